@@ -60,3 +60,20 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+Probe with the scheme and the basic auth header of the exporter web endpoints.
+*/}}
+{{- define "mikrotik-prom-exporter.probe" -}}
+{{- $probe := deepCopy .probe }}
+{{- with $probe.httpGet }}
+{{- $_ := set . "scheme" ($.web.scheme | default "HTTP") }}
+{{- with $.web.basicAuth }}
+{{- if .username }}
+{{- $auth := printf "%s:%s" .username .password | b64enc | printf "Basic %s" }}
+{{- $_ := set $probe.httpGet "httpHeaders" (append ($probe.httpGet.httpHeaders | default list) (dict "name" "Authorization" "value" $auth)) }}
+{{- end }}
+{{- end }}
+{{- end }}
+{{- toYaml $probe }}
+{{- end }}
@@ -4,23 +4,29 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/vaerh/mikrotik-prom-exporter/config"
 	"github.com/vaerh/mikrotik-prom-exporter/exporter"
 	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v2"
 	complexmetrics "github.com/vaerh/mikrotik-prom-exporter/complex_metrics"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -39,18 +45,26 @@ var (
 		Aliases:  []string{"r"},
 	}
 	flagUsername = &cli.StringFlag{
-		Name:     "username",
-		Usage:    "`USERNAME` for router authentication",
-		EnvVars:  []string{"USERNAME"},
-		Required: true,
-		Aliases:  []string{"u"},
+		Name:    "username",
+		Usage:   "`USERNAME` for router authentication",
+		EnvVars: []string{"USERNAME"},
+		Aliases: []string{"u"},
+	}
+	flagUsernameFile = &cli.StringFlag{
+		Name:    "username-file",
+		Usage:   "`FILE` with the user name for router authentication",
+		EnvVars: []string{"USERNAME_FILE"},
 	}
 	flagPassword = &cli.StringFlag{
-		Name:     "password",
-		Usage:    "`PASSWORD` for router authentication",
-		EnvVars:  []string{"PASSWORD"},
-		Required: true,
-		Aliases:  []string{"p"},
+		Name:    "password",
+		Usage:   "`PASSWORD` for router authentication, visible in the process list, prefer --password-file",
+		EnvVars: []string{"PASSWORD"},
+		Aliases: []string{"p"},
+	}
+	flagPasswordFile = &cli.StringFlag{
+		Name:    "password-file",
+		Usage:   "`FILE` with the password for router authentication, re-read when it changes",
+		EnvVars: []string{"PASSWORD_FILE"},
 	}
 	flagInsecure = &cli.BoolFlag{
 		Name:    "insecure", // curl -k/--insecure
@@ -70,6 +84,57 @@ var (
 		Value:       "Sample-Router",
 		DefaultText: "Sample-Router",
 	}
+	flagRouterLabels = &cli.StringFlag{
+		Name: "router-labels",
+		Usage: "router labels `MODE`: 'legacy' adds routerboard_address, routerboard_id and routerboard_alias to all metrics, " +
+			"'info' adds only routerboard_address, so identity changes don't change the series, and the router details are joined " +
+			"from mikrotik_router_info",
+		EnvVars:     []string{"ROUTER_LABELS"},
+		Value:       exporter.RouterLabelsLegacy,
+		DefaultText: exporter.RouterLabelsLegacy,
+		Action: func(ctx *cli.Context, v string) error {
+			if v != exporter.RouterLabelsInfo && v != exporter.RouterLabelsLegacy {
+				return fmt.Errorf("router labels mode '%v' must be '%v' or '%v'", v, exporter.RouterLabelsInfo, exporter.RouterLabelsLegacy)
+			}
+			return nil
+		},
+	}
+	flagWebConfig = &cli.StringFlag{
+		Name: "web.config.file",
+		Usage: "web configuration `FILE` enabling TLS, client certificate and basic authentication, " +
+			"https://github.com/prometheus/exporter-toolkit/blob/master/docs/web-configuration.md",
+		EnvVars: []string{"WEB_CONFIG_FILE"},
+		Action: func(ctx *cli.Context, v string) error {
+			if err := web.Validate(v); err != nil {
+				return fmt.Errorf("error checking web configuration file, %v", err)
+			}
+			return nil
+		},
+	}
+	flagInterval = &cli.StringFlag{
+		Name:        "interval",
+		Usage:       "Default `INTERVAL` of metrics collection https://pkg.go.dev/time#ParseDuration, schemas and the configuration can override it",
+		Value:       "30s",
+		DefaultText: "30s",
+		EnvVars:     []string{"INTERVAL"},
+		Action: func(ctx *cli.Context, v string) error {
+			t, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("metrics collection interval parsing error, %v", err)
+			}
+			if t <= 0 {
+				return fmt.Errorf("metrics collection interval '%v' must be positive", v)
+			}
+			return nil
+		},
+		Aliases: []string{"i"},
+	}
+	flagConfig = &cli.StringFlag{
+		Name:    "config",
+		Usage:   "configuration `FILE` with per router settings",
+		EnvVars: []string{"CONFIG_FILE"},
+		Aliases: []string{"c"},
+	}
 )
 
 func main() {
@@ -78,6 +143,55 @@ func main() {
 		Name:  "mikrotik-prom-exporter",
 		Usage: "export metrics in prometheus format from a mikrotik device",
 		Commands: []*cli.Command{
+			{
+				Name:  "schema",
+				Usage: "metrics schema tools",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "scaffold",
+						Usage:     "generate a schema draft from a live resource",
+						ArgsUsage: "PATH",
+						Action:    cli.ActionFunc(scaffold),
+						Flags: []cli.Flag{
+							flagHostURL,
+							flagUsername,
+							flagUsernameFile,
+							flagPassword,
+							flagPasswordFile,
+							flagInsecure,
+							flagCaCert,
+							&cli.StringFlag{
+								Name:    "output",
+								Usage:   "write the schema to `FILE` instead of stdout",
+								Aliases: []string{"o"},
+							},
+						},
+					},
+					{
+						Name:      "expand",
+						Usage:     "print schemas with the defaults, extended and included files resolved",
+						ArgsUsage: "[FILE|DIR]",
+						Action:    cli.ActionFunc(expand),
+					},
+				},
+			},
+			{
+				Name:   "check-permissions",
+				Usage:  "report the enabled schemas and collectors the router user group lacks policies for",
+				Action: cli.ActionFunc(checkPermissions),
+				Flags: append([]cli.Flag{
+					flagHostURL,
+					flagUsername,
+					flagUsernameFile,
+					flagPassword,
+					flagPasswordFile,
+					flagInsecure,
+					flagCaCert,
+					flagRouterAlias,
+					flagConfig,
+					flagInterval,
+				}, collectorFlags()...),
+			},
 			{
 				Name: "version",
 				Action: cli.ActionFunc(func(ctx *cli.Context) error {
@@ -99,16 +213,31 @@ func main() {
 				Action:       cli.ActionFunc(export),
 				OnUsageError: nil,
 				Subcommands:  nil,
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					flagHostURL,
 					flagUsername,
+					flagUsernameFile,
 					flagPassword,
+					flagPasswordFile,
 					flagInsecure,
 					flagCaCert,
 					flagRouterAlias,
+					flagRouterLabels,
+					flagConfig,
+					flagWebConfig,
+					&cli.StringSliceFlag{
+						Name:    "web.listen-address",
+						Usage:   "`ADDRESS` to listen on: host:port, [ipv6]:port or unix:/path/to/socket, can be repeated",
+						EnvVars: []string{"WEB_LISTEN_ADDRESS"},
+					},
+					&cli.BoolFlag{
+						Name:    "web.disable-metrics",
+						Usage:   "don't serve /metrics, the metrics are only pushed by the outputs of the configuration",
+						EnvVars: []string{"WEB_DISABLE_METRICS"},
+					},
 					&cli.IntFlag{
 						Name:        "listen",
-						Usage:       "mikrotik exporter `PORT`",
+						Usage:       "mikrotik exporter `PORT` on all interfaces, used if --web.listen-address is not set",
 						Value:       9100,
 						DefaultText: "9100",
 						EnvVars:     []string{"LISTEN_PORT"},
@@ -120,24 +249,7 @@ func main() {
 						},
 						Aliases: []string{"l"},
 					},
-					&cli.StringFlag{
-						Name:        "interval",
-						Usage:       "Positive `INTERVAL` of metrics collection https://pkg.go.dev/time#ParseDuration",
-						Value:       "30s",
-						DefaultText: "30s",
-						EnvVars:     []string{"INTERVAL"},
-						Action: func(ctx *cli.Context, v string) error {
-							t, err := time.ParseDuration(v)
-							if err != nil {
-								return fmt.Errorf("metrics collection interval parsing error, %v", err)
-							}
-							if t < 5*time.Second {
-								return fmt.Errorf("metrics collection interval '%v' must be greater than or equal to 5 seconds", v)
-							}
-							return nil
-						},
-						Aliases: []string{"i"},
-					},
+					flagInterval,
 					&cli.StringFlag{
 						Name:        "resources",
 						Usage:       "`DIR`ECTORY with metrics schemas",
@@ -167,7 +279,7 @@ func main() {
 							return nil
 						},
 					},
-				},
+				}, collectorFlags()...),
 				SkipFlagParsing:        false,
 				HideHelp:               false,
 				HideHelpCommand:        false,
@@ -186,7 +298,20 @@ func main() {
 
 func export(cliCtx *cli.Context) error {
 	ctx := cliCtx.Context
-	schemas, err := exporter.LoadResSchemas(ctx, "resources")
+
+	routerCfg, err := loadRouterConfig(cliCtx)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("loading config")
+	}
+
+	metricsCollectionInterval, _ := time.ParseDuration(flagInterval.Get(cliCtx))
+
+	schemas, err := loadSchemas(ctx, routerCfg, metricsCollectionInterval)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("")
+	}
+
+	creds, err := routerCredentials(cliCtx, routerCfg.PasswordFile)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("")
 	}
@@ -195,20 +320,24 @@ func export(cliCtx *cli.Context) error {
 		Insecure:      flagInsecure.Get(cliCtx),
 		CaCertificate: flagCaCert.Get(cliCtx),
 		HostURL:       flagHostURL.Get(cliCtx),
-		Username:      flagUsername.Get(cliCtx),
-		Password:      flagPassword.Get(cliCtx),
+		Credentials:   creds,
 	}
 
 	u, err := url.Parse(flagHostURL.Get(cliCtx))
+	if err == nil && u.Host == "" {
+		// The same default as the client
+		u, err = url.Parse("https://" + flagHostURL.Get(cliCtx))
+	}
 	if err != nil {
 		log.Fatal().Err(err).Msg("parsing router host url")
 	}
 
-	globalVars := map[string]string{
+	globalVars := exporter.NewGlobalVars(map[string]string{
 		"HOSTURL":  u.Host,
-		"USERNAME": flagUsername.Get(cliCtx),
+		"HOSTNAME": u.Hostname(),
+		"USERNAME": creds.Username,
 		"ALIAS":    flagRouterAlias.Get(cliCtx),
-	}
+	}, routerCfg.GlobalVarSources())
 
 	ctx, cancelFn := context.WithCancel(ctx)
 
@@ -217,27 +346,48 @@ func export(cliCtx *cli.Context) error {
 		logger.Fatal().Err(err).Msg("creating mikrotik client")
 	}
 
-	res, err := client.SendRequest(mikrotik.CrudRead, &mikrotik.URL{Path: "/system/identity"}, nil)
-	if err != nil {
-		logger.Err(err).Msg("read router identity")
-	} else {
-		if len(res) > 0 {
-			globalVars["ROUTER_ID"] = res[0]["name"]
-		}
-	}
-
 	// start http service ASAP to be sure it actually is online
 	globalReg := prometheus.NewRegistry()
+	// The schema and collector metrics, the router labels of the mode are added when they are gathered
+	routerReg := prometheus.NewRegistry()
+	routerLabelsMode := flagRouterLabels.Get(cliCtx)
+	gatherer := prometheus.Gatherers{globalReg, exporter.NewRouterLabelsGatherer(routerReg, routerLabelsMode, globalVars)}
 	// http.Handle("/metrics", promhttp.Handler())
-	http.Handle("/metrics", promhttp.HandlerFor(globalReg, promhttp.HandlerOpts{}))
+	if !cliCtx.Bool("web.disable-metrics") {
+		http.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	}
 
-	go func() {
-		if err = http.ListenAndServe(fmt.Sprintf(":%d", cliCtx.Int("listen")), nil); err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				logger.Fatal().Err(err).Msg("listening and starting http server for metrics")
-			}
-		}
-	}()
+	sysInfo := exporter.NewSystemInfoWatcher()
+	status := exporter.NewStatus(prometheus.Labels{exporter.TargetLabel: globalVars.Get("HOSTURL")})
+	globalReg.MustRegister(status)
+	globalReg.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Name:        "mikrotik_exporter_auth_failures_total",
+		Help:        "Number of logins rejected by the router",
+		ConstLabels: prometheus.Labels{exporter.TargetLabel: globalVars.Get("HOSTURL")},
+	}, func() float64 {
+		return float64(creds.AuthFailures())
+	}))
+
+	(&statusHandlers{
+		router:  globalVars.Get("HOSTURL"),
+		status:  status,
+		sysInfo: sysInfo,
+		metrics: !cliCtx.Bool("web.disable-metrics"),
+		logger:  *zerolog.Ctx(ctx),
+	}).register()
+
+	listenAddresses := cliCtx.StringSlice("web.listen-address")
+	if len(listenAddresses) == 0 {
+		listenAddresses = []string{fmt.Sprintf(":%d", cliCtx.Int("listen"))}
+	}
+	webSrv, err := listenWeb(listenAddresses)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("listening and starting http server for metrics")
+	}
+
+	webErrs := make(chan error, len(listenAddresses))
+	// The web configuration file is read on every TLS handshake, so rotated certificates are picked up
+	webSrv.serve(nil, flagWebConfig.Get(cliCtx), newSlogLogger(*zerolog.Ctx(ctx)), webErrs)
 
 	ctx = client.WithContext(ctx)
 
@@ -247,26 +397,83 @@ func export(cliCtx *cli.Context) error {
 	wg := sync.WaitGroup{}
 	// sem := semaphore.NewWeighted(maxConcurrentWorkers)
 
-	metricsCollectionInterval, _ := time.ParseDuration(cliCtx.String("interval"))
+	resourceCache := exporter.NewResourceCache(exporter.DefaultResourceCacheTTL)
+
+	wg.Add(1)
+	go func() {
+		creds.Watch(ctx, mikrotik.DefaultPasswordFileCheckInterval)
+		wg.Done()
+	}()
+
+	if err := globalVars.Refresh(ctx); err != nil {
+		logger.Err(err).Msg("read global variables")
+	}
+
+	wg.Add(1)
+	go func() {
+		globalVars.Watch(ctx, exporter.DefaultGlobalVarsRefreshInterval)
+		wg.Done()
+	}()
+
+	routerLabels := prometheus.Labels{exporter.TargetLabel: globalVars.Get("HOSTURL")}
+	globalReg.MustRegister(exporter.NewRouterInfoCollector(globalVars))
+
+	outputs, pointWriter, err := pushOutputs(routerCfg, gatherer, metricsCollectionInterval, globalVars,
+		exporter.RouterLabels(routerLabelsMode, globalVars))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("creating outputs")
+	}
+	if len(outputs) == 0 && cliCtx.Bool("web.disable-metrics") {
+		logger.Fatal().Msg("--web.disable-metrics requires remote_write, otlp or influxdb in the configuration")
+	}
+
+	if _, err := sysInfo.Refresh(ctx); err != nil {
+		logger.Err(err).Msg("read router system information")
+	}
+
+	wg.Add(1)
+	go func() {
+		sysInfo.Watch(ctx, exporter.DefaultSystemInfoCheckInterval)
+		wg.Done()
+	}()
+
+	for _, c := range complexmetrics.Collectors() {
+		if !collectorEnabled(cliCtx, routerCfg, c) {
+			logger.Debug().Str("collector", c.Name).Msg("collector disabled")
+			continue
+		}
+		interval := routerCfg.CollectorInterval(c.Name, metricsCollectionInterval)
+		if interval < exporter.MinCollectionInterval {
+			logger.Error().Str("collector", c.Name).Msgf("skipping collector: collection interval '%v' must be greater than or equal to %v",
+				interval, exporter.MinCollectionInterval)
+			continue
+		}
 
-	for _, m := range complexmetrics.ComplexMetrics.Get() {
 		wg.Add(1)
 
+		m := c.New()
 		workerReg := prometheus.NewRegistry()
-		globalReg.MustRegister(workerReg)
+		routerReg.MustRegister(workerReg)
+		// The requirements are checked again when the router system information changes,
+		// the metrics of an inactive collector are not exposed
+		gate := exporter.NewRequirementsGate(exporter.StatusKindCollector, c.Name, &c.Requires, sysInfo)
+		gate.OnChange(func(active bool) {
+			if active {
+				_ = routerReg.Register(workerReg)
+			} else {
+				routerReg.Unregister(workerReg)
+			}
+		})
+		collectorCtx := gate.WithContext(status.Track(exporter.StatusKindCollector, c.Name, interval).WithContext(ctx))
 
 		go func() {
-			defer globalReg.Unregister(workerReg)
+			defer routerReg.Unregister(workerReg)
 
-			m.Register(ctx, prometheus.Labels{
-				"routerboard_address": globalVars["HOSTURL"],
-				"routerboard_id":      globalVars["ROUTER_ID"],
-				"routerboard_alias":   globalVars["ALIAS"]},
-				workerReg)
-			m.SetCollectInterval(metricsCollectionInterval)
+			m.Register(collectorCtx, routerLabels, workerReg)
+			m.SetCollectInterval(interval)
 
-			if err := m.StartCollecting(ctx); err != nil {
-				logger.Err(err).Msg("exporting metrics")
+			if err := m.StartCollecting(collectorCtx); err != nil {
+				logger.Err(err).Str("collector", c.Name).Msg("exporting metrics")
 			}
 
 			wg.Done()
@@ -277,18 +484,20 @@ func export(cliCtx *cli.Context) error {
 		wg.Add(1)
 
 		workerReg := prometheus.NewRegistry()
-		globalReg.MustRegister(workerReg)
+		routerReg.MustRegister(workerReg)
+
+		rExporter := exporter.NewResourceExporter(ctx, &s, routerLabels, workerReg)
+		rExporter.SetGlobalVars(globalVars)
+		rExporter.SetResourceCache(resourceCache)
+		rExporter.SetSystemInfo(sysInfo)
+		rExporter.SetCollectInterval(metricsCollectionInterval)
+		rExporter.SetStatus(status.Track(exporter.StatusKindSchema, s.Name, rExporter.GetCollectInterval()))
+		if pointWriter != nil {
+			rExporter.SetPointWriter(pointWriter)
+		}
 
 		go func() {
-			defer globalReg.Unregister(workerReg)
-
-			rExporter := exporter.NewResourceExporter(ctx, &s, prometheus.Labels{
-				"routerboard_address": globalVars["HOSTURL"],
-				"routerboard_id":      globalVars["ROUTER_ID"],
-				"routerboard_alias":   globalVars["ALIAS"]},
-				workerReg)
-			rExporter.SetGlobalVars(globalVars)
-			rExporter.SetCollectInterval(metricsCollectionInterval)
+			defer routerReg.Unregister(workerReg)
 
 			if err := rExporter.ExportMetrics(ctx); err != nil {
 				logger.Err(err).Msg("exporting metrics")
@@ -298,6 +507,19 @@ func export(cliCtx *cli.Context) error {
 		}()
 	}
 
+	for _, o := range outputs {
+		globalReg.MustRegister(o)
+
+		wg.Add(1)
+		go func() {
+			o.Run(ctx)
+			wg.Done()
+		}()
+	}
+
+	// All schemas and collectors are tracked, the readiness check can wait for them
+	status.Started()
+
 	// for done := false; !done; {
 	// 	select {
 	// 	case <-signalChan:
@@ -306,10 +528,209 @@ func export(cliCtx *cli.Context) error {
 	// 	}
 	// }
 
-	<-signalChan
+	select {
+	case <-signalChan:
+	case err := <-webErrs:
+		logger.Err(err).Msg("serving metrics")
+	}
+
+	// Let in-flight scrapes finish before stopping the exporters
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), webShutdownTimeout)
+	if err := webSrv.shutdown(shutdownCtx); err != nil {
+		logger.Err(err).Msg("shutting down http server")
+	}
+	cancelShutdown()
 	cancelFn()
 
 	log.Printf("waiting for exporters")
 	wg.Wait()
 	return nil
 }
+
+// loadRouterConfig Returns the settings of the router from the configuration file, if any.
+func loadRouterConfig(cliCtx *cli.Context) (*config.Router, error) {
+	cfg := &config.Config{}
+	if fileName := flagConfig.Get(cliCtx); fileName != "" {
+		var err error
+		if cfg, err = config.Load(fileName); err != nil {
+			return nil, err
+		}
+	}
+	return cfg.ForRouter(flagRouterAlias.Get(cliCtx)), nil
+}
+
+// loadSchemas Loads the schemas and the template instances with the router settings applied.
+// Schemas with an invalid collection interval are skipped.
+func loadSchemas(ctx context.Context, routerCfg *config.Router, defaultInterval time.Duration) ([]exporter.ResourceSchema, error) {
+	schemas, err := exporter.LoadResSchemas(ctx, "resources")
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := exporter.LoadSchemaInstances(ctx, "resources", routerCfg.SchemaInstances())
+	if err != nil {
+		return nil, err
+	}
+	schemas = append(schemas, instances...)
+
+	var checked = schemas[:0]
+	for _, s := range schemas {
+		routerCfg.ApplySchema(&s)
+		// Schemas without their own interval use the flag value
+		if err := s.CheckInterval(defaultInterval); err != nil {
+			logger.Error().Err(err).Msg("skipping schema")
+			continue
+		}
+		checked = append(checked, s)
+	}
+
+	return checked, nil
+}
+
+// routerCredentials Returns the router credentials set by the flags. The password file from the
+// configuration is used if neither --password nor --password-file is set.
+func routerCredentials(cliCtx *cli.Context, passwordFile string) (*mikrotik.Credentials, error) {
+	username := flagUsername.Get(cliCtx)
+	if fileName := flagUsernameFile.Get(cliCtx); fileName != "" {
+		b, err := os.ReadFile(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("reading user name file '%s': %w", fileName, err)
+		}
+		username = strings.TrimSpace(string(b))
+	}
+	if username == "" {
+		return nil, fmt.Errorf("router user name is not defined, set --username or --username-file")
+	}
+
+	password := flagPassword.Get(cliCtx)
+	if fileName := flagPasswordFile.Get(cliCtx); fileName != "" {
+		if password != "" {
+			return nil, fmt.Errorf("you have selected mutually exclusive options: --password and --password-file")
+		}
+		passwordFile = fileName
+	}
+	// An empty password is valid, e.g. the default admin user
+	if password != "" {
+		passwordFile = ""
+	}
+
+	return mikrotik.NewCredentials(username, password, passwordFile)
+}
+
+// scaffold Reads the resource and prints a schema draft.
+func scaffold(cliCtx *cli.Context) error {
+	resourcePath := cliCtx.Args().First()
+	if resourcePath == "" {
+		return fmt.Errorf("resource path is not defined, e.g. /ip/ipsec/active-peers")
+	}
+	resourcePath = "/" + strings.Trim(resourcePath, "/")
+
+	creds, err := routerCredentials(cliCtx, "")
+	if err != nil {
+		return err
+	}
+
+	client, err := mikrotik.NewClient(cliCtx.Context, &mikrotik.Config{
+		Insecure:      flagInsecure.Get(cliCtx),
+		CaCertificate: flagCaCert.Get(cliCtx),
+		HostURL:       flagHostURL.Get(cliCtx),
+		Credentials:   creds,
+	})
+	if err != nil {
+		return fmt.Errorf("creating mikrotik client: %w", err)
+	}
+
+	items, err := mikrotik.Read(resourcePath, client, nil)
+	if err != nil {
+		return fmt.Errorf("reading '%s': %w", resourcePath, err)
+	}
+
+	var w io.Writer = os.Stdout
+	if fileName := cliCtx.String("output"); fileName != "" {
+		f, err := os.Create(fileName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return exporter.Scaffold(w, resourcePath, items)
+}
+
+// expand Prints the expanded schemas of the file or directory, the resources directory by default.
+func expand(cliCtx *cli.Context) error {
+	var path = "resources"
+	if cliCtx.Args().Present() {
+		path = cliCtx.Args().First()
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	var files = []string{path}
+	if fi.IsDir() {
+		if files, err = exporter.SchemaFiles(path); err != nil {
+			return err
+		}
+	}
+
+	for i, file := range files {
+		doc, err := exporter.ExpandSchemaFile(file)
+		if err != nil {
+			return err
+		}
+		// Check that the expanded schema is valid, templates are checked when instantiated
+		if _, err := exporter.SchemaParser(file); err != nil && !errors.Is(err, exporter.ErrSchemaTemplate) {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Printf("# %s\n", file)
+
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+		enc.Close()
+	}
+
+	return nil
+}
+
+// collectorFlags Returns the --collector.<name> and --no-collector.<name> flags for all registered collectors.
+func collectorFlags() []cli.Flag {
+	var res []cli.Flag
+	for _, c := range complexmetrics.Collectors() {
+		res = append(res,
+			&cli.BoolFlag{
+				Name:        "collector." + c.Name,
+				Usage:       fmt.Sprintf("enable the %s collector: %s", c.Name, c.Description),
+				DefaultText: strconv.FormatBool(c.DefaultEnabled),
+				Category:    "Collectors",
+			},
+			&cli.BoolFlag{
+				Name:     "no-collector." + c.Name,
+				Usage:    fmt.Sprintf("disable the %s collector", c.Name),
+				Category: "Collectors",
+			},
+		)
+	}
+	return res
+}
+
+// collectorEnabled Returns the collector state set by the flags, the configuration file or the collector default.
+func collectorEnabled(cliCtx *cli.Context, routerCfg *config.Router, c *complexmetrics.Collector) bool {
+	if cliCtx.Bool("no-collector." + c.Name) {
+		return false
+	}
+	if cliCtx.IsSet("collector." + c.Name) {
+		return cliCtx.Bool("collector." + c.Name)
+	}
+	return routerCfg.CollectorEnabled(c.Name, c.DefaultEnabled)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/urfave/cli/v2"
+	complexmetrics "github.com/vaerh/mikrotik-prom-exporter/complex_metrics"
+	"github.com/vaerh/mikrotik-prom-exporter/config"
+)
+
+func TestCollectorEnabled(t *testing.T) {
+	enabled, disabled := true, false
+
+	tests := []struct {
+		name           string
+		args           []string
+		config         *bool
+		defaultEnabled bool
+		want           bool
+	}{
+		{name: "default enabled", defaultEnabled: true, want: true},
+		{name: "default disabled", defaultEnabled: false, want: false},
+		{name: "config enables", config: &enabled, want: true},
+		{name: "config disables", config: &disabled, defaultEnabled: true, want: false},
+		{name: "flag enables", args: []string{"--collector.poe"}, config: &disabled, want: true},
+		{name: "flag disables", args: []string{"--collector.poe=false"}, config: &enabled, defaultEnabled: true, want: false},
+		{name: "no-collector flag", args: []string{"--no-collector.poe"}, config: &enabled, defaultEnabled: true, want: false},
+		{name: "no-collector flag wins", args: []string{"--collector.poe", "--no-collector.poe"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routerCfg := &config.Router{Collectors: map[string]config.CollectorOverride{}}
+			if tt.config != nil {
+				routerCfg.Collectors["poe"] = config.CollectorOverride{Enabled: tt.config}
+			}
+			c := &complexmetrics.Collector{Name: "poe", DefaultEnabled: tt.defaultEnabled}
+
+			var got bool
+			app := &cli.App{
+				Flags: collectorFlags(),
+				Action: func(cliCtx *cli.Context) error {
+					got = collectorEnabled(cliCtx, routerCfg, c)
+					return nil
+				},
+			}
+			if err := app.Run(append([]string{"exporter"}, tt.args...)); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
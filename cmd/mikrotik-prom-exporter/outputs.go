@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/vaerh/mikrotik-prom-exporter/config"
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+	"github.com/vaerh/mikrotik-prom-exporter/output"
+)
+
+// pushOutput Output pushing the gathered metrics, its own metrics are exported too.
+type pushOutput interface {
+	prometheus.Collector
+	Run(ctx context.Context)
+}
+
+// pushOutputs Creates the push outputs configured for the router and the writer of the schema rows,
+// nil if no output writes them.
+func pushOutputs(routerCfg *config.Router, gatherer prometheus.Gatherer, interval time.Duration,
+	globalVars *exporter.GlobalVars, routerLabels prometheus.Labels) ([]pushOutput, exporter.PointWriter, error) {
+	var res []pushOutput
+	var points exporter.PointWriter
+	constLabels := prometheus.Labels{exporter.TargetLabel: globalVars.Get("HOSTURL")}
+
+	if routerCfg.RemoteWrite != nil {
+		w, err := output.NewRemoteWriter(*routerCfg.RemoteWrite, gatherer, interval, constLabels)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, w)
+	}
+
+	if routerCfg.OTLP != nil {
+		// The router labels become resource attributes
+		var dropLabels = make([]string, 0, len(routerLabels))
+		for name := range routerLabels {
+			dropLabels = append(dropLabels, name)
+		}
+
+		e, err := output.NewOTLPExporter(*routerCfg.OTLP, gatherer, interval, func() map[string]string {
+			attrs := exporter.RouterResourceAttributes(globalVars)
+			attrs["service.version"] = version
+			return attrs
+		}, dropLabels, constLabels)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, e)
+	}
+
+	if routerCfg.InfluxDB != nil {
+		w, err := output.NewInfluxWriter(*routerCfg.InfluxDB, interval, constLabels)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, w)
+		points = w
+	}
+
+	return res, points, nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	complexmetrics "github.com/vaerh/mikrotik-prom-exporter/complex_metrics"
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// checkPermissions Reports the enabled schemas and collectors the router user can't collect
+// because its group lacks policies.
+func checkPermissions(cliCtx *cli.Context) error {
+	ctx := cliCtx.Context
+
+	routerCfg, err := loadRouterConfig(cliCtx)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	interval, _ := time.ParseDuration(flagInterval.Get(cliCtx))
+	schemas, err := loadSchemas(ctx, routerCfg, interval)
+	if err != nil {
+		return err
+	}
+
+	creds, err := routerCredentials(cliCtx, routerCfg.PasswordFile)
+	if err != nil {
+		return err
+	}
+
+	client, err := mikrotik.NewClient(ctx, &mikrotik.Config{
+		Insecure:      flagInsecure.Get(cliCtx),
+		CaCertificate: flagCaCert.Get(cliCtx),
+		HostURL:       flagHostURL.Get(cliCtx),
+		Credentials:   creds,
+	})
+	if errors.Is(err, mikrotik.ErrPermission) {
+		return fmt.Errorf("the group of user '%s' lacks the '%s' policy: %w", creds.Username, mikrotik.PolicyAPI, err)
+	}
+	if errors.Is(err, mikrotik.ErrAuth) {
+		return loginRejected(creds.Username, mikrotik.PolicyAPI, err)
+	}
+	if err != nil {
+		return fmt.Errorf("creating mikrotik client: %w", err)
+	}
+	ctx = client.WithContext(ctx)
+
+	transport := mikrotik.TransportPolicy(client.GetTransport())
+
+	user, err := mikrotik.ReadUserPolicies(client, creds.Username)
+	if errors.Is(err, mikrotik.ErrAuth) {
+		return loginRejected(creds.Username, transport, err)
+	}
+	if errors.Is(err, mikrotik.ErrPermission) {
+		return fmt.Errorf("the group of user '%s' lacks the '%s' or '%s' policy: %w",
+			creds.Username, mikrotik.PolicyRead, transport, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	info, err := mikrotik.ReadSystemInfo(client)
+	if err != nil {
+		return fmt.Errorf("read router system information: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "User '%s', group '%s', policies: %s\n\n", user.User, user.Group, strings.Join(user.Granted(), ","))
+	fmt.Fprintln(w, "KIND\tNAME\tSTATUS\tDETAILS")
+
+	var failed int
+	report := func(kind, name string, requires *mikrotik.Requirements, policies []string) {
+		if err := requires.Check(ctx, info); err != nil {
+			fmt.Fprintf(w, "%s\t%s\tinactive\t%v\n", kind, name, err)
+			return
+		}
+		missing := user.Missing(append([]string{transport}, policies...))
+		if len(missing) > 0 {
+			failed++
+			fmt.Fprintf(w, "%s\t%s\tfail\tmissing policies: %s\n", kind, name, strings.Join(missing, ", "))
+			return
+		}
+		fmt.Fprintf(w, "%s\t%s\tok\t\n", kind, name)
+	}
+
+	for _, s := range schemas {
+		report(exporter.StatusKindSchema, s.Name, &s.Requires, s.RequiredPolicies())
+	}
+	for _, c := range complexmetrics.Collectors() {
+		if !collectorEnabled(cliCtx, routerCfg, c) {
+			continue
+		}
+		report(exporter.StatusKindCollector, c.Name, &c.Requires, append([]string{mikrotik.PolicyRead}, c.Policies...))
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return cli.Exit(fmt.Sprintf("%d schemas and collectors will fail, add the missing policies to group '%s'", failed, user.Group), 1)
+	}
+	return nil
+}
+
+// loginRejected Returns the error of a rejected login. RouterOS rejects the login both for a wrong
+// password and for a group without the transport policy.
+func loginRejected(username, transport string, err error) error {
+	return fmt.Errorf("the router rejected the login of user '%s': the password may be wrong or the group may lack the '%s' policy: %w",
+		username, transport, err)
+}
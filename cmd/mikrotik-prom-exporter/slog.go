@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/rs/zerolog"
+)
+
+// newSlogLogger Returns a slog logger writing to the zerolog logger, for the libraries that use slog.
+func newSlogLogger(logger zerolog.Logger) *slog.Logger {
+	return slog.New(&zerologHandler{logger: logger})
+}
+
+type zerologHandler struct {
+	logger zerolog.Logger
+	attrs  []slog.Attr
+	group  string
+}
+
+func zerologLevel(level slog.Level) zerolog.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zerolog.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zerolog.WarnLevel
+	case level >= slog.LevelInfo:
+		return zerolog.InfoLevel
+	default:
+		return zerolog.DebugLevel
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *zerologHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return zerologLevel(level) >= h.logger.GetLevel()
+}
+
+// Handle implements slog.Handler.
+func (h *zerologHandler) Handle(_ context.Context, r slog.Record) error {
+	e := h.logger.WithLevel(zerologLevel(r.Level))
+
+	add := func(a slog.Attr) {
+		if err, ok := a.Value.Resolve().Any().(error); ok {
+			e = e.AnErr(a.Key, err)
+		} else {
+			e = e.Interface(a.Key, a.Value.Resolve().Any())
+		}
+	}
+
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		add(h.groupAttr(a))
+		return true
+	})
+
+	e.Msg(r.Message)
+	return nil
+}
+
+// WithAttrs implements slog.Handler.
+func (h *zerologHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var res = *h
+	res.attrs = append([]slog.Attr{}, h.attrs...)
+	for _, a := range attrs {
+		res.attrs = append(res.attrs, h.groupAttr(a))
+	}
+	return &res
+}
+
+// WithGroup implements slog.Handler.
+func (h *zerologHandler) WithGroup(name string) slog.Handler {
+	var res = *h
+	if res.group != "" {
+		res.group += "." + name
+	} else {
+		res.group = name
+	}
+	return &res
+}
+
+func (h *zerologHandler) groupAttr(a slog.Attr) slog.Attr {
+	if h.group != "" {
+		a.Key = h.group + "." + a.Key
+	}
+	return a
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+)
+
+// statusPage Status page of the schemas and collectors.
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string {
+		return d.Round(time.Millisecond).String()
+	},
+	"time": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>MikroTik exporter</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.error { color: #c00; }
+.inactive { color: #888; }
+</style>
+</head>
+<body>
+<h1>MikroTik exporter {{.Version}}</h1>
+<p>Router: {{.Router}} ({{if .Reachable}}reachable{{else}}<span class="error">unreachable</span>{{end}})</p>
+<p>Ready: {{if .NotReady}}<span class="error">{{.NotReady}}</span>{{else}}yes{{end}}</p>
+<p>{{if .Metrics}}<a href="/metrics">Metrics</a> | {{end}}<a href="/?format=json">JSON</a></p>
+<table>
+<tr><th>Kind</th><th>Name</th><th>Interval</th><th>Runs</th><th>Last run</th><th>Duration</th><th>Rows</th><th>Next run</th><th>Last error</th></tr>
+{{- range .Collections}}
+<tr{{if .Inactive}} class="inactive"{{end}}>
+<td>{{.Kind}}</td><td>{{.Name}}</td><td>{{duration .Interval}}</td><td>{{.Runs}}</td>
+{{- if .Runs}}
+<td>{{time .LastRun}}</td><td>{{duration .LastDuration}}</td><td>{{if ge .Rows 0}}{{.Rows}}{{end}}</td><td>{{time .NextRun}}</td>
+{{- else}}
+<td></td><td></td><td></td><td></td>
+{{- end}}
+<td class="error">{{if .Inactive}}inactive{{else if .LastError}}{{.LastErrorReason}}: {{.LastError}}{{end}}</td>
+</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// statusHandlers Serves /-/healthy, /-/ready and the status page.
+type statusHandlers struct {
+	router  string
+	status  *exporter.Status
+	sysInfo *exporter.SystemInfoWatcher
+	// metrics /metrics is served
+	metrics bool
+	logger  zerolog.Logger
+}
+
+// ready Returns an error until the router is reachable and every schema and collector has been collected.
+func (h *statusHandlers) ready() error {
+	if !h.sysInfo.Reachable() {
+		return fmt.Errorf("router '%s' is unreachable", h.router)
+	}
+	return h.status.Ready()
+}
+
+// register Registers the handlers in the default mux.
+func (h *statusHandlers) register() {
+	http.HandleFunc("/-/healthy", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "Healthy")
+	})
+
+	http.HandleFunc("/-/ready", func(w http.ResponseWriter, r *http.Request) {
+		if err := h.ready(); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "Not ready: %v\n", err)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "Ready")
+	})
+
+	http.HandleFunc("/", h.serveStatus)
+}
+
+// serveStatus Serves the status page as HTML or as JSON with ?format=json or Accept: application/json.
+func (h *statusHandlers) serveStatus(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var readyErr string
+	if err := h.ready(); err != nil {
+		readyErr = err.Error()
+	}
+
+	var data = struct {
+		Version     string                      `json:"version"`
+		Router      string                      `json:"router"`
+		Reachable   bool                        `json:"reachable"`
+		NotReady    string                      `json:"not_ready,omitempty"`
+		Metrics     bool                        `json:"-"`
+		Collections []exporter.CollectionStatus `json:"collections"`
+	}{
+		Version:     version,
+		Router:      h.router,
+		Reachable:   h.sysInfo.Reachable(),
+		NotReady:    readyErr,
+		Metrics:     h.metrics,
+		Collections: h.status.Collections(),
+	}
+
+	var err error
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(data)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = statusPage.Execute(w, data)
+	}
+	if err != nil {
+		h.logger.Err(err).Msg("writing status page")
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/exporter-toolkit/web"
+)
+
+const (
+	webReadHeaderTimeout = 10 * time.Second
+	webReadTimeout       = 30 * time.Second
+	webWriteTimeout      = time.Minute
+	webIdleTimeout       = 2 * time.Minute
+	// webShutdownTimeout Time given to in-flight scrapes to finish on shutdown
+	webShutdownTimeout = 30 * time.Second
+)
+
+// webServer Serves the exporter endpoints on one or more listeners.
+type webServer struct {
+	listeners []net.Listener
+	servers   []*http.Server
+}
+
+// listenWeb Opens the listeners. Addresses are host:port or unix:/path/to/socket.
+func listenWeb(addresses []string) (*webServer, error) {
+	var w = &webServer{}
+	for _, address := range addresses {
+		l, err := listen(address)
+		if err != nil {
+			w.close()
+			return nil, fmt.Errorf("listening on '%s': %w", address, err)
+		}
+		w.listeners = append(w.listeners, l)
+	}
+	return w, nil
+}
+
+func listen(address string) (net.Listener, error) {
+	path, ok := strings.CutPrefix(address, "unix:")
+	if !ok {
+		return net.Listen("tcp", address)
+	}
+
+	// Remove the socket left by a previous run
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", path)
+}
+
+// serve Serves the handler on all listeners. Each listener has its own server, as the
+// exporter-toolkit wraps the server handler for basic authentication.
+// Errors other than http.ErrServerClosed are sent to errCh.
+func (w *webServer) serve(handler http.Handler, webConfigFile string, logger *slog.Logger, errCh chan<- error) {
+	for _, l := range w.listeners {
+		server := &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: webReadHeaderTimeout,
+			ReadTimeout:       webReadTimeout,
+			WriteTimeout:      webWriteTimeout,
+			IdleTimeout:       webIdleTimeout,
+		}
+		w.servers = append(w.servers, server)
+
+		go func() {
+			err := web.Serve(l, server, &web.FlagConfig{
+				WebListenAddresses: &[]string{l.Addr().String()},
+				WebSystemdSocket:   new(bool),
+				WebConfigFile:      &webConfigFile,
+			}, logger)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- err
+			}
+		}()
+	}
+}
+
+// shutdown Stops accepting connections and waits for the in-flight requests.
+func (w *webServer) shutdown(ctx context.Context) error {
+	var errs []error
+	for _, s := range w.servers {
+		errs = append(errs, s.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (w *webServer) close() {
+	for _, l := range w.listeners {
+		_ = l.Close()
+	}
+}
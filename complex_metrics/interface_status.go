@@ -13,7 +13,16 @@ import (
 )
 
 func init() {
-	ComplexMetrics.AddMetric(&InterfaceStatus{path: "/interface/ethernet", collectionInterval: DefaultMetricsCollectionInterval})
+	Register(&Collector{
+		Name:           "ethernet",
+		Description:    "Ethernet interfaces link status, rate, duplex and SFP temperature",
+		DefaultEnabled: true,
+		// The monitor command
+		Policies: []string{mikrotik.PolicyTest},
+		New: func() Metric {
+			return &InterfaceStatus{path: "/interface/ethernet", collectionInterval: DefaultMetricsCollectionInterval}
+		},
+	})
 }
 
 type InterfaceStatus struct {
@@ -87,71 +96,61 @@ func (is *InterfaceStatus) collect(ctx context.Context) error {
 		return fmt.Errorf("reading resource: %w", err)
 	}
 
-	for _, iface := range mikrotikResource {
-		var name string
-		if c := iface["comment"]; c != "" {
-			name = c
-		} else {
-			name = iface["name"]
-		}
+	// Ethernet monitor for all interfaces in a single request
+	monitor, err := (&mikrotik.BatchCommand{ResourcePath: is.path, Command: "monitor"}).Run(mikrotik.Ctx(ctx), mikrotikResource)
+	if err != nil {
+		return fmt.Errorf("reading resource: %w", err)
+	}
 
-		// Get status
-		if id, ok := iface[".id"]; ok {
-			// Ethernet monitor
-			res, err := mikrotik.Monitor(is.path, mikrotik.Ctx(ctx), map[string]string{
-				"numbers": id,
-				"once":    "",
-			})
-			if err != nil {
-				return fmt.Errorf("reading resource: %w", err)
-			}
-			if len(res) == 0 {
-				logger.Warn().Msgf("monitor empty response: %v", id)
-				continue
-			}
+	for i, iface := range mikrotikResource {
+		name := nameLabel.Get(iface, nil)
 
-			if res[0]["status"] == "link-ok" {
-				is.status.With(prometheus.Labels{"name": name}).Set(1)
-			} else {
-				is.status.With(prometheus.Labels{"name": name}).Set(0)
-			}
+		res := monitor[i]
+		if res == nil {
+			logger.Warn().Msgf("monitor empty response: %v", iface[".id"])
+			continue
+		}
 
-			switch res[0]["rate"] {
-			case "10Mbps":
-				is.rate.With(prometheus.Labels{"name": name}).Set(10)
-			case "100Mbps":
-				is.rate.With(prometheus.Labels{"name": name}).Set(100)
-			case "1Gbps":
-				is.rate.With(prometheus.Labels{"name": name}).Set(1000)
-			case "2.5Gbps":
-				is.rate.With(prometheus.Labels{"name": name}).Set(2500)
-			case "5Gbps":
-				is.rate.With(prometheus.Labels{"name": name}).Set(5000)
-			case "10Gbps":
-				is.rate.With(prometheus.Labels{"name": name}).Set(10000)
-			case "40Gbps":
-				is.rate.With(prometheus.Labels{"name": name}).Set(40000)
-			default:
-				is.rate.With(prometheus.Labels{"name": name}).Set(0)
-			}
+		if res["status"] == "link-ok" {
+			is.status.With(prometheus.Labels{"name": name}).Set(1)
+		} else {
+			is.status.With(prometheus.Labels{"name": name}).Set(0)
+		}
 
-			if res[0]["full-duplex"] == "true" {
-				is.duplex.With(prometheus.Labels{"name": name}).Set(1)
-			} else {
-				is.duplex.With(prometheus.Labels{"name": name}).Set(0)
-			}
+		switch res["rate"] {
+		case "10Mbps":
+			is.rate.With(prometheus.Labels{"name": name}).Set(10)
+		case "100Mbps":
+			is.rate.With(prometheus.Labels{"name": name}).Set(100)
+		case "1Gbps":
+			is.rate.With(prometheus.Labels{"name": name}).Set(1000)
+		case "2.5Gbps":
+			is.rate.With(prometheus.Labels{"name": name}).Set(2500)
+		case "5Gbps":
+			is.rate.With(prometheus.Labels{"name": name}).Set(5000)
+		case "10Gbps":
+			is.rate.With(prometheus.Labels{"name": name}).Set(10000)
+		case "40Gbps":
+			is.rate.With(prometheus.Labels{"name": name}).Set(40000)
+		default:
+			is.rate.With(prometheus.Labels{"name": name}).Set(0)
+		}
 
-			if temp, ok := res[0]["sfp-temperature"]; ok {
-				ft, err := strconv.ParseFloat(temp, 64)
-				if err != nil {
-					logger.Warn().Fields(map[string]any{"sfp-temperature": temp}).Err(err).Msg("extracting value from resource")
-					continue
-				}
+		if res["full-duplex"] == "true" {
+			is.duplex.With(prometheus.Labels{"name": name}).Set(1)
+		} else {
+			is.duplex.With(prometheus.Labels{"name": name}).Set(0)
+		}
 
-				is.sfpTemp.With(prometheus.Labels{"name": name}).Set(ft)
+		if temp, ok := res["sfp-temperature"]; ok {
+			ft, err := strconv.ParseFloat(temp, 64)
+			if err != nil {
+				logger.Warn().Fields(map[string]any{"sfp-temperature": temp}).Err(err).Msg("extracting value from resource")
+				continue
 			}
-		}
 
+			is.sfpTemp.With(prometheus.Labels{"name": name}).Set(ft)
+		}
 	}
 
 	return nil
@@ -2,15 +2,20 @@ package complexmetrics
 
 import (
 	"context"
-	"fmt"
+	"sort"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
 )
 
 const DefaultMetricsCollectionInterval = 30 * time.Second
 
+// nameLabel The interface name label: comment if set, otherwise the interface name.
+var nameLabel = &exporter.LabelSpec{Fields: []string{"comment", "name"}}
+
 type Metric interface {
 	Register(ctx context.Context, constLabels prometheus.Labels, reg prometheus.Registerer)
 	StartCollecting(ctx context.Context) error
@@ -18,18 +23,43 @@ type Metric interface {
 	SetCollectInterval(t time.Duration)
 }
 
-var ComplexMetrics = ComplexMetricsType{}
-
-type ComplexMetricsType struct {
-	Metrics []Metric
+// Collector Complex metrics collector registered by name.
+type Collector struct {
+	// Name Collector name used in the --collector.<name> flags and configuration
+	Name        string
+	Description string
+	// DefaultEnabled The collector runs unless disabled explicitly
+	DefaultEnabled bool
+	// Requires RouterOS version, packages and resources the collector needs, the collection cycles
+	// are skipped while they are not satisfied
+	Requires mikrotik.Requirements
+	// Policies User group policies the collector needs besides read and the transport one
+	Policies []string
+	// New Creates the collector metrics
+	New func() Metric
 }
 
-func (m *ComplexMetricsType) AddMetric(metric Metric) {
-	m.Metrics = append(m.Metrics, metric)
+var collectors = map[string]*Collector{}
+
+// Register Adds the collector to the registry.
+func Register(c *Collector) {
+	if _, ok := collectors[c.Name]; ok {
+		panic("[complexmetrics] collector already registered: " + c.Name)
+	}
+	if err := c.Requires.Validate(); err != nil {
+		panic("[complexmetrics] collector " + c.Name + ": " + err.Error())
+	}
+	collectors[c.Name] = c
 }
 
-func (m *ComplexMetricsType) Get() []Metric {
-	return m.Metrics
+// Collectors Returns all registered collectors sorted by name.
+func Collectors() []*Collector {
+	var res = make([]*Collector, 0, len(collectors))
+	for _, c := range collectors {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
 }
 
 type CollectFunc func(context.Context) error
@@ -43,13 +73,9 @@ func startCollecting(ctx context.Context, metric Metric, collectFunc CollectFunc
 	for done := false; !done; {
 		select {
 		case <-firstRun:
-			if err := collectFunc(ctx); err != nil {
-				return fmt.Errorf("exporting metrics: %w", err)
-			}
+			collect(ctx, metric, collectFunc)
 		case <-timer.C:
-			if err := collectFunc(ctx); err != nil {
-				return fmt.Errorf("exporting metrics: %w", err)
-			}
+			collect(ctx, metric, collectFunc)
 		case <-ctx.Done():
 			zerolog.Ctx(ctx).Debug().Msg("terminating exporter")
 			done = true
@@ -58,3 +84,23 @@ func startCollecting(ctx context.Context, metric Metric, collectFunc CollectFunc
 
 	return nil
 }
+
+// collect Runs a collection cycle limited by the collection interval. Errors are logged, the next cycle retries.
+// The cycle is skipped while the requirements of the collector are not satisfied, see exporter.RequirementsGate.
+func collect(ctx context.Context, metric Metric, collectFunc CollectFunc) {
+	ctx, cancel := mikrotik.WithTimeout(ctx, metric.GetCollectInterval())
+	defer cancel()
+
+	start := time.Now()
+	if !exporter.RequirementsGateCtx(ctx).Active(ctx) {
+		exporter.StatusCtx(ctx).Inactive(start)
+		return
+	}
+
+	err := collectFunc(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Err(err).Msg("exporting metrics")
+	}
+	// The collectors don't report the number of rows
+	exporter.StatusCtx(ctx).Done(start, -1, err)
+}
@@ -13,7 +13,18 @@ import (
 )
 
 func init() {
-	ComplexMetrics.AddMetric(&PoEStatus{path: "/interface/ethernet/poe", collectionInterval: DefaultMetricsCollectionInterval})
+	Register(&Collector{
+		Name:           "poe",
+		Description:    "PoE-out status, current, voltage and power",
+		DefaultEnabled: true,
+		// Only devices with PoE-out ports have this menu
+		Requires: mikrotik.Requirements{Paths: []string{"/interface/ethernet/poe"}},
+		// The monitor command
+		Policies: []string{mikrotik.PolicyTest},
+		New: func() Metric {
+			return &PoEStatus{path: "/interface/ethernet/poe", collectionInterval: DefaultMetricsCollectionInterval}
+		},
+	})
 }
 
 type PoEStatus struct {
@@ -86,85 +97,62 @@ func (poe *PoEStatus) collect(ctx context.Context) error {
 		return fmt.Errorf("reading resource: %w", err)
 	}
 
-	for _, iface := range mikrotikResource {
-		var name string
-		if c := iface["comment"]; c != "" {
-			name = c
+	// PoE monitor for all interfaces in a single request
+	monitor, err := (&mikrotik.BatchCommand{ResourcePath: poe.path, Command: "monitor"}).Run(mikrotik.Ctx(ctx), mikrotikResource)
+	if err != nil {
+		return fmt.Errorf("reading resource: %w", err)
+	}
+
+	for i, iface := range mikrotikResource {
+		name := nameLabel.Get(iface, nil)
+
+		res := monitor[i]
+		if res == nil {
+			logger.Warn().Msgf("monitor empty response: %v", iface[".id"])
+			continue
+		}
+
+		if res["poe-out-status"] == "powered-on" {
+			poe.status.With(prometheus.Labels{"name": name, "poe_out": res["poe-out"], "poe_priority": iface["poe-priority"]}).Set(1)
 		} else {
-			name = iface["name"]
+			poe.status.With(prometheus.Labels{"name": name, "poe_out": res["poe-out"], "poe_priority": iface["poe-priority"]}).Set(0)
 		}
 
-		// Get status
-		if id, ok := iface[".id"]; ok {
-			// Ethernet monitor
-			res, err := mikrotik.Monitor(poe.path, mikrotik.Ctx(ctx), map[string]string{
-				"numbers": id,
-				"once":    "",
-			})
+		if curr, ok := res["poe-out-current"]; ok {
+			f, err := strconv.ParseFloat(curr, 64)
 			if err != nil {
-				return fmt.Errorf("reading resource: %w", err)
-			}
-			if len(res) == 0 {
-				logger.Warn().Msgf("monitor empty response: %v", id)
+				logger.Warn().Fields(map[string]any{"poe-out-current": curr}).Err(err).Msg("extracting value from resource")
 				continue
 			}
 
-			// PoE monitor
-			res, err = mikrotik.Monitor(poe.path, mikrotik.Ctx(ctx), map[string]string{
-				"numbers": id,
-				"once":    "",
-			})
+			poe.outputCurrent.With(prometheus.Labels{"name": name}).Set(f)
+		} else {
+			poe.outputCurrent.With(prometheus.Labels{"name": name}).Set(0)
+		}
+
+		if curr, ok := res["poe-out-power"]; ok {
+			f, err := strconv.ParseFloat(curr, 64)
 			if err != nil {
-				return fmt.Errorf("reading resource: %w", err)
-			}
-			if len(res) == 0 {
-				logger.Warn().Msgf("monitor empty response: %v", id)
+				logger.Warn().Fields(map[string]any{"poe-out-power": curr}).Err(err).Msg("extracting value from resource")
 				continue
 			}
 
-			if res[0]["poe-out-status"] == "powered-on" {
-				poe.status.With(prometheus.Labels{"name": name, "poe_out": res[0]["poe-out"], "poe_priority": iface["poe-priority"]}).Set(1)
-			} else {
-				poe.status.With(prometheus.Labels{"name": name, "poe_out": res[0]["poe-out"], "poe_priority": iface["poe-priority"]}).Set(0)
-			}
-
-			if curr, ok := res[0]["poe-out-current"]; ok {
-				f, err := strconv.ParseFloat(curr, 64)
-				if err != nil {
-					logger.Warn().Fields(map[string]any{"poe-out-current": curr}).Err(err).Msg("extracting value from resource")
-					continue
-				}
-
-				poe.outputCurrent.With(prometheus.Labels{"name": name}).Set(f)
-			} else {
-				poe.outputCurrent.With(prometheus.Labels{"name": name}).Set(0)
-			}
-
-			if curr, ok := res[0]["poe-out-power"]; ok {
-				f, err := strconv.ParseFloat(curr, 64)
-				if err != nil {
-					logger.Warn().Fields(map[string]any{"poe-out-power": curr}).Err(err).Msg("extracting value from resource")
-					continue
-				}
+			poe.outputPower.With(prometheus.Labels{"name": name}).Set(f)
+		} else {
+			poe.outputPower.With(prometheus.Labels{"name": name}).Set(0)
+		}
 
-				poe.outputPower.With(prometheus.Labels{"name": name}).Set(f)
-			} else {
-				poe.outputPower.With(prometheus.Labels{"name": name}).Set(0)
+		if curr, ok := res["poe-out-voltage"]; ok {
+			f, err := strconv.ParseFloat(curr, 64)
+			if err != nil {
+				logger.Warn().Fields(map[string]any{"poe-out-voltage": curr}).Err(err).Msg("extracting value from resource")
+				continue
 			}
 
-			if curr, ok := res[0]["poe-out-voltage"]; ok {
-				f, err := strconv.ParseFloat(curr, 64)
-				if err != nil {
-					logger.Warn().Fields(map[string]any{"poe-out-voltage": curr}).Err(err).Msg("extracting value from resource")
-					continue
-				}
-
-				poe.outputVoltage.With(prometheus.Labels{"name": name}).Set(f)
-			} else {
-				poe.outputVoltage.With(prometheus.Labels{"name": name}).Set(0)
-			}
+			poe.outputVoltage.With(prometheus.Labels{"name": name}).Set(f)
+		} else {
+			poe.outputVoltage.With(prometheus.Labels{"name": name}).Set(0)
 		}
-
 	}
 
 	return nil
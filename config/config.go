@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+	"github.com/vaerh/mikrotik-prom-exporter/output"
+	"gopkg.in/yaml.v3"
+)
+
+// Config Exporter configuration file.
+//
+//	schemas:                # settings for all routers
+//	  interface:
+//	    exclude_rows:
+//	      - field: dynamic
+//	routers:
+//	  Sample-Router:        # router alias, overrides the settings above
+//	    password_file: /run/secrets/sample-router
+//	    schemas:
+//	      interface:
+//	        include_rows:
+//	          - field: name
+//	            regex: '^ether'
+//	global_vars:            # global variables read from the router
+//	  FIRMWARE:
+//	    resource_path: /system/routerboard
+//	    field: current-firmware
+//	instances:              # template schema instances
+//	  blocklist:
+//	    schema: table_counter
+//	    params:
+//	      resource_path: /ip/firewall/address-list
+//	      filter_field: list
+//	      filter_value: blocklist
+//	remote_write:           # push the metrics to a Prometheus remote write receiver
+//	  url: https://prometheus.example.com/api/v1/write
+//	  external_labels:
+//	    site: branch
+//	otlp:                   # push the metrics to an OpenTelemetry collector
+//	  protocol: grpc
+//	  endpoint: otel-collector:4317
+type Config struct {
+	Router `yaml:",inline"`
+	// Routers Per router settings, the key is the router alias
+	Routers map[string]Router `yaml:"routers,omitempty"`
+}
+
+// Router Settings that can be overridden for a particular router.
+type Router struct {
+	// PasswordFile File with the router password, used if the password is not set by the flags
+	PasswordFile string `yaml:"password_file,omitempty"`
+	// Schemas Schema settings, the key is the schema name
+	Schemas map[string]SchemaOverride `yaml:"schemas,omitempty"`
+	// Collectors Complex collectors settings, the key is the collector name
+	Collectors map[string]CollectorOverride `yaml:"collectors,omitempty"`
+	// Instances Template schema instances, the key is the instance name
+	Instances map[string]exporter.SchemaInstance `yaml:"instances,omitempty"`
+	// GlobalVars Global variables read from the router, the key is the variable name
+	GlobalVars map[string]exporter.GlobalVarSource `yaml:"global_vars,omitempty"`
+	// RemoteWrite Pushes the metrics to a Prometheus remote write receiver, the router section replaces the common one
+	RemoteWrite *output.RemoteWriteConfig `yaml:"remote_write,omitempty"`
+	// OTLP Pushes the metrics to an OpenTelemetry collector, the router section replaces the common one
+	OTLP *output.OTLPConfig `yaml:"otlp,omitempty"`
+	// InfluxDB Writes the schema rows to InfluxDB, the router section replaces the common one
+	InfluxDB *output.InfluxConfig `yaml:"influxdb,omitempty"`
+}
+
+// SchemaOverride Schema settings overridden from the configuration.
+// Unset fields keep the values from the schema file.
+type SchemaOverride struct {
+	Include  []*exporter.RowRule `yaml:"include_rows,omitempty"`
+	Exclude  []*exporter.RowRule `yaml:"exclude_rows,omitempty"`
+	Interval time.Duration       `yaml:"interval,omitempty"`
+	Timeout  time.Duration       `yaml:"timeout,omitempty"`
+}
+
+// CollectorOverride Complex collector settings.
+type CollectorOverride struct {
+	// Enabled Enables or disables the collector, the --collector.<name> flags take precedence
+	Enabled *bool `yaml:"enabled,omitempty"`
+	// Interval Collection interval, the --interval flag value by default
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// Load Reads the configuration file.
+func Load(fileName string) (*Config, error) {
+	bytes, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file '%v', %v", fileName, err)
+	}
+
+	var res Config
+	if err := yaml.Unmarshal(bytes, &res); err != nil {
+		return nil, fmt.Errorf("unmarshalling config file '%s': %w", fileName, err)
+	}
+
+	if err := res.validate(); err != nil {
+		return nil, fmt.Errorf("config file '%s': %w", fileName, err)
+	}
+
+	return &res, nil
+}
+
+// validate Checks the schema rules of the common and the router settings.
+func (c *Config) validate() error {
+	if err := c.Router.validate(); err != nil {
+		return err
+	}
+	var aliases = make([]string, 0, len(c.Routers))
+	for alias := range c.Routers {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		r := c.Routers[alias]
+		if err := r.validate(); err != nil {
+			return fmt.Errorf("router '%s': %w", alias, err)
+		}
+	}
+	return nil
+}
+
+func (r *Router) validate() error {
+	var names = make([]string, 0, len(r.Schemas))
+	for name := range r.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o := r.Schemas[name]
+		if err := exporter.ValidateRules("include_rows", o.Include); err != nil {
+			return fmt.Errorf("schema '%s': %w", name, err)
+		}
+		if err := exporter.ValidateRules("exclude_rows", o.Exclude); err != nil {
+			return fmt.Errorf("schema '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// ForRouter Returns the router settings merged with the common ones.
+func (c *Config) ForRouter(alias string) *Router {
+	var res = Router{
+		PasswordFile: c.PasswordFile,
+		RemoteWrite:  c.RemoteWrite,
+		OTLP:         c.OTLP,
+		InfluxDB:     c.InfluxDB,
+		Schemas:      make(map[string]SchemaOverride, len(c.Schemas)),
+		Collectors:   make(map[string]CollectorOverride, len(c.Collectors)),
+		Instances:    make(map[string]exporter.SchemaInstance, len(c.Instances)),
+		GlobalVars:   make(map[string]exporter.GlobalVarSource, len(c.GlobalVars)),
+	}
+
+	for name, s := range c.Schemas {
+		res.Schemas[name] = s
+	}
+	for name, col := range c.Collectors {
+		res.Collectors[name] = col
+	}
+	for name, inst := range c.Instances {
+		res.Instances[name] = inst
+	}
+	for name, v := range c.GlobalVars {
+		res.GlobalVars[name] = v
+	}
+
+	if r, ok := c.Routers[alias]; ok {
+		if r.PasswordFile != "" {
+			res.PasswordFile = r.PasswordFile
+		}
+		if r.RemoteWrite != nil {
+			res.RemoteWrite = r.RemoteWrite
+		}
+		if r.OTLP != nil {
+			res.OTLP = r.OTLP
+		}
+		if r.InfluxDB != nil {
+			res.InfluxDB = r.InfluxDB
+		}
+		for name, s := range r.Schemas {
+			res.Schemas[name] = res.Schemas[name].merge(s)
+		}
+		for name, col := range r.Collectors {
+			res.Collectors[name] = res.Collectors[name].merge(col)
+		}
+		for name, inst := range r.Instances {
+			res.Instances[name] = inst
+		}
+		for name, v := range r.GlobalVars {
+			res.GlobalVars[name] = v
+		}
+	}
+
+	return &res
+}
+
+// ApplySchema Applies the schema overrides.
+func (r *Router) ApplySchema(s *exporter.ResourceSchema) {
+	o, ok := r.Schemas[s.Name]
+	if !ok {
+		return
+	}
+
+	if o.Include != nil {
+		s.Include = o.Include
+	}
+	if o.Exclude != nil {
+		s.Exclude = o.Exclude
+	}
+	if o.Interval != 0 {
+		s.Interval = o.Interval
+	}
+	if o.Timeout != 0 {
+		s.Timeout = o.Timeout
+	}
+}
+
+// CollectorInterval Returns the collector interval from the configuration or the default one.
+func (r *Router) CollectorInterval(name string, defaultInterval time.Duration) time.Duration {
+	if o, ok := r.Collectors[name]; ok && o.Interval != 0 {
+		return o.Interval
+	}
+	return defaultInterval
+}
+
+// GlobalVarSources Returns the default global variables with the configured ones merged in.
+func (r *Router) GlobalVarSources() map[string]exporter.GlobalVarSource {
+	var res = make(map[string]exporter.GlobalVarSource, len(exporter.DefaultGlobalVarSources)+len(r.GlobalVars))
+	for name, v := range exporter.DefaultGlobalVarSources {
+		res[name] = v
+	}
+	for name, v := range r.GlobalVars {
+		res[name] = v
+	}
+	return res
+}
+
+// SchemaInstances Returns the template schema instances sorted by name.
+func (r *Router) SchemaInstances() []exporter.SchemaInstance {
+	var names = make([]string, 0, len(r.Instances))
+	for name := range r.Instances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var res = make([]exporter.SchemaInstance, 0, len(names))
+	for _, name := range names {
+		inst := r.Instances[name]
+		inst.Name = name
+		res = append(res, inst)
+	}
+	return res
+}
+
+// CollectorEnabled Returns the collector state from the configuration or the default one.
+func (r *Router) CollectorEnabled(name string, defaultEnabled bool) bool {
+	if o, ok := r.Collectors[name]; ok && o.Enabled != nil {
+		return *o.Enabled
+	}
+	return defaultEnabled
+}
+
+func (o SchemaOverride) merge(other SchemaOverride) SchemaOverride {
+	if other.Include != nil {
+		o.Include = other.Include
+	}
+	if other.Exclude != nil {
+		o.Exclude = other.Exclude
+	}
+	if other.Interval != 0 {
+		o.Interval = other.Interval
+	}
+	if other.Timeout != 0 {
+		o.Timeout = other.Timeout
+	}
+	return o
+}
+
+func (o CollectorOverride) merge(other CollectorOverride) CollectorOverride {
+	if other.Enabled != nil {
+		o.Enabled = other.Enabled
+	}
+	if other.Interval != 0 {
+		o.Interval = other.Interval
+	}
+	return o
+}
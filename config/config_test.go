@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "valid",
+			config: `
+schemas:
+  interface:
+    exclude_rows:
+      - field: dynamic
+routers:
+  r1:
+    schemas:
+      interface:
+        include_rows:
+          - expr: rx-byte > 0
+`,
+		},
+		{
+			name: "regex",
+			config: `
+schemas:
+  interface:
+    include_rows:
+      - field: name
+        regex: '(ether'
+`,
+			err: "rule regex:",
+		},
+		{
+			name: "empty router rule",
+			config: `
+routers:
+  r1:
+    schemas:
+      interface:
+        exclude_rows:
+          - field: dynamic
+          -
+`,
+			err: "router 'r1': schema 'interface': exclude_rows rule 2 is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(file, []byte(tt.config), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Load(file)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error '%v'", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGlobalVarSources(t *testing.T) {
+	r := &Router{GlobalVars: map[string]exporter.GlobalVarSource{
+		"ROUTER_ID": {Value: "core"},
+		"LOCATION":  {MikrotikResourcePath: "/system/routerboard/settings", Field: "location"},
+	}}
+
+	want := map[string]exporter.GlobalVarSource{
+		"ROUTER_ID":     {Value: "core"},
+		"VERSION":       {MikrotikResourcePath: "/system/resource", Field: "version"},
+		"BOARD_NAME":    {MikrotikResourcePath: "/system/resource", Field: "board-name"},
+		"SERIAL_NUMBER": {MikrotikResourcePath: "/system/routerboard", Field: "serial-number"},
+		"LOCATION":      {MikrotikResourcePath: "/system/routerboard/settings", Field: "location"},
+	}
+	if got := r.GlobalVarSources(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// The defaults are not modified
+	if exporter.DefaultGlobalVarSources["ROUTER_ID"].Value != "" {
+		t.Error("the default ROUTER_ID source is replaced")
+	}
+}
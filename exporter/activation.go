@@ -0,0 +1,228 @@
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+const DefaultSystemInfoCheckInterval = time.Minute
+
+// SystemInfoWatcher Keeps the router system information the schema requirements are evaluated against.
+// The information is read at startup and again when the router becomes reachable after a failure,
+// reboots or changes its version.
+type SystemInfoWatcher struct {
+	mu         sync.RWMutex
+	info       *mikrotik.SystemInfo
+	generation uint64
+	reachable  bool
+}
+
+func NewSystemInfoWatcher() *SystemInfoWatcher {
+	return &SystemInfoWatcher{}
+}
+
+// Info Returns the last known system information and its generation, which changes with the information.
+func (w *SystemInfoWatcher) Info() (*mikrotik.SystemInfo, uint64) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.info, w.generation
+}
+
+// Reachable Returns true if the last check of the router succeeded.
+func (w *SystemInfoWatcher) Reachable() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.reachable
+}
+
+// Refresh Reads the system information. Returns true if it has changed.
+func (w *SystemInfoWatcher) Refresh(ctx context.Context) (bool, error) {
+	info, err := mikrotik.ReadSystemInfo(mikrotik.Ctx(ctx))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err != nil {
+		w.reachable = false
+		return false, err
+	}
+	w.reachable = true
+
+	changed := w.info == nil || !w.info.Equal(info)
+	if changed {
+		w.generation++
+	}
+	w.info = info
+
+	return changed, nil
+}
+
+// Watch Checks the router every interval until the context is cancelled.
+func (w *SystemInfoWatcher) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.check(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *SystemInfoWatcher) check(ctx context.Context) {
+	logger := zerolog.Ctx(ctx)
+
+	res, err := mikrotik.Read("/system/resource", mikrotik.Ctx(ctx), nil)
+
+	w.mu.Lock()
+	if err != nil || len(res) == 0 {
+		w.reachable = false
+		w.mu.Unlock()
+		return
+	}
+
+	uptime, _ := mikrotik.ParseDuration(res[0]["uptime"])
+	refresh := !w.reachable || w.info == nil || uptime < w.info.Uptime
+	if !refresh {
+		w.info.Uptime = uptime
+	}
+	w.mu.Unlock()
+
+	if !refresh {
+		return
+	}
+
+	changed, err := w.Refresh(ctx)
+	if err != nil {
+		logger.Warn().Err(err).Msg("refreshing router system information")
+		return
+	}
+	if changed {
+		logger.Info().Msg("router system information changed, re-evaluating schema and collector requirements")
+	}
+}
+
+// RequirementsGate Evaluates the requirements of a schema or collector before every collection cycle.
+// They are evaluated again only when the router system information changes. A nil gate is always active.
+type RequirementsGate struct {
+	kind, name string
+	requires   *mikrotik.Requirements
+	sysInfo    *SystemInfoWatcher
+	onChange   func(active bool)
+
+	checked    bool
+	generation uint64
+	err        error
+}
+
+// NewRequirementsGate Creates the gate of the schema or collector, kind is StatusKindSchema or StatusKindCollector.
+func NewRequirementsGate(kind, name string, requires *mikrotik.Requirements, sysInfo *SystemInfoWatcher) *RequirementsGate {
+	return &RequirementsGate{kind: kind, name: name, requires: requires, sysInfo: sysInfo}
+}
+
+// OnChange Sets the function called when the requirements stop or start being satisfied,
+// e.g. to remove the metrics of the inactive schema.
+func (g *RequirementsGate) OnChange(fn func(active bool)) {
+	g.onChange = fn
+}
+
+// Active Returns true if the requirements are satisfied. Temporary errors keep the gate active,
+// the collection reports the error if it persists.
+func (g *RequirementsGate) Active(ctx context.Context) bool {
+	if g == nil || g.sysInfo == nil || g.requires.IsEmpty() {
+		return true
+	}
+
+	info, generation := g.sysInfo.Info()
+	if g.checked && generation == g.generation {
+		return g.err == nil
+	}
+
+	logger := zerolog.Ctx(ctx).With().Str(g.kind, g.name).Logger()
+
+	err := g.requires.Check(ctx, info)
+	if mikrotik.IsTemporary(err) {
+		// Checked again on the next cycle
+		logger.Warn().Err(err).Msgf("checking %s requirements", g.kind)
+		return true
+	}
+
+	switch {
+	case err != nil && (!g.checked || g.err == nil):
+		logger.Info().Msgf("skipping %s: %v", g.kind, err)
+		if g.onChange != nil {
+			g.onChange(false)
+		}
+	case err == nil && g.checked && g.err != nil:
+		logger.Info().Msgf("enabling %s", g.kind)
+		if g.onChange != nil {
+			g.onChange(true)
+		}
+	}
+
+	g.err, g.generation, g.checked = err, generation, true
+
+	return err == nil
+}
+
+type requirementsGateKey struct{}
+
+// WithContext Returns a copy of the context carrying the gate.
+func (g *RequirementsGate) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requirementsGateKey{}, g)
+}
+
+// RequirementsGateCtx Returns the gate stored in the context or nil.
+func RequirementsGateCtx(ctx context.Context) *RequirementsGate {
+	g, _ := ctx.Value(requirementsGateKey{}).(*RequirementsGate)
+	return g
+}
+
+// isActive Returns true if the schema requirements are satisfied and its resource exists.
+// The requirements are evaluated again only when the router system information changes.
+func (r *ResourceExporter) isActive(ctx context.Context) bool {
+	if r.sysInfo == nil {
+		return true
+	}
+
+	if r.notFound {
+		if _, generation := r.sysInfo.Info(); generation == r.notFoundGeneration {
+			return false
+		}
+		zerolog.Ctx(ctx).Info().Str("schema", r.schema.Name).Msg("router system information changed, enabling schema")
+		r.notFound = false
+	}
+
+	return r.requirements.Active(ctx)
+}
+
+// disableNotFound Disables the schema whose resource or command doesn't exist on the router,
+// e.g. its package is not installed, until the router system information changes.
+func (r *ResourceExporter) disableNotFound(ctx context.Context, err error) {
+	if r.sysInfo == nil {
+		return
+	}
+
+	zerolog.Ctx(ctx).Info().Str("schema", r.schema.Name).Msgf("disabling schema until the router changes: %v", err)
+	_, r.notFoundGeneration = r.sysInfo.Info()
+	r.notFound = true
+	r.resetMetrics()
+}
+
+// SetSystemInfo Sets the router system information the schema requirements are evaluated against.
+func (r *ResourceExporter) SetSystemInfo(w *SystemInfoWatcher) {
+	r.sysInfo = w
+	r.requirements = NewRequirementsGate(StatusKindSchema, r.schema.Name, &r.schema.Requires, w)
+	r.requirements.OnChange(func(active bool) {
+		if !active {
+			r.resetMetrics()
+		}
+	})
+}
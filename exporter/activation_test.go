@@ -0,0 +1,165 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// setSystemInfo Sets the system information as a refresh from the router would.
+func setSystemInfo(w *SystemInfoWatcher, info *mikrotik.SystemInfo) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.info == nil || !w.info.Equal(info) {
+		w.generation++
+	}
+	w.info, w.reachable = info, true
+}
+
+func TestRequirementsGate(t *testing.T) {
+	ctx := context.Background()
+	w := NewSystemInfoWatcher()
+	setSystemInfo(w, &mikrotik.SystemInfo{Version: "7.14.2", Packages: map[string]string{"routeros": "7.14.2"}})
+
+	gate := NewRequirementsGate(StatusKindCollector, "wifi", &mikrotik.Requirements{Version: ">=7.15", Packages: []string{"wifi-qcom"}}, w)
+	var changes []bool
+	gate.OnChange(func(active bool) { changes = append(changes, active) })
+
+	if gate.Active(ctx) {
+		t.Error("active on an old version")
+	}
+	// Not evaluated again until the information changes
+	gate.requires = &mikrotik.Requirements{Version: ">=7"}
+	if gate.Active(ctx) {
+		t.Error("active without a system information change")
+	}
+	gate.requires = &mikrotik.Requirements{Version: ">=7.15", Packages: []string{"wifi-qcom"}}
+
+	// The router is upgraded, the package is still missing
+	setSystemInfo(w, &mikrotik.SystemInfo{Version: "7.15", Packages: map[string]string{"routeros": "7.15"}})
+	if gate.Active(ctx) {
+		t.Error("active without the package")
+	}
+
+	setSystemInfo(w, &mikrotik.SystemInfo{Version: "7.15", Packages: map[string]string{"routeros": "7.15", "wifi-qcom": "7.15"}})
+	if !gate.Active(ctx) {
+		t.Error("inactive with the requirements satisfied")
+	}
+
+	// Downgrade
+	setSystemInfo(w, &mikrotik.SystemInfo{Version: "7.14.3", Packages: map[string]string{"routeros": "7.14.3", "wifi-qcom": "7.14.3"}})
+	if gate.Active(ctx) {
+		t.Error("active after a downgrade")
+	}
+
+	// Changes are reported once per transition
+	if want := []bool{false, true, false}; len(changes) != len(want) || changes[0] || !changes[1] || changes[2] {
+		t.Errorf("got changes %v, want %v", changes, want)
+	}
+}
+
+func TestRequirementsGateAlwaysActive(t *testing.T) {
+	ctx := context.Background()
+
+	var gate *RequirementsGate
+	if !gate.Active(ctx) {
+		t.Error("nil gate is inactive")
+	}
+	if !RequirementsGateCtx(ctx).Active(ctx) {
+		t.Error("context without a gate is inactive")
+	}
+
+	// Without the system information watcher
+	gate = NewRequirementsGate(StatusKindSchema, "test", &mikrotik.Requirements{Version: ">=8"}, nil)
+	if !gate.Active(ctx) {
+		t.Error("gate without the watcher is inactive")
+	}
+
+	// Without requirements
+	w := NewSystemInfoWatcher()
+	gate = NewRequirementsGate(StatusKindSchema, "test", &mikrotik.Requirements{}, w)
+	if !gate.Active(gate.WithContext(ctx)) {
+		t.Error("gate without requirements is inactive")
+	}
+	if RequirementsGateCtx(gate.WithContext(ctx)) != gate {
+		t.Error("gate is not stored in the context")
+	}
+}
+
+func TestSchemaRequirements(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/interface/wifi", mikrotik.MikrotikItem{"name": "wifi1", "running": "true"})
+
+	r, reg := newTestExporter(t, `
+name: wifi
+subsystem: wifi
+resource_path: /interface/wifi
+requires:
+  version: '>=7.13'
+metrics:
+  - name: running
+    type: GaugeVec
+    field: running
+    field_type: bool
+    labels: {name: $name}
+`)
+	w := NewSystemInfoWatcher()
+	r.SetSystemInfo(w)
+
+	setSystemInfo(w, &mikrotik.SystemInfo{Version: "7.14"})
+	r.collect(ctx)
+	if got := gaugeValues(t, reg, "mikrotik_wifi_running", "name"); got["wifi1"] != 1 {
+		t.Fatalf("metrics %v", got)
+	}
+
+	// The metrics are removed when the requirements stop being satisfied
+	setSystemInfo(w, &mikrotik.SystemInfo{Version: "7.12"})
+	r.collect(ctx)
+	if got := gaugeValues(t, reg, "mikrotik_wifi_running", "name"); len(got) != 0 {
+		t.Errorf("inactive schema metrics %v", got)
+	}
+	if n := router.count("GET", "/interface/wifi"); n != 1 {
+		t.Errorf("inactive schema read the resource, %d reads", n)
+	}
+
+	setSystemInfo(w, &mikrotik.SystemInfo{Version: "7.13"})
+	r.collect(ctx)
+	if got := gaugeValues(t, reg, "mikrotik_wifi_running", "name"); got["wifi1"] != 1 {
+		t.Errorf("metrics %v after the upgrade", got)
+	}
+}
+
+func TestSchemaRequirementsActive(t *testing.T) {
+	info := &mikrotik.SystemInfo{Version: "7.15rc2", BoardName: "CCR2004-1G-12S+2XS", Packages: map[string]string{"routeros": "7.15rc2"}}
+
+	tests := []struct {
+		requires string
+		want     bool
+	}{
+		{requires: "{}", want: true},
+		{requires: "{version: '>=7'}", want: true},
+		{requires: "{version: '>=7.15'}", want: false},
+		{requires: "{version: '>=7.15rc1, <8'}", want: true},
+		{requires: "{packages: [routeros]}", want: true},
+		{requires: "{packages: [routeros, wireless]}", want: false},
+		{requires: "{board: ^CCR}", want: true},
+		{requires: "{board: ^CRS, version: '>=7'}", want: false},
+	}
+
+	for _, tt := range tests {
+		s, err := parseTestSchema(t, "resource_path: /interface\nrequires: "+tt.requires+"\nmetrics: []")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewResourceExporter(context.Background(), s, nil, prom.NewRegistry())
+		w := NewSystemInfoWatcher()
+		setSystemInfo(w, info)
+		r.SetSystemInfo(w)
+
+		if got := r.isActive(context.Background()); got != tt.want {
+			t.Errorf("requires %s: active %v, want %v", tt.requires, got, tt.want)
+		}
+	}
+}
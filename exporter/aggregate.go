@@ -0,0 +1,146 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	AggSum           = "sum"
+	AggMin           = "min"
+	AggMax           = "max"
+	AggAvg           = "avg"
+	AggCount         = "count"
+	AggCountDistinct = "count_distinct"
+)
+
+// Aggregation Aggregates the metric values of all rows into one series per group.
+//
+//	aggregate:
+//	  by: [chain]   # label names or Mikrotik fields
+//	  func: sum     # sum, min, max, avg, count, count_distinct
+type Aggregation struct {
+	// By Labels or Mikrotik fields to group the rows by, all rows form one group if empty
+	By []string `yaml:"by,omitempty"`
+	// Func Aggregation function
+	Func string `yaml:"func"`
+}
+
+func (a *Aggregation) validate() error {
+	switch strings.ToLower(a.Func) {
+	case AggSum, AggMin, AggMax, AggAvg, AggCount, AggCountDistinct:
+		return nil
+	}
+	return fmt.Errorf("unknown aggregation function '%s'", a.Func)
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// labelName Converts the Mikrotik field name to a valid label name.
+func labelName(field string) string {
+	return invalidLabelChars.ReplaceAllString(strings.TrimPrefix(field, "."), "_")
+}
+
+// groupLabels Returns the labels of the aggregated series.
+// An entry of 'by' refers to a metric label or, if there is no such label, to a Mikrotik field.
+func (a *Aggregation) groupLabels(labels map[string]*LabelSpec) map[string]*LabelSpec {
+	var res = make(map[string]*LabelSpec, len(a.By))
+	for _, by := range a.By {
+		if l, ok := labels[by]; ok {
+			res[by] = l
+		} else {
+			res[labelName(by)] = &LabelSpec{Field: by}
+		}
+	}
+	return res
+}
+
+// aggregateCollector Exposes the values aggregated over all rows of one collection cycle.
+type aggregateCollector struct {
+	*snapshotCollector
+	fn        string
+	valueType prom.ValueType
+	// pending Data of the current collection cycle, accessed only by the collecting goroutine
+	pending map[string]*aggregateData
+}
+
+type aggregateData struct {
+	labelValues []string
+	count       uint64
+	sum         float64
+	min, max    float64
+	distinct    map[string]struct{}
+}
+
+func newAggregateCollector(fqName, help string, labelNames []string, constLabels prom.Labels, fn string, valueType prom.ValueType) *aggregateCollector {
+	return &aggregateCollector{
+		snapshotCollector: newSnapshotCollector(fqName, help, labelNames, constLabels),
+		fn:                strings.ToLower(fn),
+		valueType:         valueType,
+	}
+}
+
+// begin Starts a new collection cycle.
+func (a *aggregateCollector) begin() {
+	a.pending = make(map[string]*aggregateData)
+}
+
+// needsValue Returns true if the aggregation function uses the parsed metric value.
+func (a *aggregateCollector) needsValue() bool {
+	return a.fn != AggCount && a.fn != AggCountDistinct
+}
+
+// observe Adds the row to its group. The key is used only by count_distinct.
+func (a *aggregateCollector) observe(labels prom.Labels, v float64, key string) {
+	values := a.labelValues(labels)
+	k := labelsKey(values)
+
+	d, ok := a.pending[k]
+	if !ok {
+		d = &aggregateData{labelValues: values, min: math.Inf(1), max: math.Inf(-1)}
+		if a.fn == AggCountDistinct {
+			d.distinct = make(map[string]struct{})
+		}
+		a.pending[k] = d
+	}
+
+	d.count++
+	d.sum += v
+	d.min = math.Min(d.min, v)
+	d.max = math.Max(d.max, v)
+	if d.distinct != nil {
+		d.distinct[key] = struct{}{}
+	}
+}
+
+// commit Replaces the exposed series with the data of the current cycle.
+func (a *aggregateCollector) commit() {
+	var metrics = make([]prom.Metric, 0, len(a.pending))
+	for _, key := range sortedKeys(a.pending) {
+		d := a.pending[key]
+
+		var v float64
+		switch a.fn {
+		case AggSum:
+			v = d.sum
+		case AggMin:
+			v = d.min
+		case AggMax:
+			v = d.max
+		case AggAvg:
+			v = d.sum / float64(d.count)
+		case AggCount:
+			v = float64(d.count)
+		case AggCountDistinct:
+			v = float64(len(d.distinct))
+		}
+
+		metrics = append(metrics, prom.MustNewConstMetric(a.desc, a.valueType, v, d.labelValues...))
+	}
+	a.set(metrics)
+	a.pending = nil
+}
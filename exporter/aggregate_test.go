@@ -0,0 +1,312 @@
+package exporter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+func TestAggregateCollector(t *testing.T) {
+	type row struct {
+		chain string
+		value float64
+		key   string
+	}
+	rows := []row{
+		{"input", 10, "a"},
+		{"input", 30, "b"},
+		{"input", 20, "a"},
+		{"forward", -5, "c"},
+	}
+
+	tests := []struct {
+		fn        string
+		valueType prom.ValueType
+		want      string
+	}{
+		{fn: AggSum, want: `forward -5, input 60`},
+		{fn: AggMin, want: `forward -5, input 10`},
+		{fn: AggMax, want: `forward -5, input 30`},
+		{fn: AggAvg, want: `forward -5, input 20`},
+		{fn: AggCount, want: `forward 1, input 3`},
+		{fn: AggCountDistinct, want: `forward 1, input 2`},
+		{fn: "SUM", valueType: prom.CounterValue, want: `forward -5, input 60`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fn, func(t *testing.T) {
+			valueType, typeName := prom.GaugeValue, "gauge"
+			if tt.valueType == prom.CounterValue {
+				valueType, typeName = tt.valueType, "counter"
+			}
+			a := newAggregateCollector("mikrotik_ip_firewall_bytes", "Bytes per chain", []string{"chain"}, nil, tt.fn, valueType)
+
+			a.begin()
+			for _, r := range rows {
+				a.observe(prom.Labels{"chain": r.chain}, r.value, r.key)
+			}
+			a.commit()
+
+			var want = fmt.Sprintf("# HELP mikrotik_ip_firewall_bytes Bytes per chain\n# TYPE mikrotik_ip_firewall_bytes %s\n", typeName)
+			for _, s := range strings.Split(tt.want, ", ") {
+				chain, v, _ := strings.Cut(s, " ")
+				want += fmt.Sprintf("mikrotik_ip_firewall_bytes{chain=%q} %s\n", chain, v)
+			}
+			if err := testutil.CollectAndCompare(a, strings.NewReader(want)); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAggregateCollectorCycles(t *testing.T) {
+	a := newAggregateCollector("mikrotik_ip_pool_used", "Used addresses", []string{"pool"}, nil, AggCount, prom.GaugeValue)
+
+	a.begin()
+	a.observe(prom.Labels{"pool": "dhcp"}, 0, "")
+	a.observe(prom.Labels{"pool": "vpn"}, 0, "")
+	a.commit()
+
+	// The series of the previous cycle are exposed until the current one is committed
+	a.begin()
+	a.observe(prom.Labels{"pool": "dhcp"}, 0, "")
+	a.observe(prom.Labels{"pool": "dhcp"}, 0, "")
+	want := `
+# HELP mikrotik_ip_pool_used Used addresses
+# TYPE mikrotik_ip_pool_used gauge
+mikrotik_ip_pool_used{pool="dhcp"} 1
+mikrotik_ip_pool_used{pool="vpn"} 1
+`
+	if err := testutil.CollectAndCompare(a, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// The pool without used addresses is removed
+	a.commit()
+	want = `
+# HELP mikrotik_ip_pool_used Used addresses
+# TYPE mikrotik_ip_pool_used gauge
+mikrotik_ip_pool_used{pool="dhcp"} 2
+`
+	if err := testutil.CollectAndCompare(a, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAggregateSchema(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/ip/firewall/filter",
+		mikrotik.MikrotikItem{"chain": "input", "bytes": "100", "src-address": "10.0.0.1"},
+		mikrotik.MikrotikItem{"chain": "input", "bytes": "300", "src-address": "10.0.0.2"},
+		mikrotik.MikrotikItem{"chain": "input", "bytes": "x", "src-address": "10.0.0.1"},
+		mikrotik.MikrotikItem{"chain": "forward", "bytes": "50", "src-address": "10.0.0.1"},
+	)
+
+	r, reg := newTestExporter(t, `
+subsystem: ip_firewall
+resource_path: /ip/firewall/filter
+metrics:
+  - name: bytes_total
+    help: Bytes per chain
+    type: CounterVec
+    field: bytes
+    field_type: int
+    labels:
+      comment: $comment
+    aggregate:
+      by: [chain]
+      func: sum
+  - name: sources_count
+    help: Distinct source addresses
+    type: GaugeVec
+    field: src-address
+    aggregate:
+      func: count_distinct
+`)
+	if _, err := r.exportMetrics(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the grouping labels are left, the unparsable value is skipped
+	want := `
+# HELP mikrotik_ip_firewall_bytes_total Bytes per chain
+# TYPE mikrotik_ip_firewall_bytes_total counter
+mikrotik_ip_firewall_bytes_total{chain="forward",routerboard_address="router"} 50
+mikrotik_ip_firewall_bytes_total{chain="input",routerboard_address="router"} 400
+# HELP mikrotik_ip_firewall_sources_count Distinct source addresses
+# TYPE mikrotik_ip_firewall_sources_count gauge
+mikrotik_ip_firewall_sources_count{routerboard_address="router"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
+
+// seriesValues Returns the values of all gathered series keyed by the metric name and labels.
+func seriesValues(t *testing.T, reg prom.Gatherer) map[string]float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res = make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.GetMetric() {
+			var labels []string
+			for k, v := range metricLabels(m) {
+				labels = append(labels, k+"="+v)
+			}
+			sort.Strings(labels)
+			res[f.GetName()+"{"+strings.Join(labels, ",")+"}"] = m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return res
+}
+
+// TestAggregateShippedSchemas The shipped schemas counted rows with Inc or Add and reset_gauge
+// before aggregations were added. The aggregations must expose the same series.
+func TestAggregateShippedSchemas(t *testing.T) {
+	tests := []struct {
+		file   string
+		legacy string
+		path   string
+		cycles [][]mikrotik.MikrotikItem
+	}{
+		{
+			file: "ip_pool_used.yaml",
+			legacy: `
+subsystem: ip
+resource_path: /ip/pool/used
+global_labels:
+  pool: $pool
+metrics:
+  - name: pool_used
+    help: Number of used addresses per IP pool
+    type: GaugeVec
+    operation: Inc
+    reset_gauge: true
+`,
+			path: "/ip/pool/used",
+			cycles: [][]mikrotik.MikrotikItem{
+				{{"pool": "dhcp", "address": "10.0.0.2"}, {"pool": "dhcp", "address": "10.0.0.3"}, {"pool": "vpn", "address": "10.1.0.2"}},
+				{{"pool": "dhcp", "address": "10.0.0.2"}},
+				{},
+			},
+		},
+		{
+			file: "ip_dhcp_server_lease.yaml",
+			legacy: `
+subsystem: ip
+resource_path: /ip/dhcp-server/lease
+global_labels:
+  server: $server
+metrics:
+  - name: dhcp_lease_active_count
+    help: Number of active leases per DHCP server
+    type: GaugeVec
+    operation: Inc
+    reset_gauge: true
+`,
+			path: "/ip/dhcp-server/lease",
+			cycles: [][]mikrotik.MikrotikItem{
+				{{"server": "lan", "address": "10.0.0.2"}, {"server": "lan", "address": "10.0.0.3"}, {"server": "guest", "address": "10.2.0.2"}},
+				{{"server": "guest", "address": "10.2.0.2"}},
+			},
+		},
+		{
+			file: "ip_routes_by_family.yaml",
+			legacy: `
+subsystem: ip
+resource_path: /ip/route
+metrics:
+  - name: routes_connect_total
+    help: Number of connect routes in RIB
+    type: GaugeVec
+    field: connect
+    field_type: bool
+    operation: Add
+    reset_gauge: true
+    labels:
+      protocol: connect
+  - name: routes_static_total
+    help: Number of static routes in RIB
+    type: GaugeVec
+    field: static
+    field_type: bool
+    operation: Add
+    reset_gauge: true
+    labels:
+      protocol: static
+  - name: routes_bgp_total
+    help: Number of bgp routes in RIB
+    type: GaugeVec
+    field: bgp
+    field_type: bool
+    operation: Add
+    reset_gauge: true
+    labels:
+      protocol: bgp
+resource_filter:
+  active: yes
+`,
+			path: "/ip/route",
+			cycles: [][]mikrotik.MikrotikItem{
+				{
+					{"active": "yes", "connect": "true", "static": "false", "bgp": "false"},
+					{"active": "no", "connect": "false", "static": "false", "bgp": "true"},
+					{"active": "yes", "connect": "false", "static": "true", "bgp": "false"},
+					{"active": "yes", "connect": "false", "static": "true", "bgp": "false"},
+				},
+				{{"active": "yes", "connect": "true", "static": "false", "bgp": "false"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			router, ctx := newTestRouter(t)
+
+			shipped, err := SchemaParser("../resources/" + tt.file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reg := prom.NewRegistry()
+			r := NewResourceExporter(ctx, shipped, prom.Labels{TargetLabel: "router"}, reg)
+			legacy, legacyReg := newTestExporter(t, tt.legacy)
+
+			// Other metrics of the shipped schema are not compared
+			var names = make(map[string]bool)
+			for _, m := range legacy.schema.Metrics {
+				names[prom.BuildFQName(legacy.schema.PromNamespace, legacy.schema.PromSubsystem, m.PromMetricName)] = true
+			}
+
+			for i, rows := range tt.cycles {
+				router.set(tt.path, rows...)
+				if _, err := legacy.exportMetrics(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := r.exportMetrics(ctx); err != nil {
+					t.Fatal(err)
+				}
+
+				want := seriesValues(t, legacyReg)
+				var got = make(map[string]float64)
+				for k, v := range seriesValues(t, reg) {
+					if name, _, _ := strings.Cut(k, "{"); names[name] {
+						got[k] = v
+					}
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("cycle %d: got %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
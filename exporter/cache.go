@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+const DefaultResourceCacheTTL = 5 * time.Second
+
+// ResourceCache Shares resource reads between exporters.
+// A resource read within TTL of the previous read with the same path and filter is served from the cache.
+// Cached rows are shared and must not be modified.
+type ResourceCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	mu      sync.Mutex
+	expires time.Time
+	items   []mikrotik.MikrotikItem
+}
+
+func NewResourceCache(ttl time.Duration) *ResourceCache {
+	return &ResourceCache{
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Read Reads the resource or returns the cached rows.
+// Concurrent reads of the same resource wait for the single request to the router.
+func (c *ResourceCache) Read(ctx context.Context, resourcePath string, resourceFilter map[string]string) ([]mikrotik.MikrotikItem, error) {
+	key := cacheKey(resourcePath, resourceFilter)
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if time.Now().Before(e.expires) {
+		return e.items, nil
+	}
+
+	items, err := mikrotik.ReadResource(ctx, resourcePath, resourceFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	e.items = items
+	e.expires = time.Now().Add(c.ttl)
+
+	return items, nil
+}
+
+func cacheKey(resourcePath string, resourceFilter map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString(resourcePath)
+	for _, k := range sortedKeys(resourceFilter) {
+		sb.WriteString("\xff" + k + "=" + resourceFilter[k])
+	}
+	return sb.String()
+}
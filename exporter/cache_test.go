@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+func TestResourceCache(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/ip/dhcp-server/lease",
+		mikrotik.MikrotikItem{"server": "lan", "mac-address": "AA"},
+		mikrotik.MikrotikItem{"server": "guest", "mac-address": "BB"},
+	)
+
+	c := NewResourceCache(DefaultResourceCacheTTL)
+	read := func(filter map[string]string) []mikrotik.MikrotikItem {
+		t.Helper()
+		items, err := c.Read(ctx, "/ip/dhcp-server/lease", filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return items
+	}
+
+	if items := read(nil); len(items) != 2 {
+		t.Fatalf("got %d rows", len(items))
+	}
+	router.set("/ip/dhcp-server/lease", mikrotik.MikrotikItem{"server": "lan", "mac-address": "CC"})
+
+	// Within the TTL the rows are served from the cache
+	if items := read(nil); len(items) != 2 {
+		t.Errorf("got %d rows within the TTL", len(items))
+	}
+	if n := router.count("GET", "/ip/dhcp-server/lease"); n != 1 {
+		t.Errorf("got %d requests within the TTL", n)
+	}
+
+	// The filter is a part of the key, the order of its fields doesn't matter
+	if items := read(map[string]string{"server": "lan", "mac-address": "CC"}); len(items) != 1 {
+		t.Errorf("got %d filtered rows", len(items))
+	}
+	read(map[string]string{"mac-address": "CC", "server": "lan"})
+	if n := router.count("GET", "/ip/dhcp-server/lease"); n != 2 {
+		t.Errorf("got %d requests with the filter", n)
+	}
+
+	// Expired
+	c.entries[cacheKey("/ip/dhcp-server/lease", nil)].expires = time.Now()
+	if items := read(nil); len(items) != 1 || items[0]["mac-address"] != "CC" {
+		t.Errorf("got %v after the TTL", items)
+	}
+	if n := router.count("GET", "/ip/dhcp-server/lease"); n != 3 {
+		t.Errorf("got %d requests after the TTL", n)
+	}
+}
+
+func TestResourceCacheConcurrentReads(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/interface", mikrotik.MikrotikItem{"name": "ether1"})
+
+	c := NewResourceCache(time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Read(ctx, "/interface", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := router.count("GET", "/interface"); n != 1 {
+		t.Errorf("got %d requests for concurrent reads", n)
+	}
+}
+
+func TestResourceCacheErrors(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	c := NewResourceCache(time.Minute)
+
+	// Errors are not cached
+	if _, err := c.Read(ctx, "/interface/wifi", nil); err == nil {
+		t.Fatal("no error reading a missing resource")
+	}
+	router.set("/interface/wifi", mikrotik.MikrotikItem{"name": "wifi1"})
+	if items, err := c.Read(ctx, "/interface/wifi", nil); err != nil || len(items) != 1 {
+		t.Errorf("got %v, %v after the error", items, err)
+	}
+}
@@ -0,0 +1,87 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+	"gopkg.in/yaml.v3"
+)
+
+// CommandSpec Resource command executed with 'once' for all listed rows in a single request.
+// In the schema it is either the command name or a mapping:
+//
+//	command: monitor
+//
+//	command:
+//	  name: monitor
+//	  id_field: .id           # field of the listed rows passed to the command
+//	  numbers_arg: numbers    # command argument with the comma-separated list of ids
+//	  args:                   # additional command arguments
+//	    duration: 1s
+//	  include_rows:           # rules selecting the rows after the command
+//	    - field: sfp-module-present
+//
+// The command result fields are merged into the listed rows.
+type CommandSpec struct {
+	Name       string            `yaml:"name"`
+	IdField    string            `yaml:"id_field,omitempty"`
+	NumbersArg string            `yaml:"numbers_arg,omitempty"`
+	Args       map[string]string `yaml:"args,omitempty"`
+	// Include Rules selecting the rows by the command result fields, a row must match any of them (optional)
+	Include []*RowRule `yaml:"include_rows,omitempty"`
+}
+
+// UnmarshalYAML Implements yaml.Unmarshaler to support the short notation.
+func (c *CommandSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = CommandSpec{}
+		return node.Decode(&c.Name)
+	}
+
+	type plain CommandSpec
+	if err := node.Decode((*plain)(c)); err != nil {
+		return err
+	}
+
+	if c.Name == "" {
+		return errors.New("command name is not defined")
+	}
+
+	return nil
+}
+
+// run Runs the command for the rows and returns new rows with the command results merged in.
+// Rows without a command result are dropped.
+func (c *CommandSpec) run(ctx context.Context, resourcePath string, items []mikrotik.MikrotikItem) ([]mikrotik.MikrotikItem, error) {
+	cmd := &mikrotik.BatchCommand{
+		ResourcePath: resourcePath,
+		Command:      c.Name,
+		IdField:      c.IdField,
+		NumbersArg:   c.NumbersArg,
+		Args:         c.Args,
+	}
+
+	out, err := cmd.Run(mikrotik.Ctx(ctx), items)
+	if err != nil {
+		return nil, err
+	}
+
+	var res = make([]mikrotik.MikrotikItem, 0, len(items))
+	for i, item := range items {
+		if out[i] == nil {
+			continue
+		}
+
+		var row = make(mikrotik.MikrotikItem, len(item)+len(out[i]))
+		for k, v := range item {
+			row[k] = v
+		}
+		for k, v := range out[i] {
+			row[k] = v
+		}
+		res = append(res, row)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// gaugeValues Returns the values of the metric family by the label value.
+func gaugeValues(t *testing.T, reg prom.Gatherer, name, label string) map[string]float64 {
+	t.Helper()
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res = make(map[string]float64)
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			res[metricLabels(m)[label]] = m.GetGauge().GetValue() + m.GetCounter().GetValue()
+		}
+	}
+	return res
+}
+
+func TestSFPCommand(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/interface/ethernet",
+		mikrotik.MikrotikItem{".id": "*1", "name": "sfp1", "default-name": "sfp1"},
+		mikrotik.MikrotikItem{".id": "*2", "name": "uplink", "default-name": "sfp-sfpplus2"},
+		mikrotik.MikrotikItem{".id": "*3", "name": "ether1", "default-name": "ether1"},
+		mikrotik.MikrotikItem{".id": "*4", "name": "sfp3", "default-name": "sfp3"},
+	)
+
+	var args []map[string]string
+	router.command("/interface/ethernet/monitor", func(a map[string]string) []mikrotik.MikrotikItem {
+		args = append(args, a)
+		// Returned out of order, the empty cage has no readings
+		return []mikrotik.MikrotikItem{
+			{"name": "sfp3", "sfp-module-present": "false"},
+			{"name": "uplink", "sfp-module-present": "true", "sfp-rx-power": "-5.25", "sfp-tx-power": "-2.1",
+				"sfp-supply-voltage": "3.29", "sfp-tx-bias-current": "7"},
+			{"name": "sfp1", "sfp-module-present": "true", "sfp-rx-power": "-12.5", "sfp-tx-power": "-1.75",
+				"sfp-supply-voltage": "3.31", "sfp-tx-bias-current": "11"},
+		}
+	})
+
+	s, err := SchemaParser("../resources/interface_ethernet_sfp.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := prom.NewRegistry()
+	r := NewResourceExporter(context.Background(), s, prom.Labels{TargetLabel: "router"}, reg)
+
+	rows, err := r.exportMetrics(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 2 {
+		t.Errorf("got %d rows, want 2", rows)
+	}
+
+	// One request for all SFP ports
+	if n := router.count("POST", "/interface/ethernet/monitor"); n != 1 {
+		t.Fatalf("monitor is called %d times", n)
+	}
+	if got := args[0]["numbers"]; got != "*1,*2,*4" {
+		t.Errorf("monitor numbers '%s'", got)
+	}
+
+	tests := map[string]map[string]float64{
+		"mikrotik_interface_sfp_rx_power":        {"sfp1": -12.5, "uplink": -5.25},
+		"mikrotik_interface_sfp_tx_power":        {"sfp1": -1.75, "uplink": -2.1},
+		"mikrotik_interface_sfp_supply_voltage":  {"sfp1": 3.31, "uplink": 3.29},
+		"mikrotik_interface_sfp_tx_bias_current": {"sfp1": 11, "uplink": 7},
+	}
+	for name, want := range tests {
+		got := gaugeValues(t, reg, name, "name")
+		if len(got) != len(want) {
+			t.Errorf("%s: got %v, want %v", name, got, want)
+			continue
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s{name=%s}: got %v, want %v", name, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestCommandSpecYAML(t *testing.T) {
+	s, err := parseTestSchema(t, "resource_path: /interface/ethernet\ncommand: monitor\nmetrics: []")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Command == nil || s.Command.Name != "monitor" {
+		t.Errorf("short notation: command %+v", s.Command)
+	}
+
+	_, err = parseTestSchema(t, "resource_path: /interface/ethernet\ncommand: {args: {duration: 1s}}\nmetrics: []")
+	if err == nil || !strings.Contains(err.Error(), "command name is not defined") {
+		t.Errorf("got error '%v' without a command name", err)
+	}
+}
@@ -0,0 +1,794 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// Expr A compiled metric expression evaluated against a single resource row.
+//
+// Field names are written as is and may contain dashes and dots (total-memory, .id),
+// so binary minus must be separated by spaces: 'total-memory - free-memory'.
+// Supported syntax:
+//
+//	literals:    1, 2.5, 1e6, "text", 'text', true, false
+//	arithmetic:  + - * / %
+//	comparison:  == != < <= > >=
+//	logical:     && || !
+//	conditional: cond ? a : b, a ?? b (b if a is missing or empty)
+//	functions:   default(a, b), has(field), if(cond, a, b), min(a, ...), max(a, ...), abs(a),
+//	             num(a), bool(a), duration(a), quantity(a)
+//
+// Field values are converted to numbers on demand: true/yes/false/no become 1/0,
+// numbers are parsed as floats and RouterOS durations are converted to seconds.
+// quantity() parses values with a unit, e.g. 100Mbps, -62dBm or 12KiB, into the base unit. The multiplier
+// prefix is only removed before a known unit, see quantityUnits, so 3M or 5Mode keep their value.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// ParseExpr Compiles the expression.
+func ParseExpr(src string) (*Expr, error) {
+	p := &exprParser{src: src}
+	if err := p.tokenize(); err != nil {
+		return nil, fmt.Errorf("parsing expression '%s': %w", src, err)
+	}
+
+	root, err := p.parseTernary()
+	if err != nil {
+		return nil, fmt.Errorf("parsing expression '%s': %w", src, err)
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("parsing expression '%s': unexpected '%s' at %d", src, t.text, t.pos)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval Evaluates the expression against the resource row and returns a numeric result.
+func (e *Expr) Eval(item mikrotik.MikrotikItem) (float64, error) {
+	v, err := e.root.eval(item)
+	if err != nil {
+		return 0, err
+	}
+	return v.number()
+}
+
+// Fields Returns the names of all fields referenced by the expression.
+func (e *Expr) Fields() []string {
+	var res []string
+	e.root.walk(func(n exprNode) {
+		if f, ok := n.(*exprField); ok {
+			res = append(res, f.name)
+		}
+	})
+	return res
+}
+
+// exprValue A value produced while evaluating the expression.
+// A missing field is represented by the 'missing' flag.
+type exprValue struct {
+	missing  bool
+	isString bool
+	field    string
+	num      float64
+	str      string
+}
+
+func numValue(f float64) exprValue {
+	return exprValue{num: f}
+}
+
+func boolValue(b bool) exprValue {
+	if b {
+		return exprValue{num: 1}
+	}
+	return exprValue{num: 0}
+}
+
+func (v exprValue) empty() bool {
+	return v.missing || (v.isString && v.str == "")
+}
+
+func (v exprValue) number() (float64, error) {
+	if v.missing {
+		return 0, fmt.Errorf("field '%s' is missing", v.field)
+	}
+	if !v.isString {
+		return v.num, nil
+	}
+
+	switch strings.ToLower(v.str) {
+	case "true", "yes":
+		return 1, nil
+	case "false", "no":
+		return 0, nil
+	}
+
+	if f, err := strconv.ParseFloat(v.str, 64); err == nil {
+		return f, nil
+	}
+
+	if v.str != "" && strings.IndexFunc(v.str, unicode.IsLetter) >= 0 || strings.Contains(v.str, ":") {
+		if d, err := mikrotik.ParseDuration(v.str); err == nil {
+			return d.Seconds(), nil
+		}
+	}
+
+	if v.field != "" {
+		return 0, fmt.Errorf("field '%s' value '%s' is not a number", v.field, v.str)
+	}
+	return 0, fmt.Errorf("value '%s' is not a number", v.str)
+}
+
+func (v exprValue) truth() (bool, error) {
+	if v.empty() {
+		return false, nil
+	}
+	f, err := v.number()
+	if err != nil {
+		// Any non-empty string that is not a number is considered true.
+		return true, nil
+	}
+	return f != 0, nil
+}
+
+type exprNode interface {
+	eval(item mikrotik.MikrotikItem) (exprValue, error)
+	walk(fn func(exprNode))
+}
+
+type exprLiteral struct {
+	val exprValue
+}
+
+func (n *exprLiteral) eval(mikrotik.MikrotikItem) (exprValue, error) {
+	return n.val, nil
+}
+
+func (n *exprLiteral) walk(fn func(exprNode)) {
+	fn(n)
+}
+
+type exprField struct {
+	name string
+}
+
+func (n *exprField) eval(item mikrotik.MikrotikItem) (exprValue, error) {
+	s, ok := item[n.name]
+	if !ok {
+		return exprValue{missing: true, field: n.name}, nil
+	}
+	return exprValue{isString: true, str: s, field: n.name}, nil
+}
+
+func (n *exprField) walk(fn func(exprNode)) {
+	fn(n)
+}
+
+type exprUnary struct {
+	op string
+	x  exprNode
+}
+
+func (n *exprUnary) eval(item mikrotik.MikrotikItem) (exprValue, error) {
+	v, err := n.x.eval(item)
+	if err != nil {
+		return v, err
+	}
+
+	switch n.op {
+	case "!":
+		b, err := v.truth()
+		return boolValue(!b), err
+	default: // "-"
+		f, err := v.number()
+		return numValue(-f), err
+	}
+}
+
+func (n *exprUnary) walk(fn func(exprNode)) {
+	fn(n)
+	n.x.walk(fn)
+}
+
+type exprBinary struct {
+	op   string
+	x, y exprNode
+}
+
+func (n *exprBinary) eval(item mikrotik.MikrotikItem) (exprValue, error) {
+	x, err := n.x.eval(item)
+	if err != nil {
+		return x, err
+	}
+
+	// Short-circuit operators.
+	switch n.op {
+	case "??":
+		if !x.empty() {
+			return x, nil
+		}
+		return n.y.eval(item)
+	case "&&", "||":
+		b, err := x.truth()
+		if err != nil {
+			return x, err
+		}
+		if (n.op == "&&" && !b) || (n.op == "||" && b) {
+			return boolValue(b), nil
+		}
+		y, err := n.y.eval(item)
+		if err != nil {
+			return y, err
+		}
+		b, err = y.truth()
+		return boolValue(b), err
+	}
+
+	y, err := n.y.eval(item)
+	if err != nil {
+		return y, err
+	}
+
+	switch n.op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		return compareValues(n.op, x, y)
+	}
+
+	a, err := x.number()
+	if err != nil {
+		return x, err
+	}
+	b, err := y.number()
+	if err != nil {
+		return y, err
+	}
+
+	switch n.op {
+	case "+":
+		return numValue(a + b), nil
+	case "-":
+		return numValue(a - b), nil
+	case "*":
+		return numValue(a * b), nil
+	case "/":
+		if b == 0 {
+			return exprValue{}, fmt.Errorf("division by zero")
+		}
+		return numValue(a / b), nil
+	default: // "%"
+		if b == 0 {
+			return exprValue{}, fmt.Errorf("division by zero")
+		}
+		return numValue(math.Mod(a, b)), nil
+	}
+}
+
+func (n *exprBinary) walk(fn func(exprNode)) {
+	fn(n)
+	n.x.walk(fn)
+	n.y.walk(fn)
+}
+
+func compareValues(op string, x, y exprValue) (exprValue, error) {
+	// A missing field is only equal to another missing field or an empty string.
+	if x.missing || y.missing {
+		eq := x.empty() && y.empty()
+		switch op {
+		case "==":
+			return boolValue(eq), nil
+		case "!=":
+			return boolValue(!eq), nil
+		}
+		if x.missing {
+			return x, fmt.Errorf("field '%s' is missing", x.field)
+		}
+		return y, fmt.Errorf("field '%s' is missing", y.field)
+	}
+
+	var c int
+	a, errA := x.number()
+	b, errB := y.number()
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			c = -1
+		case a > b:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(x.text(), y.text())
+	}
+
+	switch op {
+	case "==":
+		return boolValue(c == 0), nil
+	case "!=":
+		return boolValue(c != 0), nil
+	case "<":
+		return boolValue(c < 0), nil
+	case "<=":
+		return boolValue(c <= 0), nil
+	case ">":
+		return boolValue(c > 0), nil
+	default: // ">="
+		return boolValue(c >= 0), nil
+	}
+}
+
+func (v exprValue) text() string {
+	if v.isString {
+		return v.str
+	}
+	return strconv.FormatFloat(v.num, 'f', -1, 64)
+}
+
+type exprTernary struct {
+	cond, x, y exprNode
+}
+
+func (n *exprTernary) eval(item mikrotik.MikrotikItem) (exprValue, error) {
+	c, err := n.cond.eval(item)
+	if err != nil {
+		return c, err
+	}
+	b, err := c.truth()
+	if err != nil {
+		return c, err
+	}
+	if b {
+		return n.x.eval(item)
+	}
+	return n.y.eval(item)
+}
+
+func (n *exprTernary) walk(fn func(exprNode)) {
+	fn(n)
+	n.cond.walk(fn)
+	n.x.walk(fn)
+	n.y.walk(fn)
+}
+
+type exprCall struct {
+	name string
+	args []exprNode
+}
+
+var exprFuncArity = map[string][2]int{
+	// name: min, max (-1 means unlimited)
+	"default":  {2, 2},
+	"has":      {1, 1},
+	"if":       {3, 3},
+	"min":      {1, -1},
+	"max":      {1, -1},
+	"abs":      {1, 1},
+	"num":      {1, 1},
+	"bool":     {1, 1},
+	"duration": {1, 1},
+	"quantity": {1, 1},
+}
+
+func (n *exprCall) eval(item mikrotik.MikrotikItem) (exprValue, error) {
+	switch n.name {
+	case "default":
+		return (&exprBinary{op: "??", x: n.args[0], y: n.args[1]}).eval(item)
+	case "if":
+		return (&exprTernary{cond: n.args[0], x: n.args[1], y: n.args[2]}).eval(item)
+	case "has":
+		v, err := n.args[0].eval(item)
+		return boolValue(!v.empty()), err
+	}
+
+	var args = make([]exprValue, len(n.args))
+	for i, a := range n.args {
+		v, err := a.eval(item)
+		if err != nil {
+			return v, err
+		}
+		args[i] = v
+	}
+
+	switch n.name {
+	case "bool":
+		b, err := args[0].truth()
+		return boolValue(b), err
+	case "duration":
+		if args[0].missing {
+			return args[0], fmt.Errorf("field '%s' is missing", args[0].field)
+		}
+		d, err := mikrotik.ParseDuration(args[0].text())
+		return numValue(d.Seconds()), err
+	case "quantity":
+		if args[0].missing {
+			return args[0], fmt.Errorf("field '%s' is missing", args[0].field)
+		}
+		f, _, err := parseQuantity(args[0].text())
+		return numValue(f), err
+	}
+
+	var nums = make([]float64, len(args))
+	for i, a := range args {
+		f, err := a.number()
+		if err != nil {
+			return a, err
+		}
+		nums[i] = f
+	}
+
+	switch n.name {
+	case "min":
+		res := nums[0]
+		for _, f := range nums[1:] {
+			res = math.Min(res, f)
+		}
+		return numValue(res), nil
+	case "max":
+		res := nums[0]
+		for _, f := range nums[1:] {
+			res = math.Max(res, f)
+		}
+		return numValue(res), nil
+	case "abs":
+		return numValue(math.Abs(nums[0])), nil
+	default: // "num"
+		return numValue(nums[0]), nil
+	}
+}
+
+func (n *exprCall) walk(fn func(exprNode)) {
+	fn(n)
+	for _, a := range n.args {
+		a.walk(fn)
+	}
+}
+
+// Parser
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+type exprParser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+var exprOperators = []string{
+	"??", "==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", ",",
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func (p *exprParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case '0' <= c && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+				j++
+			}
+			if j < len(s) && (s[j] == 'e' || s[j] == 'E') {
+				j++
+				if j < len(s) && (s[j] == '+' || s[j] == '-') {
+					j++
+				}
+				for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+					j++
+				}
+			}
+			p.tokens = append(p.tokens, token{kind: tokNumber, text: s[i:j], pos: i})
+			i = j
+
+		case c == '"' || c == '\'':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				sb.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			p.tokens = append(p.tokens, token{kind: tokString, text: sb.String(), pos: i})
+			i = j + 1
+
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(s) {
+				// A dash is a part of the field name only when it is surrounded by name characters.
+				if isIdentChar(s[j]) || (s[j] == '-' && j+1 < len(s) && isIdentChar(s[j+1])) {
+					j++
+					continue
+				}
+				break
+			}
+			p.tokens = append(p.tokens, token{kind: tokIdent, text: strings.TrimPrefix(s[i:j], "$"), pos: i})
+			i = j
+
+		default:
+			var found bool
+			for _, op := range exprOperators {
+				if strings.HasPrefix(s[i:], op) {
+					p.tokens = append(p.tokens, token{kind: tokOp, text: op, pos: i})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("unexpected character '%c' at %d", c, i)
+			}
+		}
+	}
+
+	p.tokens = append(p.tokens, token{kind: tokEOF, pos: len(s)})
+	return nil
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if _, ok := p.isOp(op); !ok {
+		t := p.peek()
+		if t.kind == tokEOF {
+			return fmt.Errorf("expected '%s' at the end of expression", op)
+		}
+		return fmt.Errorf("expected '%s' at %d, got '%s'", op, t.pos, t.text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) parseTernary() (exprNode, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.isOp("?"); !ok {
+		return cond, nil
+	}
+	p.next()
+
+	x, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	y, err := p.parseTernary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &exprTernary{cond: cond, x: x, y: y}, nil
+}
+
+// Binary operators from the lowest to the highest precedence.
+var exprPrecedence = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(exprPrecedence) {
+		return p.parseUnary()
+	}
+
+	x, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.isOp(exprPrecedence[level]...)
+		if !ok {
+			return x, nil
+		}
+		p.next()
+
+		y, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		x = &exprBinary{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if op, ok := p.isOp("!", "-"); ok {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s' at %d", t.text, t.pos)
+		}
+		return &exprLiteral{val: numValue(f)}, nil
+
+	case tokString:
+		return &exprLiteral{val: exprValue{isString: true, str: t.text}}, nil
+
+	case tokIdent:
+		if _, ok := p.isOp("("); ok {
+			return p.parseCall(t)
+		}
+		switch t.text {
+		case "true":
+			return &exprLiteral{val: boolValue(true)}, nil
+		case "false":
+			return &exprLiteral{val: boolValue(false)}, nil
+		}
+		return &exprField{name: t.text}, nil
+
+	case tokOp:
+		if t.text == "(" {
+			x, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+		return nil, fmt.Errorf("unexpected '%s' at %d", t.text, t.pos)
+	}
+
+	return nil, fmt.Errorf("unexpected end of expression")
+}
+
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	arity, ok := exprFuncArity[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at %d", name.text, name.pos)
+	}
+	p.next() // (
+
+	var args []exprNode
+	if _, ok := p.isOp(")"); !ok {
+		for {
+			a, err := p.parseTernary()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+
+			if _, ok := p.isOp(","); !ok {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if len(args) < arity[0] || (arity[1] >= 0 && len(args) > arity[1]) {
+		return nil, fmt.Errorf("wrong number of arguments for '%s' at %d", name.text, name.pos)
+	}
+
+	return &exprCall{name: name.text, args: args}, nil
+}
+
+var quantityPrefixes = []struct {
+	prefix     string
+	multiplier float64
+}{
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"k", 1e3}, {"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+}
+
+// quantityUnits Units the multiplier prefixes apply to, also as a rate, e.g. KiB/s.
+var quantityUnits = map[string]bool{
+	"bps": true, "pps": true, "b": true, "bit": true, "B": true,
+	"Hz": true, "W": true, "Wh": true, "V": true, "A": true,
+}
+
+// parseQuantity Parses a number followed by an optional unit, e.g. 100Mbps, -62dBm, 1.5GiB.
+// Returns the value in the base unit and the unit without the multiplier prefix.
+// The prefix is only removed before one of quantityUnits.
+func parseQuantity(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.' || i == 0 && (s[i] == '-' || s[i] == '+')) {
+		i++
+	}
+
+	f, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("value '%s' is not a quantity", s)
+	}
+
+	unit := s[i:]
+	if strings.IndexFunc(unit, func(r rune) bool { return !unicode.IsLetter(r) && r != '/' && r != '%' }) >= 0 {
+		return 0, "", fmt.Errorf("value '%s' is not a quantity", s)
+	}
+
+	for _, p := range quantityPrefixes {
+		base, ok := strings.CutPrefix(unit, p.prefix)
+		if ok && quantityUnits[strings.TrimSuffix(base, "/s")] {
+			return f * p.multiplier, base, nil
+		}
+	}
+
+	return f, unit, nil
+}
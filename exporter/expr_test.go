@@ -0,0 +1,252 @@
+package exporter
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+func TestParseExprErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "", err: "unexpected end of expression"},
+		{src: "1 +", err: "unexpected end of expression"},
+		{src: "(1 + 2", err: "expected ')' at the end of expression"},
+		{src: "1 + 2)", err: "unexpected ')' at 5"},
+		{src: "a ? 1", err: "expected ':' at the end of expression"},
+		{src: "'text", err: "unterminated string at 0"},
+		{src: "a # b", err: "unexpected character '#' at 2"},
+		{src: "1 2", err: "unexpected '2' at 2"},
+		{src: "foo(1)", err: "unknown function 'foo' at 0"},
+		{src: "abs(1, 2)", err: "wrong number of arguments for 'abs' at 0"},
+		{src: "if(a, 1)", err: "wrong number of arguments for 'if' at 0"},
+		{src: "min()", err: "wrong number of arguments for 'min' at 0"},
+		{src: "1.2.3", err: "invalid number '1.2.3' at 0"},
+		{src: "* 2", err: "unexpected '*' at 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := ParseExpr(tt.src)
+			if err == nil {
+				t.Fatalf("expected error '%s'", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExprEval(t *testing.T) {
+	item := mikrotik.MikrotikItem{
+		"total-memory": "1000",
+		"free-memory":  "250",
+		"running":      "true",
+		"disabled":     "no",
+		"name":         "ether1",
+		"uptime":       "1h2m3s",
+		"rate":         "100Mbps",
+		"signal":       "-62dBm",
+		"size":         "12KiB",
+		"empty":        "",
+		"zero":         "0",
+		".id":          "*1",
+		"text":         "up",
+	}
+
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		// Literals and precedence
+		{src: "1 + 2 * 3", want: 7},
+		{src: "(1 + 2) * 3", want: 9},
+		{src: "10 - 4 - 3", want: 3},
+		{src: "12 / 3 / 2", want: 2},
+		{src: "7 % 4", want: 3},
+		{src: "1e3", want: 1000},
+		{src: "2.5 * 2", want: 5},
+		{src: "1 + 2 > 2 && 1 < 2", want: 1},
+		{src: "1 || 0 && 0", want: 1},
+
+		// Unary and binary minus: field names may contain dashes
+		{src: "-5", want: -5},
+		{src: "--5", want: 5},
+		{src: "2 * -3", want: -6},
+		{src: "total-memory - free-memory", want: 750},
+		{src: "-free-memory", want: -250},
+		{src: "(total-memory - free-memory) / total-memory", want: 0.75},
+
+		// Field conversions
+		{src: "running", want: 1},
+		{src: "disabled", want: 0},
+		{src: "!disabled", want: 1},
+		{src: "uptime", want: 3723},
+		{src: "$total-memory", want: 1000},
+
+		// Comparisons
+		{src: "name == 'ether1'", want: 1},
+		{src: `name != "ether1"`, want: 0},
+		{src: "total-memory >= 1000", want: 1},
+		{src: "free-memory < 100", want: 0},
+		{src: "'b' > 'a'", want: 1},
+		{src: "'10' == 10", want: 1},
+		{src: "missing == ''", want: 1},
+		{src: "missing == empty", want: 1},
+		{src: "missing != 1", want: 1},
+
+		// Defaults for missing or empty fields
+		{src: "missing ?? 5", want: 5},
+		{src: "empty ?? 6", want: 6},
+		{src: "free-memory ?? 5", want: 250},
+		{src: "missing ?? empty ?? 7", want: 7},
+		{src: "default(missing, 8)", want: 8},
+		{src: "1 + (missing ?? 2)", want: 3},
+
+		// Ternary
+		{src: "running ? 10 : 20", want: 10},
+		{src: "disabled ? 10 : 20", want: 20},
+		{src: "text ? 1 : 0", want: 1},
+		{src: "empty ? 1 : 0", want: 0},
+		{src: "missing ? 1 : 0", want: 0},
+		{src: "zero ? 1 : zero == 0 ? 2 : 3", want: 2},
+		{src: "if(name == 'ether1', 1, 2)", want: 1},
+		{src: "zero > 0 ? 1 / zero : 0", want: 0},
+
+		// Short-circuit operators don't evaluate the other side
+		{src: "0 && 1 / zero", want: 0},
+		{src: "1 || missing", want: 1},
+
+		// Functions
+		{src: "has(name)", want: 1},
+		{src: "has(missing)", want: 0},
+		{src: "has(empty)", want: 0},
+		{src: "min(3, 1, 2)", want: 1},
+		{src: "max(3, 1, 2)", want: 3},
+		{src: "abs(-4)", want: 4},
+		{src: "num('2.5')", want: 2.5},
+		{src: "bool(text)", want: 1},
+		{src: "duration('1d')", want: 86400},
+		{src: "duration(uptime)", want: 3723},
+		{src: "quantity(rate)", want: 100e6},
+		{src: "quantity(signal)", want: -62},
+		{src: "quantity(size)", want: 12 * 1024},
+		{src: "quantity('5')", want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := ParseExpr(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := e.Eval(item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprEvalErrors(t *testing.T) {
+	item := mikrotik.MikrotikItem{
+		"zero":  "0",
+		"name":  "ether1",
+		"empty": "",
+	}
+
+	tests := []struct {
+		src string
+		err string
+	}{
+		{src: "1 / zero", err: "division by zero"},
+		{src: "5 % 0", err: "division by zero"},
+		{src: "missing", err: "field 'missing' is missing"},
+		{src: "missing + 1", err: "field 'missing' is missing"},
+		{src: "missing > 1", err: "field 'missing' is missing"},
+		// ?? has the lowest precedence, the sum fails before the default applies
+		{src: "1 + missing ?? 2", err: "field 'missing' is missing"},
+		{src: "name + 1", err: "field 'name' value 'ether1' is not a number"},
+		{src: "'abc'", err: "value 'abc' is not a number"},
+		{src: "quantity(name)", err: "value 'ether1' is not a quantity"},
+		{src: "quantity('10 Mbps')", err: "is not a quantity"},
+		{src: "quantity(missing)", err: "field 'missing' is missing"},
+		{src: "duration(missing)", err: "field 'missing' is missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := ParseExpr(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = e.Eval(item)
+			if err == nil {
+				t.Fatalf("expected error '%s'", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestExprFields(t *testing.T) {
+	e, err := ParseExpr("has(rx-byte) ? (rx-byte + $tx-byte) / 2 : .id == '*1' ?? 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := e.Fields()
+	sort.Strings(got)
+	if want := []string{".id", "rx-byte", "rx-byte", "tx-byte"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		s    string
+		want float64
+		unit string
+	}{
+		{s: "100Mbps", want: 100e6, unit: "bps"},
+		{s: "1.5GiB", want: 1.5 * (1 << 30), unit: "B"},
+		{s: "-62dBm", want: -62, unit: "dBm"},
+		{s: "10kbps", want: 10e3, unit: "bps"},
+		{s: "42", want: 42, unit: ""},
+		{s: " 7% ", want: 7, unit: "%"},
+		{s: "2.4GHz", want: 2.4e9, unit: "Hz"},
+		{s: "12KiB/s", want: 12 << 10, unit: "B/s"},
+		{s: "1.5kW", want: 1500, unit: "W"},
+		{s: "3V", want: 3, unit: "V"},
+		// The prefix is only removed before a known unit
+		{s: "3M", want: 3, unit: "M"},
+		{s: "5Mode", want: 5, unit: "Mode"},
+		{s: "2Ks", want: 2, unit: "Ks"},
+		{s: "7Tx", want: 7, unit: "Tx"},
+		{s: "64Gi", want: 64, unit: "Gi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, unit, err := parseQuantity(tt.s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want || unit != tt.unit {
+				t.Errorf("got %v %q, want %v %q", got, unit, tt.want, tt.unit)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultGlobalVarsRefreshInterval = time.Minute
+
+// GlobalVarSource Router resource field a global variable is read from, or a static value.
+//
+//	global_vars:
+//	  LOCATION:
+//	    resource_path: /system/routerboard/settings
+//	    field: location
+//	  SITE: dc1               # static value
+type GlobalVarSource struct {
+	// MikrotikResourcePath Resource path, the first row is used
+	MikrotikResourcePath string `yaml:"resource_path,omitempty"`
+	// ResourceFilter Filter executed on find to select the row (optional)
+	ResourceFilter map[string]string `yaml:"resource_filter,omitempty"`
+	// Field Field of the row
+	Field string `yaml:"field,omitempty"`
+	// Value Static value
+	Value string `yaml:"value,omitempty"`
+}
+
+// UnmarshalYAML Implements yaml.Unmarshaler to support static values as scalars.
+func (s *GlobalVarSource) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = GlobalVarSource{}
+		return node.Decode(&s.Value)
+	}
+
+	type plain GlobalVarSource
+	if err := node.Decode((*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.MikrotikResourcePath != "" && s.Field == "" {
+		return fmt.Errorf("global variable from '%s': field is not defined", s.MikrotikResourcePath)
+	}
+	// Global variables are used in labels
+	if mikrotik.IsSensitiveField(s.MikrotikResourcePath, s.Field) {
+		return fmt.Errorf("global variable from '%s': field '%s' holds a secret", s.MikrotikResourcePath, s.Field)
+	}
+
+	return nil
+}
+
+// DefaultGlobalVarSources Variables read from the router unless the configuration redefines them.
+var DefaultGlobalVarSources = map[string]GlobalVarSource{
+	"ROUTER_ID":     {MikrotikResourcePath: "/system/identity", Field: "name"},
+	"VERSION":       {MikrotikResourcePath: "/system/resource", Field: "version"},
+	"BOARD_NAME":    {MikrotikResourcePath: "/system/resource", Field: "board-name"},
+	"SERIAL_NUMBER": {MikrotikResourcePath: "/system/routerboard", Field: "serial-number"},
+}
+
+// GlobalVars Variables available to the schema labels as $NAME.
+// The variables read from the router are refreshed periodically, so labels follow
+// identity changes and upgrades.
+type GlobalVars struct {
+	mu      sync.RWMutex
+	vars    map[string]string
+	sources map[string]GlobalVarSource
+}
+
+// NewGlobalVars Creates the variables with the static values and the router sources.
+func NewGlobalVars(static map[string]string, sources map[string]GlobalVarSource) *GlobalVars {
+	var g = &GlobalVars{
+		vars:    make(map[string]string, len(static)+len(sources)),
+		sources: make(map[string]GlobalVarSource, len(sources)),
+	}
+
+	for k, v := range static {
+		g.vars[k] = v
+	}
+	for k, s := range sources {
+		if s.MikrotikResourcePath == "" {
+			g.vars[k] = s.Value
+			continue
+		}
+		g.sources[k] = s
+	}
+
+	return g
+}
+
+// Get Returns the variable value.
+func (g *GlobalVars) Get(name string) string {
+	if g == nil {
+		return ""
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.vars[name]
+}
+
+// Snapshot Returns a copy of all variables.
+func (g *GlobalVars) Snapshot() map[string]string {
+	if g == nil {
+		return nil
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var res = make(map[string]string, len(g.vars))
+	for k, v := range g.vars {
+		res[k] = v
+	}
+	return res
+}
+
+// Refresh Reads the variables from the router. Each resource is read once.
+// Variables whose resource can't be read keep their previous values.
+func (g *GlobalVars) Refresh(ctx context.Context) error {
+	type read struct {
+		items []mikrotik.MikrotikItem
+		err   error
+	}
+	var reads = make(map[string]read)
+	var values = make(map[string]string, len(g.sources))
+	var errs []error
+
+	for _, name := range sortedKeys(g.sources) {
+		s := g.sources[name]
+		key := cacheKey(s.MikrotikResourcePath, s.ResourceFilter)
+
+		r, ok := reads[key]
+		if !ok {
+			r.items, r.err = mikrotik.ReadResource(ctx, s.MikrotikResourcePath, s.ResourceFilter)
+			reads[key] = r
+			if r.err != nil {
+				errs = append(errs, fmt.Errorf("reading '%s': %w", s.MikrotikResourcePath, r.err))
+			}
+		}
+		if r.err != nil {
+			continue
+		}
+
+		if len(r.items) > 0 {
+			values[name] = r.items[0][s.Field]
+		} else {
+			values[name] = ""
+		}
+	}
+
+	g.mu.Lock()
+	for k, v := range values {
+		if old, ok := g.vars[k]; ok && old != v {
+			zerolog.Ctx(ctx).Info().Str("var", k).Str("old", old).Str("new", v).Msg("global variable changed")
+		}
+		g.vars[k] = v
+	}
+	g.mu.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// Watch Refreshes the variables every interval until the context is cancelled.
+func (g *GlobalVars) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			refreshCtx, cancel := mikrotik.WithTimeout(ctx, interval)
+			if err := g.Refresh(refreshCtx); err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Msg("refreshing global variables")
+			}
+			cancel()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
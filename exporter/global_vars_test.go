@@ -0,0 +1,172 @@
+package exporter
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+	"gopkg.in/yaml.v3"
+)
+
+// setSystemRows Sets the router resources the default global variables are read from.
+func setSystemRows(router *testRouter, identity, version string) {
+	router.set("/system/identity", mikrotik.MikrotikItem{"name": identity})
+	router.set("/system/resource", mikrotik.MikrotikItem{"version": version, "board-name": "CCR2004-1G-12S+2XS", "uptime": "1d"})
+	router.set("/system/routerboard", mikrotik.MikrotikItem{"serial-number": "HF1234", "model": "CCR2004"})
+}
+
+func TestGlobalVarsDefaults(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	setSystemRows(router, "core", "7.15.2 (stable)")
+
+	g := NewGlobalVars(map[string]string{"HOSTURL": "10.0.0.1:443"}, DefaultGlobalVarSources)
+	// Not read before the first refresh
+	if v := g.Get("ROUTER_ID"); v != "" {
+		t.Errorf("ROUTER_ID is '%s' before the refresh", v)
+	}
+
+	if err := g.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"HOSTURL":       "10.0.0.1:443",
+		"ROUTER_ID":     "core",
+		"VERSION":       "7.15.2 (stable)",
+		"BOARD_NAME":    "CCR2004-1G-12S+2XS",
+		"SERIAL_NUMBER": "HF1234",
+	}
+	if got := g.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// VERSION and BOARD_NAME share the read
+	if n := router.count("GET", "/system/resource"); n != 1 {
+		t.Errorf("/system/resource is read %d times", n)
+	}
+}
+
+func TestGlobalVarsRefresh(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	setSystemRows(router, "core", "7.15.2")
+
+	g := NewGlobalVars(nil, map[string]GlobalVarSource{
+		"ROUTER_ID": DefaultGlobalVarSources["ROUTER_ID"],
+		"VERSION":   DefaultGlobalVarSources["VERSION"],
+		"LOCATION":  {MikrotikResourcePath: "/system/routerboard/settings", Field: "location"},
+		"SITE":      {Value: "dc1"},
+	})
+	if v := g.Get("SITE"); v != "dc1" {
+		t.Errorf("static SITE is '%s'", v)
+	}
+
+	// The unreadable variable is reported, the others are read
+	err := g.Refresh(ctx)
+	if err == nil || !strings.Contains(err.Error(), "reading '/system/routerboard/settings'") {
+		t.Errorf("got error '%v'", err)
+	}
+	if v := g.Get("ROUTER_ID"); v != "core" {
+		t.Errorf("ROUTER_ID is '%s'", v)
+	}
+
+	// Identity change and upgrade
+	setSystemRows(router, "edge", "7.16")
+	router.set("/system/routerboard/settings", mikrotik.MikrotikItem{"location": "rack 4"})
+	if err := g.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"ROUTER_ID": "edge", "VERSION": "7.16", "LOCATION": "rack 4", "SITE": "dc1"}
+	if got := g.Snapshot(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The values are kept while the resource can't be read, an empty resource clears them
+	router.mu.Lock()
+	delete(router.rows, "/system/identity")
+	router.mu.Unlock()
+	router.set("/system/routerboard/settings")
+	if err := g.Refresh(ctx); err == nil {
+		t.Error("no error reading a missing resource")
+	}
+	if v := g.Get("ROUTER_ID"); v != "edge" {
+		t.Errorf("ROUTER_ID is '%s' after a failed read, want 'edge'", v)
+	}
+	if v, ok := g.Snapshot()["LOCATION"]; !ok || v != "" {
+		t.Errorf("LOCATION is '%s' of an empty resource", v)
+	}
+}
+
+func TestGlobalVarsWatch(t *testing.T) {
+	if DefaultGlobalVarsRefreshInterval != time.Minute {
+		t.Errorf("refresh interval is %v", DefaultGlobalVarsRefreshInterval)
+	}
+
+	router, ctx := newTestRouter(t)
+	setSystemRows(router, "core", "7.15.2")
+
+	g := NewGlobalVars(nil, DefaultGlobalVarSources)
+	if err := g.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		g.Watch(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+
+	setSystemRows(router, "edge", "7.16")
+	for deadline := time.Now().Add(5 * time.Second); g.Get("ROUTER_ID") != "edge"; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("ROUTER_ID is '%s' after the refresh interval", g.Get("ROUTER_ID"))
+		}
+	}
+	if v := g.Get("VERSION"); v != "7.16" {
+		t.Errorf("VERSION is '%s'", v)
+	}
+	// The legacy router labels follow the refresh
+	if v := RouterLabels(RouterLabelsLegacy, g)["routerboard_id"]; v != "edge" {
+		t.Errorf("routerboard_id label is '%s' after the refresh", v)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch doesn't return after the context is cancelled")
+	}
+}
+
+func TestGlobalVarSourceYAML(t *testing.T) {
+	var sources map[string]GlobalVarSource
+	err := yaml.Unmarshal([]byte(`
+SITE: dc1
+LOCATION:
+  resource_path: /system/routerboard/settings
+  field: location
+`), &sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]GlobalVarSource{
+		"SITE":     {Value: "dc1"},
+		"LOCATION": {MikrotikResourcePath: "/system/routerboard/settings", Field: "location"},
+	}
+	if !reflect.DeepEqual(sources, want) {
+		t.Errorf("got %+v, want %+v", sources, want)
+	}
+
+	tests := map[string]string{
+		"{resource_path: /system/identity}":             "global variable from '/system/identity': field is not defined",
+		"{resource_path: /ppp/secret, field: password}": "global variable from '/ppp/secret': field 'password' holds a secret",
+	}
+	for src, want := range tests {
+		var s GlobalVarSource
+		if err := yaml.Unmarshal([]byte(src), &s); err == nil || err.Error() != want {
+			t.Errorf("%s: got error '%v', want '%s'", src, err, want)
+		}
+	}
+}
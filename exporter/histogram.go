@@ -0,0 +1,66 @@
+package exporter
+
+import (
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// histogramCollector Histogram of the values observed on all rows during one collection cycle.
+type histogramCollector struct {
+	*snapshotCollector
+	buckets []float64
+	// pending Data of the current collection cycle, accessed only by the collecting goroutine
+	pending map[string]*histogramData
+}
+
+type histogramData struct {
+	labelValues []string
+	count       uint64
+	sum         float64
+	buckets     map[float64]uint64
+}
+
+func newHistogramCollector(fqName, help string, labelNames []string, constLabels prom.Labels, buckets []float64) *histogramCollector {
+	return &histogramCollector{
+		snapshotCollector: newSnapshotCollector(fqName, help, labelNames, constLabels),
+		buckets:           buckets,
+	}
+}
+
+// begin Starts a new collection cycle.
+func (h *histogramCollector) begin() {
+	h.pending = make(map[string]*histogramData)
+}
+
+func (h *histogramCollector) observe(labels prom.Labels, v float64) {
+	values := h.labelValues(labels)
+	key := labelsKey(values)
+
+	d, ok := h.pending[key]
+	if !ok {
+		d = &histogramData{labelValues: values, buckets: make(map[float64]uint64, len(h.buckets))}
+		for _, b := range h.buckets {
+			d.buckets[b] = 0
+		}
+		h.pending[key] = d
+	}
+
+	d.count++
+	d.sum += v
+	// Buckets are cumulative.
+	for _, b := range h.buckets {
+		if v <= b {
+			d.buckets[b]++
+		}
+	}
+}
+
+// commit Replaces the exposed histograms with the data of the current cycle.
+func (h *histogramCollector) commit() {
+	var metrics = make([]prom.Metric, 0, len(h.pending))
+	for _, key := range sortedKeys(h.pending) {
+		d := h.pending[key]
+		metrics = append(metrics, prom.MustNewConstHistogram(h.desc, d.count, d.sum, d.buckets, d.labelValues...))
+	}
+	h.set(metrics)
+	h.pending = nil
+}
@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+func TestHistogramCollector(t *testing.T) {
+	h := newHistogramCollector("mikrotik_capsman_clients_signal_strength_dbm", "Signal strength",
+		[]string{"ssid"}, prom.Labels{TargetLabel: "router"}, []float64{-80, -70, -60})
+
+	h.begin()
+	// A value equal to the upper bound falls into the bucket, the last one is only in +Inf
+	for _, v := range []float64{-85, -80, -72, -65, -50} {
+		h.observe(prom.Labels{"ssid": "office"}, v)
+	}
+	h.observe(prom.Labels{"ssid": "guest", "unused": "x"}, -61)
+	h.commit()
+
+	const help = `
+# HELP mikrotik_capsman_clients_signal_strength_dbm Signal strength
+# TYPE mikrotik_capsman_clients_signal_strength_dbm histogram
+`
+	want := help + `
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="guest",le="-80"} 0
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="guest",le="-70"} 0
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="guest",le="-60"} 1
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="guest",le="+Inf"} 1
+mikrotik_capsman_clients_signal_strength_dbm_sum{routerboard_address="router",ssid="guest"} -61
+mikrotik_capsman_clients_signal_strength_dbm_count{routerboard_address="router",ssid="guest"} 1
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-80"} 2
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-70"} 3
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-60"} 4
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="+Inf"} 5
+mikrotik_capsman_clients_signal_strength_dbm_sum{routerboard_address="router",ssid="office"} -352
+mikrotik_capsman_clients_signal_strength_dbm_count{routerboard_address="router",ssid="office"} 5
+`
+	if err := testutil.CollectAndCompare(h, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// The next cycle replaces the distribution, the SSID without clients is gone
+	h.begin()
+	h.observe(prom.Labels{"ssid": "office"}, -90)
+	// Not exposed until the cycle is committed
+	if n := testutil.CollectAndCount(h); n != 2 {
+		t.Errorf("got %d series during the cycle, want 2", n)
+	}
+	h.commit()
+
+	want = help + `
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-80"} 1
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-70"} 1
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-60"} 1
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="+Inf"} 1
+mikrotik_capsman_clients_signal_strength_dbm_sum{routerboard_address="router",ssid="office"} -90
+mikrotik_capsman_clients_signal_strength_dbm_count{routerboard_address="router",ssid="office"} 1
+`
+	if err := testutil.CollectAndCompare(h, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// A cycle without rows exposes nothing
+	h.begin()
+	h.commit()
+	if n := testutil.CollectAndCount(h); n != 0 {
+		t.Errorf("got %d series without rows", n)
+	}
+}
+
+func TestHistogramSchema(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/caps-man/registration-table",
+		mikrotik.MikrotikItem{"ssid": "office", "rx-signal": "-64"},
+		mikrotik.MikrotikItem{"ssid": "office", "rx-signal": "-81"},
+		mikrotik.MikrotikItem{"ssid": "office", "rx-signal": "weak"},
+	)
+
+	r, reg := newTestExporter(t, `
+subsystem: capsman
+resource_path: /caps-man/registration-table
+global_labels:
+  ssid: $ssid
+metrics:
+  - name: clients_signal_strength_dbm
+    help: Distribution of client devices signal strength
+    type: Histogram
+    field: rx-signal
+    field_type: int
+    buckets: [-80, -60]
+`)
+	if _, err := r.exportMetrics(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unparsable value is not observed
+	want := `
+# HELP mikrotik_capsman_clients_signal_strength_dbm Distribution of client devices signal strength
+# TYPE mikrotik_capsman_clients_signal_strength_dbm histogram
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-80"} 1
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="-60"} 2
+mikrotik_capsman_clients_signal_strength_dbm_bucket{routerboard_address="router",ssid="office",le="+Inf"} 2
+mikrotik_capsman_clients_signal_strength_dbm_sum{routerboard_address="router",ssid="office"} -145
+mikrotik_capsman_clients_signal_strength_dbm_count{routerboard_address="router",ssid="office"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "mikrotik_capsman_clients_signal_strength_dbm"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,88 @@
+package exporter
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// Join Attaches the fields of a secondary resource to the resource rows before labels are built.
+//
+//	joins:
+//	  - name: lease
+//	    resource_path: /ip/dhcp-server/lease
+//	    on: mac-address         # field of the resource row
+//	    key: mac-address        # field of the secondary row, defaults to 'on'
+//	    fields: [host-name]     # attached fields, all fields by default
+//
+// The attached fields are named '<name>.<field>', e.g. $lease.host-name.
+type Join struct {
+	// Name Prefix of the attached fields, defaults to the last element of the resource path
+	Name string `yaml:"name,omitempty"`
+	// MikrotikResourcePath Secondary resource path in routeros
+	MikrotikResourcePath string `yaml:"resource_path"`
+	// ResourceFilter Filter executed on find to select secondary rows (optional)
+	ResourceFilter map[string]string `yaml:"resource_filter,omitempty"`
+	// On Field of the resource row to match
+	On string `yaml:"on"`
+	// Key Field of the secondary row to match, defaults to On
+	Key string `yaml:"key,omitempty"`
+	// Fields Fields to attach (optional)
+	Fields []string `yaml:"fields,omitempty"`
+}
+
+func (j *Join) validate() error {
+	if j.MikrotikResourcePath == "" {
+		return fmt.Errorf("join resource_path is not defined")
+	}
+	if j.On == "" {
+		return fmt.Errorf("join '%s' field 'on' is not defined", j.MikrotikResourcePath)
+	}
+	if j.Key == "" {
+		j.Key = j.On
+	}
+	if j.Name == "" {
+		j.Name = path.Base(j.MikrotikResourcePath)
+	}
+	return nil
+}
+
+// apply Returns new rows with the fields of the matching secondary rows attached.
+// The first secondary row with the key is used.
+func (j *Join) apply(items, secondary []mikrotik.MikrotikItem) []mikrotik.MikrotikItem {
+	var index = make(map[string]mikrotik.MikrotikItem, len(secondary))
+	for _, s := range secondary {
+		if k, ok := s[j.Key]; ok {
+			if _, found := index[k]; !found {
+				index[k] = s
+			}
+		}
+	}
+
+	var res = make([]mikrotik.MikrotikItem, len(items))
+	for i, item := range items {
+		var row = make(mikrotik.MikrotikItem, len(item)+len(j.Fields))
+		for k, v := range item {
+			row[k] = v
+		}
+
+		if s, ok := index[item[j.On]]; ok {
+			if len(j.Fields) == 0 {
+				for k, v := range s {
+					row[j.Name+"."+k] = v
+				}
+			} else {
+				for _, f := range j.Fields {
+					if v, ok := s[f]; ok {
+						row[j.Name+"."+f] = v
+					}
+				}
+			}
+		}
+
+		res[i] = row
+	}
+
+	return res
+}
@@ -0,0 +1,138 @@
+package exporter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+func TestJoinValidate(t *testing.T) {
+	j := &Join{MikrotikResourcePath: "/ip/dhcp-server/lease", On: "mac-address"}
+	if err := j.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if j.Name != "lease" || j.Key != "mac-address" {
+		t.Errorf("got name '%s' key '%s', want the defaults", j.Name, j.Key)
+	}
+
+	tests := []struct {
+		join Join
+		err  string
+	}{
+		{join: Join{On: "mac-address"}, err: "join resource_path is not defined"},
+		{join: Join{MikrotikResourcePath: "/ip/arp"}, err: "join '/ip/arp' field 'on' is not defined"},
+	}
+	for _, tt := range tests {
+		if err := tt.join.validate(); err == nil || err.Error() != tt.err {
+			t.Errorf("got error '%v', want '%s'", err, tt.err)
+		}
+	}
+}
+
+func TestJoinApply(t *testing.T) {
+	items := []mikrotik.MikrotikItem{
+		{"mac-address": "AA", "interface": "cap1"},
+		{"mac-address": "BB", "interface": "cap2"},
+		{"interface": "cap3"},
+	}
+	secondary := []mikrotik.MikrotikItem{
+		{"mac": "AA", "host-name": "laptop", "address": "10.0.0.2"},
+		// The first row with the key is used
+		{"mac": "AA", "host-name": "stale", "address": "10.0.0.9"},
+		{"host-name": "no key"},
+	}
+
+	tests := []struct {
+		name   string
+		fields []string
+		want   []mikrotik.MikrotikItem
+	}{
+		{
+			name:   "selected fields",
+			fields: []string{"host-name", "missing"},
+			want: []mikrotik.MikrotikItem{
+				{"mac-address": "AA", "interface": "cap1", "lease.host-name": "laptop"},
+				{"mac-address": "BB", "interface": "cap2"},
+				{"interface": "cap3"},
+			},
+		},
+		{
+			name: "all fields",
+			want: []mikrotik.MikrotikItem{
+				{"mac-address": "AA", "interface": "cap1", "lease.mac": "AA", "lease.host-name": "laptop", "lease.address": "10.0.0.2"},
+				{"mac-address": "BB", "interface": "cap2"},
+				{"interface": "cap3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := &Join{Name: "lease", MikrotikResourcePath: "/ip/dhcp-server/lease", On: "mac-address", Key: "mac", Fields: tt.fields}
+			got := j.apply(items, secondary)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			// The rows may be shared by the cache
+			if len(items[0]) != 2 {
+				t.Errorf("resource row is modified: %v", items[0])
+			}
+		})
+	}
+}
+
+func TestJoinSchema(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/caps-man/registration-table",
+		mikrotik.MikrotikItem{"mac-address": "AA", "dhcp-name": "laptop-sent", "rx-signal": "-60"},
+		mikrotik.MikrotikItem{"mac-address": "BB", "dhcp-name": "phone", "rx-signal": "-70"},
+		mikrotik.MikrotikItem{"mac-address": "CC", "rx-signal": "-80"},
+	)
+	router.set("/ip/dhcp-server/lease",
+		mikrotik.MikrotikItem{"mac-address": "AA", "host-name": "laptop"},
+		// Lease without a host name
+		mikrotik.MikrotikItem{"mac-address": "CC", "host-name": ""},
+	)
+
+	s, err := SchemaParser("../resources/capsman_clients_info.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := prom.NewRegistry()
+	r := NewResourceExporter(ctx, s, prom.Labels{TargetLabel: "router"}, reg)
+	r.SetResourceCache(NewResourceCache(DefaultResourceCacheTTL))
+	if _, err := r.exportMetrics(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The name the client sent is used without the lease host name
+	want := map[string]float64{
+		"laptop": -60,
+		"phone":  -70,
+		"":       -80,
+	}
+	if got := gaugeValues(t, reg, "mikrotik_capsman_clients_signal_strength", "dhcp_name"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// A missing joined resource fails the cycle
+	router.set("/interface/wireless/registration-table", mikrotik.MikrotikItem{"mac-address": "AA"})
+	r, _ = newTestExporter(t, `
+subsystem: wireless
+resource_path: /interface/wireless/registration-table
+joins:
+  - resource_path: /ip/arp
+    on: mac-address
+metrics:
+  - name: clients_info
+    type: GaugeVec
+    field_type: const
+    labels: {address: $arp.address}
+`)
+	if _, err := r.exportMetrics(ctx); err == nil || !strings.Contains(err.Error(), "reading joined resource '/ip/arp'") {
+		t.Errorf("got error '%v'", err)
+	}
+}
@@ -0,0 +1,186 @@
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CaseLower = "lower"
+	CaseUpper = "upper"
+)
+
+// LabelSpec Describes how the label value is built.
+// In the schema, the label is either a string or a mapping:
+//
+//	labels:
+//	  static: some value      # constant label
+//	  name: $name             # Mikrotik field or global variable
+//	  comment:
+//	    field: comment
+//	    regex: '^[^\n]*'      # keep the first capture group or the whole match
+//	    max_length: 64
+//	    default: none
+//	  title:
+//	    fields: [comment, name] # the first non-empty field
+//	  key:
+//	    field: private-key
+//	    allow_sensitive: true # export a secret field, see mikrotik.SensitiveFields
+type LabelSpec struct {
+	// Value Constant label value
+	Value string `yaml:"value,omitempty"`
+	// Field Mikrotik field name or global variable
+	Field string `yaml:"field,omitempty"`
+	// Fields List of fields, the first non-empty value is used
+	Fields []string `yaml:"fields,omitempty"`
+	// Join If set, all non-empty values of Fields are joined with this separator
+	Join string `yaml:"join,omitempty"`
+	// Regex Regular expression applied to the value.
+	// Without Replacement the first capture group (or the whole match) is used.
+	Regex string `yaml:"regex,omitempty"`
+	// Replacement Replacement for all Regex matches, supports $1 expansion
+	Replacement *string `yaml:"replacement,omitempty"`
+	// Case Convert the value to the 'lower' or 'upper' case
+	Case string `yaml:"case,omitempty"`
+	// MaxLength Truncate the value to MaxLength characters
+	MaxLength int `yaml:"max_length,omitempty"`
+	// Default Value used when the result is empty
+	Default string `yaml:"default,omitempty"`
+	// AllowSensitive Allows building the label from fields holding secrets
+	AllowSensitive bool `yaml:"allow_sensitive,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// UnmarshalYAML Implements yaml.Unmarshaler to support the short '$field' notation.
+func (l *LabelSpec) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		var s string
+		if err := node.Decode(&s); err != nil {
+			return err
+		}
+		*l = LabelSpec{}
+		if len(s) > 1 && s[0] == '$' {
+			l.Field = s[1:]
+		} else {
+			l.Value = s
+		}
+		return nil
+	}
+
+	type plain LabelSpec
+	if err := node.Decode((*plain)(l)); err != nil {
+		return err
+	}
+
+	return l.Compile()
+}
+
+// Compile Validates the label specification and compiles the regular expression.
+func (l *LabelSpec) Compile() error {
+	switch strings.ToLower(l.Case) {
+	case "", CaseLower, CaseUpper:
+	default:
+		return fmt.Errorf("unknown label case '%s'", l.Case)
+	}
+
+	if l.Field != "" && len(l.Fields) > 0 {
+		return fmt.Errorf("label fields 'field' and 'fields' are mutually exclusive")
+	}
+
+	if l.MaxLength < 0 {
+		return fmt.Errorf("label max_length must be positive")
+	}
+
+	if l.Regex != "" {
+		re, err := regexp.Compile(l.Regex)
+		if err != nil {
+			return fmt.Errorf("label regex: %w", err)
+		}
+		l.re = re
+	}
+
+	return nil
+}
+
+// IsConst Returns true if the label value does not depend on the resource fields.
+func (l *LabelSpec) IsConst() bool {
+	return l.Field == "" && len(l.Fields) == 0
+}
+
+// SourceFields Returns all fields the label value is built from.
+func (l *LabelSpec) SourceFields() []string {
+	if l.Field != "" {
+		return []string{l.Field}
+	}
+	return l.Fields
+}
+
+// Get Builds the label value from the resource row.
+// Global variables take precedence over the resource fields.
+func (l *LabelSpec) Get(item mikrotik.MikrotikItem, globalVars map[string]string) string {
+	var res string
+
+	if l.IsConst() {
+		res = l.Value
+	} else if len(l.Fields) > 0 && l.Join != "" {
+		var values []string
+		for _, f := range l.Fields {
+			if v := fieldValue(f, item, globalVars); v != "" {
+				values = append(values, v)
+			}
+		}
+		res = strings.Join(values, l.Join)
+	} else {
+		for _, f := range l.SourceFields() {
+			if res = fieldValue(f, item, globalVars); res != "" {
+				break
+			}
+		}
+	}
+
+	return l.transform(res)
+}
+
+func (l *LabelSpec) transform(s string) string {
+	if l.re != nil {
+		if l.Replacement != nil {
+			s = l.re.ReplaceAllString(s, *l.Replacement)
+		} else if m := l.re.FindStringSubmatch(s); m == nil {
+			s = ""
+		} else if len(m) > 1 {
+			s = m[1]
+		} else {
+			s = m[0]
+		}
+	}
+
+	switch strings.ToLower(l.Case) {
+	case CaseLower:
+		s = strings.ToLower(s)
+	case CaseUpper:
+		s = strings.ToUpper(s)
+	}
+
+	if l.MaxLength > 0 && utf8.RuneCountInString(s) > l.MaxLength {
+		s = string([]rune(s)[:l.MaxLength])
+	}
+
+	if s == "" {
+		s = l.Default
+	}
+
+	return s
+}
+
+func fieldValue(name string, item mikrotik.MikrotikItem, globalVars map[string]string) string {
+	if v, ok := globalVars[name]; ok {
+		return v
+	}
+	return item[name]
+}
@@ -0,0 +1,104 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+	"gopkg.in/yaml.v3"
+)
+
+func TestLabelSpec(t *testing.T) {
+	item := mikrotik.MikrotikItem{
+		"name":      "ether1",
+		"comment":   "Uplink to ISP\nsecond line",
+		"host-name": "",
+		"address":   "10.0.0.1/24",
+		"mac":       "aa:bb:cc:dd:ee:ff",
+		"long":      "Ünïcödé value",
+	}
+	globalVars := map[string]string{"ROUTER_ID": "r1", "name": "global"}
+
+	tests := []struct {
+		name string
+		spec string
+		want string
+	}{
+		{name: "constant", spec: `static value`, want: "static value"},
+		{name: "constant mapping", spec: `{value: static}`, want: "static"},
+		{name: "dollar only is a constant", spec: `$`, want: "$"},
+		{name: "field", spec: `$address`, want: "10.0.0.1/24"},
+		{name: "global variable", spec: `$ROUTER_ID`, want: "r1"},
+		{name: "global variable wins", spec: `$name`, want: "global"},
+		{name: "missing field", spec: `$missing`, want: ""},
+		{name: "first non-empty field", spec: `{fields: [host-name, missing, mac]}`, want: "aa:bb:cc:dd:ee:ff"},
+		{name: "no field set", spec: `{fields: [host-name, missing]}`, want: ""},
+		{name: "join", spec: `{fields: [ROUTER_ID, host-name, address], join: ' / '}`, want: "r1 / 10.0.0.1/24"},
+		{name: "regex whole match", spec: `{field: comment, regex: '^[^\n]*'}`, want: "Uplink to ISP"},
+		{name: "regex capture group", spec: `{field: address, regex: '^([^/]+)/(\d+)$'}`, want: "10.0.0.1"},
+		{name: "regex without a match", spec: `{field: address, regex: '^fe80'}`, want: ""},
+		{name: "replacement", spec: `{field: mac, regex: ':', replacement: ''}`, want: "aabbccddeeff"},
+		{name: "replacement expansion", spec: `{field: address, regex: '^(.+)/(\d+)$', replacement: '$2 $1'}`, want: "24 10.0.0.1"},
+		{name: "upper case", spec: `{field: mac, case: upper}`, want: "AA:BB:CC:DD:EE:FF"},
+		{name: "lower case", spec: `{field: comment, regex: '^\w+', case: LOWER}`, want: "uplink"},
+		{name: "max length in runes", spec: `{field: long, max_length: 5}`, want: "Ünïcö"},
+		{name: "max length of a short value", spec: `{field: mac, max_length: 64}`, want: "aa:bb:cc:dd:ee:ff"},
+		{name: "default", spec: `{field: host-name, default: unknown}`, want: "unknown"},
+		{name: "default after regex", spec: `{field: address, regex: '^fe80', default: none}`, want: "none"},
+		{name: "default of a non-empty value", spec: `{field: address, default: none}`, want: "10.0.0.1/24"},
+		{name: "default of a constant", spec: `{default: none}`, want: "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l LabelSpec
+			if err := yaml.Unmarshal([]byte(tt.spec), &l); err != nil {
+				t.Fatal(err)
+			}
+			if got := l.Get(item, globalVars); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelSpecErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{spec: `{field: name, case: title}`, err: "unknown label case 'title'"},
+		{spec: `{field: name, fields: [comment]}`, err: "label fields 'field' and 'fields' are mutually exclusive"},
+		{spec: `{field: name, max_length: -1}`, err: "label max_length must be positive"},
+		{spec: `{field: name, regex: '(eth'}`, err: "label regex:"},
+	}
+
+	for _, tt := range tests {
+		var l LabelSpec
+		if err := yaml.Unmarshal([]byte(tt.spec), &l); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error '%v', want '%s'", tt.spec, err, tt.err)
+		}
+	}
+}
+
+func TestLabelSpecSourceFields(t *testing.T) {
+	tests := []struct {
+		spec    string
+		isConst bool
+		fields  []string
+	}{
+		{spec: `value`, isConst: true},
+		{spec: `$name`, fields: []string{"name"}},
+		{spec: `{fields: [comment, name]}`, fields: []string{"comment", "name"}},
+	}
+
+	for _, tt := range tests {
+		var l LabelSpec
+		if err := yaml.Unmarshal([]byte(tt.spec), &l); err != nil {
+			t.Fatal(err)
+		}
+		if l.IsConst() != tt.isConst || strings.Join(l.SourceFields(), ",") != strings.Join(tt.fields, ",") {
+			t.Errorf("%s: const %v, fields %v", tt.spec, l.IsConst(), l.SourceFields())
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package exporter
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rs/zerolog"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// Point Values of the schema metrics read from a resource row in a collection cycle,
+// for the outputs writing rows instead of the Prometheus series.
+//
+// The counters and the gauges changed with Inc, Dec, Add or Sub accumulate the rows, their values
+// are the ones exposed to Prometheus at the end of the cycle, e.g. the number of rows counted with Inc.
+// They are written in one point per series with the collection cycle start time.
+type Point struct {
+	// Measurement Schema namespace and subsystem joined with '_', the schema name if both are empty
+	Measurement string
+	// Tags Metric labels, the schema and router const labels included
+	Tags map[string]string
+	// Fields Metric values by metric name
+	Fields map[string]float64
+	// Time The router time from the schema timestamp field or the collection cycle start
+	Time time.Time
+}
+
+// PointWriter Receives the points of every collection cycle. It's called concurrently by the schemas.
+type PointWriter interface {
+	WritePoints(ctx context.Context, points []Point)
+}
+
+// SetPointWriter Sets the writer receiving the resource rows of every collection cycle.
+func (r *ResourceExporter) SetPointWriter(w PointWriter) {
+	r.points = w
+}
+
+// pointBuilder Collects the points of a collection cycle. The metrics of a row with the same
+// labels are fields of one point. Aggregated and histogram metrics don't map to rows, they are skipped.
+// The accumulating metrics are read from their series when the cycle is written.
+// A nil builder collects nothing.
+type pointBuilder struct {
+	writer      PointWriter
+	schema      *ResourceSchema
+	measurement string
+	constLabels prom.Labels
+	start       time.Time
+
+	rowTime time.Time
+	// row Points of the current row by their tags
+	row    map[string]*Point
+	points []Point
+	// series Series of the accumulating metrics by the point tags and the metric name
+	series map[string]*seriesPoint
+}
+
+type seriesPoint struct {
+	tags   map[string]string
+	fields map[string]prom.Metric
+}
+
+// accumulates Returns true if the metric value accumulates the rows: counters and gauges
+// changed with Inc, Dec, Add or Sub.
+func (m *ResourceMetric) accumulates() bool {
+	if m.PromMetricType == CounterVec {
+		return true
+	}
+	switch m.PromMetricOperation {
+	case OperInc, OperDec, OperAdd, OperSub:
+		return true
+	}
+	return false
+}
+
+func (r *ResourceExporter) newPointBuilder(start time.Time) *pointBuilder {
+	if r.points == nil {
+		return nil
+	}
+
+	var measurement []string
+	for _, s := range []string{r.schema.PromNamespace, r.schema.PromSubsystem} {
+		if s != "" {
+			measurement = append(measurement, s)
+		}
+	}
+	if len(measurement) == 0 {
+		measurement = append(measurement, r.schema.Name)
+	}
+
+	return &pointBuilder{
+		writer:      r.points,
+		schema:      r.schema,
+		measurement: strings.Join(measurement, "_"),
+		constLabels: r.constLabels,
+		start:       start,
+	}
+}
+
+// beginRow Starts the points of the next resource row.
+func (b *pointBuilder) beginRow(ctx context.Context, item mikrotik.MikrotikItem) {
+	if b == nil {
+		return
+	}
+	b.flushRow()
+
+	b.rowTime = b.start
+	if f := b.schema.TimestampField; f != "" && item[f] != "" {
+		t, err := mikrotik.ParseTime(item[f], time.Local)
+		if err != nil {
+			zerolog.Ctx(ctx).Debug().Err(err).Str("schema", b.schema.Name).Msg("parsing row timestamp")
+		} else {
+			b.rowTime = t
+		}
+	}
+}
+
+// tags Returns the point tags of the metric.
+func (b *pointBuilder) tags(metric *ResourceMetric, labels prom.Labels) map[string]string {
+	var tags = make(map[string]string, len(labels)+len(metric.constLabels)+len(b.constLabels))
+	for k, v := range b.constLabels {
+		tags[k] = v
+	}
+	for k, v := range metric.constLabels {
+		tags[k] = v
+	}
+	for k, v := range labels {
+		tags[k] = v
+	}
+	return tags
+}
+
+// addSeries Adds the series of the accumulating metric, its value is read when the cycle is written.
+func (b *pointBuilder) addSeries(metric *ResourceMetric, labels prom.Labels, series prom.Metric) {
+	if b == nil {
+		return
+	}
+
+	tags := b.tags(metric, labels)
+	key := tagsKey(tags)
+	if b.series == nil {
+		b.series = make(map[string]*seriesPoint)
+	}
+	p, ok := b.series[key]
+	if !ok {
+		p = &seriesPoint{tags: tags, fields: make(map[string]prom.Metric)}
+		b.series[key] = p
+	}
+	p.fields[metric.PromMetricName] = series
+}
+
+// add Adds the metric value of the current row.
+func (b *pointBuilder) add(metric *ResourceMetric, labels prom.Labels, value float64) {
+	if b == nil || metric.Aggregate != nil || metric.PromMetricType == Histogram {
+		return
+	}
+
+	tags := b.tags(metric, labels)
+
+	if metric.PromMetricOperation == OperCurrTime {
+		value = float64(b.start.UnixNano()) / 1e9
+	}
+
+	key := tagsKey(tags)
+	if b.row == nil {
+		b.row = make(map[string]*Point)
+	}
+	p, ok := b.row[key]
+	if !ok {
+		p = &Point{Measurement: b.measurement, Tags: tags, Fields: make(map[string]float64), Time: b.rowTime}
+		b.row[key] = p
+	}
+	p.Fields[metric.PromMetricName] = value
+}
+
+func (b *pointBuilder) flushRow() {
+	if len(b.row) == 0 {
+		return
+	}
+
+	var keys = make([]string, 0, len(b.row))
+	for k := range b.row {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.points = append(b.points, *b.row[k])
+	}
+	clear(b.row)
+}
+
+// write Passes the points of the cycle to the writer.
+func (b *pointBuilder) write(ctx context.Context) {
+	if b == nil {
+		return
+	}
+	b.flushRow()
+
+	for _, key := range sortedKeys(b.series) {
+		s := b.series[key]
+		var p = Point{Measurement: b.measurement, Tags: s.tags, Fields: make(map[string]float64, len(s.fields)), Time: b.start}
+		for name, series := range s.fields {
+			var m dto.Metric
+			if err := series.Write(&m); err != nil {
+				continue
+			}
+			if m.Counter != nil {
+				p.Fields[name] = m.GetCounter().GetValue()
+			} else {
+				p.Fields[name] = m.GetGauge().GetValue()
+			}
+		}
+		b.points = append(b.points, p)
+	}
+
+	if len(b.points) > 0 {
+		b.writer.WritePoints(ctx, b.points)
+	}
+}
+
+func tagsKey(tags map[string]string) string {
+	var keys = make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(tags[k])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+type recordingPointWriter struct {
+	points [][]Point
+}
+
+func (w *recordingPointWriter) WritePoints(_ context.Context, points []Point) {
+	w.points = append(w.points, points)
+}
+
+// fields Returns the fields of the last cycle points by the value of the tag.
+func (w *recordingPointWriter) fields(tag string) map[string]map[string]float64 {
+	var res = make(map[string]map[string]float64)
+	for _, p := range w.points[len(w.points)-1] {
+		f, ok := res[p.Tags[tag]]
+		if !ok {
+			f = make(map[string]float64)
+			res[p.Tags[tag]] = f
+		}
+		for k, v := range p.Fields {
+			f[k] = v
+		}
+	}
+	return res
+}
+
+func TestPointsAccumulatingMetrics(t *testing.T) {
+	router, ctx := newTestRouter(t)
+	router.set("/interface",
+		mikrotik.MikrotikItem{"name": "ether1", "type": "ether", "rx-byte": "100", "mtu": "1500"},
+		mikrotik.MikrotikItem{"name": "ether2", "type": "ether", "rx-byte": "200", "mtu": "9000"},
+		mikrotik.MikrotikItem{"name": "wlan1", "type": "wlan", "rx-byte": "50", "mtu": "1500"},
+	)
+
+	r, _ := newTestExporter(t, `
+name: interface
+subsystem: interface
+resource_path: /interface
+metrics:
+  - name: mtu
+    type: GaugeVec
+    field: mtu
+    field_type: int
+    labels: {name: $name}
+  - name: count
+    type: GaugeVec
+    field: mtu
+    field_type: int
+    operation: Inc
+    reset_gauge: true
+    labels: {name: $type}
+  - name: rx_byte
+    type: CounterVec
+    field: rx-byte
+    field_type: int
+    operation: Add
+    labels: {name: $type}
+`)
+	w := &recordingPointWriter{}
+	r.SetPointWriter(w)
+
+	want := map[string]map[string]float64{
+		"ether1": {"mtu": 1500},
+		"ether2": {"mtu": 9000},
+		"wlan1":  {"mtu": 1500},
+		"ether":  {"count": 2, "rx_byte": 300},
+		"wlan":   {"count": 1, "rx_byte": 50},
+	}
+	for cycle := 1; cycle <= 2; cycle++ {
+		if _, err := r.exportMetrics(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.fields("name"); !reflect.DeepEqual(got, want) {
+			t.Errorf("cycle %d: got %v, want %v", cycle, got, want)
+		}
+
+		// The counters keep the totals, the reset gauges count the rows again
+		want["ether"]["rx_byte"] += 300
+		want["wlan"]["rx_byte"] += 50
+	}
+
+	// One point per series with the cycle start time
+	for _, p := range w.points[1] {
+		if _, ok := p.Fields["count"]; ok && len(p.Fields) != 2 {
+			t.Errorf("series point fields %v", p.Fields)
+		}
+		if p.Tags[TargetLabel] != "router" || p.Measurement != "mikrotik_interface" {
+			t.Errorf("point %+v", p)
+		}
+	}
+}
@@ -2,9 +2,8 @@ package exporter
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -20,24 +19,46 @@ type ResourceExporter struct {
 	ctx                context.Context
 	schema             *ResourceSchema
 	promMertics        map[string]any
-	globalVars         map[string]string
+	globalVars         *GlobalVars
 	collectionInterval time.Duration
+	cache              *ResourceCache
+	sysInfo            *SystemInfoWatcher
+	requirements       *RequirementsGate
+	notFound           bool
+	notFoundGeneration uint64
+	status             *StatusTracker
+	constLabels        prom.Labels
+	points             PointWriter
 }
 
+// GetCollectInterval Returns the schema collection interval or the default one.
 func (r *ResourceExporter) GetCollectInterval() time.Duration {
+	if r.schema.Interval != 0 {
+		return r.schema.Interval
+	}
 	return r.collectionInterval
 }
 
+// SetCollectInterval Sets the collection interval used if the schema doesn't set one.
 func (r *ResourceExporter) SetCollectInterval(t time.Duration) {
 	r.collectionInterval = t
 }
 
+// GetCollectTimeout Returns the timeout of a collection cycle.
+func (r *ResourceExporter) GetCollectTimeout() time.Duration {
+	if r.schema.Timeout != 0 {
+		return r.schema.Timeout
+	}
+	return r.GetCollectInterval()
+}
+
 func NewResourceExporter(ctx context.Context, schema *ResourceSchema, constLabels prometheus.Labels, reg *prom.Registry) *ResourceExporter {
 	var exporter = &ResourceExporter{
 		ctx:                ctx,
 		schema:             schema,
 		promMertics:        make(map[string]any),
 		collectionInterval: DefaultMetricsCollectionInterval,
+		constLabels:        constLabels,
 	}
 
 	for _, metric := range schema.Metrics {
@@ -49,6 +70,21 @@ func NewResourceExporter(ctx context.Context, schema *ResourceSchema, constLabel
 			cl[k] = v
 		}
 
+		if metric.Aggregate != nil {
+			var valueType = prom.GaugeValue
+			if metric.PromMetricType == CounterVec {
+				valueType = prom.CounterValue
+			}
+
+			aggregate := newAggregateCollector(
+				prom.BuildFQName(schema.PromNamespace, schema.PromSubsystem, metric.PromMetricName),
+				metric.PromMetricHelp, metric.GetLabels(), cl, metric.Aggregate.Func, valueType)
+
+			reg.MustRegister(aggregate)
+			exporter.promMertics[metric.PromMetricName] = aggregate
+			continue
+		}
+
 		switch metric.PromMetricType {
 		case CounterVec:
 			counter := promauto.NewCounterVec(prom.CounterOpts{
@@ -73,6 +109,14 @@ func NewResourceExporter(ctx context.Context, schema *ResourceSchema, constLabel
 
 			reg.MustRegister(gauge)
 			exporter.promMertics[metric.PromMetricName] = gauge
+
+		case Histogram:
+			histogram := newHistogramCollector(
+				prom.BuildFQName(schema.PromNamespace, schema.PromSubsystem, metric.PromMetricName),
+				metric.PromMetricHelp, metric.GetLabels(), cl, metric.Buckets)
+
+			reg.MustRegister(histogram)
+			exporter.promMertics[metric.PromMetricName] = histogram
 		}
 
 		// FIXME
@@ -83,7 +127,7 @@ func NewResourceExporter(ctx context.Context, schema *ResourceSchema, constLabel
 }
 
 func (r *ResourceExporter) ExportMetrics(ctx context.Context) error {
-	timer := time.NewTicker(r.collectionInterval)
+	timer := time.NewTicker(r.GetCollectInterval())
 
 	firstRun := make(chan struct{}, 1)
 	firstRun <- struct{}{}
@@ -91,13 +135,9 @@ func (r *ResourceExporter) ExportMetrics(ctx context.Context) error {
 	for done := false; !done; {
 		select {
 		case <-firstRun:
-			if err := r.exportMetrics(ctx); err != nil {
-				return fmt.Errorf("exporting metrics: %w", err)
-			}
+			r.collect(ctx)
 		case <-timer.C:
-			if err := r.exportMetrics(ctx); err != nil {
-				return fmt.Errorf("exporting metrics: %w", err)
-			}
+			r.collect(ctx)
 		case <-ctx.Done():
 			zerolog.Ctx(ctx).Debug().Msg("terminating exporter")
 			done = true
@@ -107,13 +147,70 @@ func (r *ResourceExporter) ExportMetrics(ctx context.Context) error {
 	return nil
 }
 
-func (r *ResourceExporter) exportMetrics(ctx context.Context) error {
+// collect Runs a collection cycle limited by the timeout. Errors are logged, the next cycle retries.
+func (r *ResourceExporter) collect(ctx context.Context) {
+	ctx, cancel := mikrotik.WithTimeout(ctx, r.GetCollectTimeout())
+	defer cancel()
+
+	start := time.Now()
+	if !r.isActive(ctx) {
+		r.status.Inactive(start)
+		return
+	}
+
+	rows, err := r.exportMetrics(ctx)
+	r.status.Done(start, rows, err)
+
+	logger := zerolog.Ctx(ctx)
+	switch {
+	case err == nil:
+	case errors.Is(err, mikrotik.ErrNotFound):
+		r.disableNotFound(ctx, err)
+	case mikrotik.IsTemporary(err):
+		// Retried on the next cycle
+		logger.Warn().Err(err).Str("schema", r.schema.Name).Msg("exporting metrics")
+	default:
+		logger.Err(err).Str("schema", r.schema.Name).Msg("exporting metrics")
+	}
+}
+
+// exportMetrics Updates the metrics and returns the number of rows read.
+func (r *ResourceExporter) exportMetrics(ctx context.Context) (int, error) {
 	logger := zerolog.Ctx(ctx)
+	start := time.Now()
+
 	logger.Debug().Msg("exporting resources")
 
-	mikrotikResource, err := r.ReadResource()
+	mikrotikResource, err := r.readResource(ctx, r.schema.MikrotikResourcePath, r.schema.ResourceFilter)
 	if err != nil {
-		return fmt.Errorf("reading resource: %w", err)
+		return -1, fmt.Errorf("reading resource: %w", err)
+	}
+
+	for _, j := range r.schema.Joins {
+		secondary, err := r.readResource(ctx, j.MikrotikResourcePath, j.ResourceFilter)
+		if err != nil {
+			return -1, fmt.Errorf("reading joined resource '%s': %w", j.MikrotikResourcePath, err)
+		}
+		mikrotikResource = j.apply(mikrotikResource, secondary)
+	}
+
+	// The rules are validated on load, an error here is a row the rule can't be evaluated on,
+	// e.g. without the expression field. Such a row doesn't match the rule.
+	mikrotikResource, err = FilterRows(mikrotikResource, r.schema.Include, r.schema.Exclude)
+	if err != nil {
+		logger.Warn().Err(err).Msg("filtering resource rows")
+	}
+
+	if r.schema.Command != nil && len(mikrotikResource) > 0 {
+		mikrotikResource, err = r.schema.Command.run(ctx, r.schema.MikrotikResourcePath, mikrotikResource)
+		if err != nil {
+			return -1, fmt.Errorf("running resource command '%s': %w", r.schema.Command.Name, err)
+		}
+
+		mikrotikResource, err = FilterRows(mikrotikResource, r.schema.Command.Include, nil)
+		if err != nil {
+			logger.Warn().Err(err).Msg("filtering resource command rows")
+		}
 	}
 
 	// Zeroize
@@ -123,84 +220,127 @@ func (r *ResourceExporter) exportMetrics(ctx context.Context) error {
 				g.Reset()
 			}
 		}
+		if c, ok := r.promMertics[metric.PromMetricName].(cycleCollector); ok {
+			c.begin()
+		}
 	}
 
+	globalVars := r.globalVars.Snapshot()
+	var points = r.newPointBuilder(start)
+
 	for _, instanceJSON := range mikrotikResource {
+		// Secrets are not exposed in labels and logs
+		redacted := r.schema.redactRow(instanceJSON)
+		points.beginRow(ctx, instanceJSON)
+
 		// collect metrics & labels
 		for _, metric := range r.schema.Metrics {
-			var res any
-			var err error
-			// Parse value
-			inVal := instanceJSON[metric.MtFieldName]
-			switch strings.ToLower(metric.MtFieldType) {
-			case Int:
-				res, err = strconv.ParseFloat(inVal, 64)
-				if err != nil {
-					logger.Warn().Fields(map[string]any{metric.MtFieldName: inVal}).Err(err).Msg("extracting value from resource")
-					continue
-				}
-			case Time:
-				d, err := mikrotik.ParseDuration(inVal)
-				if err != nil {
-					logger.Warn().Fields(map[string]any{metric.MtFieldName: inVal}).Err(err).Msg("extracting value from resource")
-					continue
-				}
-				res = d.Seconds()
-			case Const:
-				res = float64(1.0)
-			case Bool:
-				res = mikrotik.BoolFromMikrotikJSONToFloat(inVal)
+			var labels = make(prom.Labels, len(metric.labels))
+			for labelName, label := range metric.labels {
+				labels[labelName] = label.Get(redacted, globalVars)
 			}
 
-			var labels = make(prom.Labels, len(metric.labels))
-			for labelName, mtFieldName := range metric.labels {
-				labels[labelName] = instanceJSON[mtFieldName]
-				if v, ok := r.globalVars[mtFieldName]; ok {
-					labels[labelName] = v
-				}
+			if a, ok := r.promMertics[metric.PromMetricName].(*aggregateCollector); ok && !a.needsValue() {
+				a.observe(labels, 0, metric.distinctKey(instanceJSON))
+				continue
+			}
+
+			// Parse value
+			res, err := metric.value(instanceJSON)
+			if err != nil {
+				logger.Warn().Fields(metric.valueFields(redacted)).Err(err).Msg("extracting value from resource")
+				continue
 			}
 
 			switch m := r.promMertics[metric.PromMetricName].(type) {
 			case *prom.CounterVec:
+				c := m.With(labels)
 				if metric.PromMetricOperation == OperAdd {
-					m.With(labels).Add(res.(float64))
+					c.Add(res)
 				} else {
-					m.With(labels).Inc()
+					c.Inc()
 				}
+				points.addSeries(&metric, labels, c)
 			case *prom.GaugeVec:
+				g := m.With(labels)
 				switch metric.PromMetricOperation {
 				case OperInc:
-					m.With(labels).Inc()
+					g.Inc()
 				case OperDec:
-					m.With(labels).Dec()
+					g.Dec()
 				case OperAdd:
-					m.With(labels).Add(res.(float64))
+					g.Add(res)
 				case OperSub:
-					m.With(labels).Sub(res.(float64))
+					g.Sub(res)
 				case OperCurrTime:
-					m.With(labels).SetToCurrentTime()
+					g.SetToCurrentTime()
 				case OperSet:
 					fallthrough
 				default:
-					m.With(labels).Set(res.(float64))
+					g.Set(res)
+				}
+				if metric.accumulates() {
+					points.addSeries(&metric, labels, g)
+				} else {
+					points.add(&metric, labels, res)
 				}
+			case *histogramCollector:
+				m.observe(labels, res)
+			case *aggregateCollector:
+				m.observe(labels, res, "")
 			}
 		}
 	}
-	return err
+
+	for _, m := range r.promMertics {
+		if c, ok := m.(cycleCollector); ok {
+			c.commit()
+		}
+	}
+
+	points.write(ctx)
+
+	return len(mikrotikResource), nil
 }
 
 func (r *ResourceExporter) ReadResource() ([]mikrotik.MikrotikItem, error) {
-	if len(r.schema.ResourceFilter) == 0 {
-		return mikrotik.Read(r.schema.MikrotikResourcePath, mikrotik.Ctx(r.ctx), nil)
+	return r.readResource(r.ctx, r.schema.MikrotikResourcePath, r.schema.ResourceFilter)
+}
+
+func (r *ResourceExporter) readResource(ctx context.Context, resourcePath string, resourceFilter map[string]string) ([]mikrotik.MikrotikItem, error) {
+	if r.cache != nil {
+		return r.cache.Read(ctx, resourcePath, resourceFilter)
 	}
-	var filter []string
-	for k, v := range r.schema.ResourceFilter {
-		filter = append(filter, k+"="+v)
+	return mikrotik.ReadResource(ctx, resourcePath, resourceFilter)
+}
+
+// SetGlobalVars Sets the variables available to the schema labels.
+func (r *ResourceExporter) SetGlobalVars(g *GlobalVars) {
+	r.globalVars = g
+}
+
+// resetMetrics Removes all series of the schema metrics.
+func (r *ResourceExporter) resetMetrics() {
+	for _, m := range r.promMertics {
+		switch m := m.(type) {
+		case *prom.CounterVec:
+			m.Reset()
+		case *prom.GaugeVec:
+			m.Reset()
+		case *histogramCollector:
+			m.set(nil)
+		case *aggregateCollector:
+			m.set(nil)
+		}
 	}
-	return mikrotik.ReadFiltered(filter, r.schema.MikrotikResourcePath, mikrotik.Ctx(r.ctx), nil)
 }
 
-func (r *ResourceExporter) SetGlobalVars(m map[string]string) {
-	r.globalVars = m
+// SetStatus Sets the tracker recording the collection cycles.
+func (r *ResourceExporter) SetStatus(t *StatusTracker) {
+	r.status = t
+}
+
+// SetResourceCache Sets the cache shared with other exporters.
+func (r *ResourceExporter) SetResourceCache(c *ResourceCache) {
+	r.cache = c
 }
@@ -0,0 +1,138 @@
+package exporter
+
+import (
+	"sort"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Router labels modes.
+const (
+	// RouterLabelsInfo Only the target label on all metrics, the router details are in mikrotik_router_info
+	RouterLabelsInfo = "info"
+	// RouterLabelsLegacy The target, identity and alias labels on all metrics, the default
+	RouterLabelsLegacy = "legacy"
+)
+
+// TargetLabel Stable label identifying the router on all metrics.
+const TargetLabel = "routerboard_address"
+
+// routerInfoLabels Info metric labels and the global variables they are taken from.
+var routerInfoLabels = []struct {
+	label, variable string
+}{
+	{"identity", "ROUTER_ID"},
+	{"alias", "ALIAS"},
+	{"board_name", "BOARD_NAME"},
+	{"serial_number", "SERIAL_NUMBER"},
+	{"version", "VERSION"},
+}
+
+// RouterLabels Returns the router labels of all metrics in the mode with their current values.
+func RouterLabels(mode string, vars *GlobalVars) prom.Labels {
+	var res = prom.Labels{TargetLabel: vars.Get("HOSTURL")}
+	if mode == RouterLabelsLegacy {
+		res["routerboard_id"] = vars.Get("ROUTER_ID")
+		res["routerboard_alias"] = vars.Get("ALIAS")
+	}
+	return res
+}
+
+// NewRouterLabelsGatherer Returns the gatherer adding the router labels of the mode other than the target
+// label to the gathered metrics. The values are taken on every gather, so the legacy labels follow
+// identity changes like mikrotik_router_info. The metrics are registered with the target label only.
+func NewRouterLabelsGatherer(g prom.Gatherer, mode string, vars *GlobalVars) prom.Gatherer {
+	if mode != RouterLabelsLegacy {
+		return g
+	}
+
+	return prom.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := g.Gather()
+
+		labels := RouterLabels(mode, vars)
+		delete(labels, TargetLabel)
+		for _, mf := range families {
+			for _, m := range mf.GetMetric() {
+				m.Label = addLabels(m.GetLabel(), labels)
+			}
+		}
+
+		return families, err
+	})
+}
+
+// addLabels Adds the labels the metric doesn't have, keeping the pairs sorted by name.
+func addLabels(pairs []*dto.LabelPair, labels prom.Labels) []*dto.LabelPair {
+	var names = make(map[string]bool, len(pairs))
+	for _, p := range pairs {
+		names[p.GetName()] = true
+	}
+
+	for name, value := range labels {
+		if !names[name] {
+			pairs = append(pairs, &dto.LabelPair{Name: &name, Value: &value})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].GetName() < pairs[j].GetName()
+	})
+
+	return pairs
+}
+
+// routerInfoCollector Exposes mikrotik_router_info with the current global variables,
+// so identity changes and upgrades only change this series:
+//
+//	mikrotik_interface_rx_byte_total * on(routerboard_address) group_left(identity) mikrotik_router_info
+type routerInfoCollector struct {
+	desc *prom.Desc
+	vars *GlobalVars
+}
+
+// NewRouterInfoCollector Creates the mikrotik_router_info collector.
+func NewRouterInfoCollector(vars *GlobalVars) prom.Collector {
+	var labelNames = make([]string, len(routerInfoLabels))
+	for i, l := range routerInfoLabels {
+		labelNames[i] = l.label
+	}
+
+	return &routerInfoCollector{
+		desc: prom.NewDesc("mikrotik_router_info", "Router identity, board, serial number, RouterOS version and alias",
+			labelNames, prom.Labels{TargetLabel: vars.Get("HOSTURL")}),
+		vars: vars,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *routerInfoCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *routerInfoCollector) Collect(ch chan<- prom.Metric) {
+	vars := c.vars.Snapshot()
+
+	var values = make([]string, len(routerInfoLabels))
+	for i, l := range routerInfoLabels {
+		values[i] = vars[l.variable]
+	}
+
+	ch <- prom.MustNewConstMetric(c.desc, prom.GaugeValue, 1, values...)
+}
+
+// RouterResourceAttributes Returns the OpenTelemetry resource attributes identifying the router,
+// they replace the router labels in the OTLP output. Unknown values are empty.
+func RouterResourceAttributes(vars *GlobalVars) map[string]string {
+	v := vars.Snapshot()
+	return map[string]string{
+		"service.name":        "mikrotik-prom-exporter",
+		"service.instance.id": v["HOSTURL"],
+		"host.name":           v["ROUTER_ID"],
+		"host.id":             v["SERIAL_NUMBER"],
+		"device.model.name":   v["BOARD_NAME"],
+		"os.name":             "RouterOS",
+		"os.version":          v["VERSION"],
+		"mikrotik.alias":      v["ALIAS"],
+	}
+}
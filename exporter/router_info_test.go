@@ -0,0 +1,147 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func testRouterVars() *GlobalVars {
+	return NewGlobalVars(map[string]string{
+		"HOSTURL":       "10.0.0.1:443",
+		"ROUTER_ID":     "core",
+		"ALIAS":         "dc1-core",
+		"BOARD_NAME":    "CCR2004",
+		"SERIAL_NUMBER": "HF1234",
+		"VERSION":       "7.15.2",
+	}, nil)
+}
+
+// setVar Sets the variable as a refresh from the router would.
+func setVar(g *GlobalVars, name, value string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.vars[name] = value
+}
+
+func metricLabels(m *dto.Metric) map[string]string {
+	var res = make(map[string]string, len(m.GetLabel()))
+	for _, l := range m.GetLabel() {
+		res[l.GetName()] = l.GetValue()
+	}
+	return res
+}
+
+func TestRouterLabels(t *testing.T) {
+	vars := testRouterVars()
+
+	tests := map[string]prom.Labels{
+		RouterLabelsInfo: {TargetLabel: "10.0.0.1:443"},
+		RouterLabelsLegacy: {
+			TargetLabel:         "10.0.0.1:443",
+			"routerboard_id":    "core",
+			"routerboard_alias": "dc1-core",
+		},
+	}
+	for mode, want := range tests {
+		if got := RouterLabels(mode, vars); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestRouterLabelsGatherer(t *testing.T) {
+	vars := testRouterVars()
+
+	reg := prom.NewRegistry()
+	c := prom.NewCounterVec(prom.CounterOpts{
+		Name:        "test_rx_byte_total",
+		Help:        "test",
+		ConstLabels: prom.Labels{TargetLabel: vars.Get("HOSTURL")},
+	}, []string{"name", "routerboard_id"})
+	c.WithLabelValues("ether1", "").Inc()
+	c.WithLabelValues("ether2", "schema").Inc()
+	reg.MustRegister(c)
+
+	gather := func(mode string) []*dto.Metric {
+		t.Helper()
+		families, err := NewRouterLabelsGatherer(reg, mode, vars).Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(families) != 1 || len(families[0].GetMetric()) != 2 {
+			t.Fatalf("unexpected families %v", families)
+		}
+		return families[0].GetMetric()
+	}
+
+	// Only the target label in the info mode
+	for _, m := range gather(RouterLabelsInfo) {
+		if l := metricLabels(m); l["routerboard_alias"] != "" || len(l) != 3 {
+			t.Errorf("info mode labels are %v", l)
+		}
+	}
+
+	metrics := gather(RouterLabelsLegacy)
+	want := map[string]string{"name": "ether1", "routerboard_address": "10.0.0.1:443", "routerboard_id": "", "routerboard_alias": "dc1-core"}
+	if got := metricLabels(metrics[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("legacy mode labels are %v, want %v", got, want)
+	}
+	// The metric label is kept
+	if got := metricLabels(metrics[1]); got["routerboard_id"] != "schema" {
+		t.Errorf("the metric routerboard_id label is replaced: %v", got)
+	}
+	for _, m := range metrics {
+		for i := 1; i < len(m.GetLabel()); i++ {
+			if m.GetLabel()[i-1].GetName() >= m.GetLabel()[i].GetName() {
+				t.Errorf("labels %v are not sorted", m.GetLabel())
+			}
+		}
+	}
+
+	// The labels follow the refreshed variables
+	setVar(vars, "ALIAS", "dc2-core")
+	if got := metricLabels(gather(RouterLabelsLegacy)[0]); got["routerboard_alias"] != "dc2-core" {
+		t.Errorf("routerboard_alias is '%s' after the refresh, want 'dc2-core'", got["routerboard_alias"])
+	}
+}
+
+func TestRouterInfoCollector(t *testing.T) {
+	vars := testRouterVars()
+	reg := prom.NewRegistry()
+	reg.MustRegister(NewRouterInfoCollector(vars))
+
+	check := func(want map[string]string) {
+		t.Helper()
+		families, err := reg.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(families) != 1 || len(families[0].GetMetric()) != 1 {
+			t.Fatalf("unexpected families %v", families)
+		}
+		m := families[0].GetMetric()[0]
+		if got := metricLabels(m); !reflect.DeepEqual(got, want) {
+			t.Errorf("got labels %v, want %v", got, want)
+		}
+		if m.GetGauge().GetValue() != 1 {
+			t.Errorf("value is %v, want 1", m.GetGauge().GetValue())
+		}
+	}
+
+	want := map[string]string{
+		TargetLabel:     "10.0.0.1:443",
+		"identity":      "core",
+		"alias":         "dc1-core",
+		"board_name":    "CCR2004",
+		"serial_number": "HF1234",
+		"version":       "7.15.2",
+	}
+	check(want)
+
+	setVar(vars, "VERSION", "7.16")
+	want["version"] = "7.16"
+	check(want)
+}
@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// testRouter Fake RouterOS REST API. GET returns the rows of the path matching the query,
+// POST runs the command handler of the path.
+type testRouter struct {
+	mu       sync.Mutex
+	rows     map[string][]mikrotik.MikrotikItem
+	commands map[string]func(args map[string]string) []mikrotik.MikrotikItem
+	// requests Requests by method and path
+	requests map[string]int
+}
+
+// newTestRouter Starts the router and returns the context with its client.
+func newTestRouter(t *testing.T) (*testRouter, context.Context) {
+	t.Helper()
+
+	r := &testRouter{
+		rows:     make(map[string][]mikrotik.MikrotikItem),
+		commands: make(map[string]func(args map[string]string) []mikrotik.MikrotikItem),
+		requests: make(map[string]int),
+	}
+	srv := httptest.NewTLSServer(r)
+	t.Cleanup(srv.Close)
+
+	ctx := context.Background()
+	client, err := mikrotik.NewClient(ctx, &mikrotik.Config{
+		HostURL:     srv.URL,
+		Insecure:    true,
+		Credentials: &mikrotik.Credentials{Username: "test"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r, client.WithContext(ctx)
+}
+
+// set Sets the rows of the resource path.
+func (r *testRouter) set(path string, rows ...mikrotik.MikrotikItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rows[path] = rows
+}
+
+// command Sets the handler of the command path.
+func (r *testRouter) command(path string, h func(args map[string]string) []mikrotik.MikrotikItem) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[path] = h
+}
+
+// count Returns the number of requests with the method and path.
+func (r *testRouter) count(method, path string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests[method+" "+path]
+}
+
+func (r *testRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.TrimPrefix(req.URL.Path, "/rest")
+
+	r.mu.Lock()
+	r.requests[req.Method+" "+path]++
+	rows, found := r.rows[path]
+	command := r.commands[path]
+	r.mu.Unlock()
+
+	var res = []mikrotik.MikrotikItem{}
+	switch {
+	case req.Method == http.MethodPost && command != nil:
+		var args map[string]string
+		body, _ := io.ReadAll(req.Body)
+		_ = json.Unmarshal(body, &args)
+		res = command(args)
+	case req.Method == http.MethodGet && found:
+	next:
+		for _, row := range rows {
+			for k, v := range req.URL.Query() {
+				if row[k] != v[0] {
+					continue next
+				}
+			}
+			res = append(res, row)
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":400,"message":"Bad Request","detail":"no such command or directory"}`))
+		return
+	}
+
+	// Without a trailing newline as RouterOS, an empty table is exactly []
+	body, _ := json.Marshal(res)
+	_, _ = w.Write(body)
+}
+
+// newTestExporter Parses the schema and creates its exporter with a new registry.
+func newTestExporter(t *testing.T, schema string) (*ResourceExporter, *prom.Registry) {
+	t.Helper()
+
+	s, err := parseTestSchema(t, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := prom.NewRegistry()
+	return NewResourceExporter(context.Background(), s, prom.Labels{TargetLabel: "router"}, reg), reg
+}
@@ -0,0 +1,153 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+	"gopkg.in/yaml.v3"
+)
+
+// RowRule Condition applied to the resource rows after reading.
+// All conditions specified in the rule must match:
+//
+//	include_rows:
+//	  - field: name
+//	    regex: '^ether'
+//	exclude_rows:
+//	  - field: dynamic          # the field value is true/yes
+//	  - field: type
+//	    equals: vlan
+//	  - expr: rx-byte == 0
+type RowRule struct {
+	// Field Mikrotik field name. Without 'equals' and 'regex' the field value must be true/yes.
+	Field string `yaml:"field,omitempty"`
+	// Equals The field value must be equal to this string
+	Equals *string `yaml:"equals,omitempty"`
+	// Regex The field value must match the regular expression
+	Regex string `yaml:"regex,omitempty"`
+	// Expr The expression evaluated over the row must be non-zero
+	Expr string `yaml:"expr,omitempty"`
+
+	re   *regexp.Regexp
+	expr *Expr
+}
+
+// UnmarshalYAML Implements yaml.Unmarshaler to validate the rule while parsing.
+func (r *RowRule) UnmarshalYAML(node *yaml.Node) error {
+	type plain RowRule
+	if err := node.Decode((*plain)(r)); err != nil {
+		return err
+	}
+
+	return r.Compile()
+}
+
+// Compile Validates the rule and compiles its regular expression and expression.
+func (r *RowRule) Compile() error {
+	if r.Field == "" && r.Expr == "" {
+		return errors.New("rule must have 'field' or 'expr'")
+	}
+
+	if r.Field == "" && (r.Equals != nil || r.Regex != "") {
+		return errors.New("rule conditions 'equals' and 'regex' require 'field'")
+	}
+
+	if r.Regex != "" {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return fmt.Errorf("rule regex: %w", err)
+		}
+		r.re = re
+	}
+
+	if r.Expr != "" {
+		expr, err := ParseExpr(r.Expr)
+		if err != nil {
+			return fmt.Errorf("rule: %w", err)
+		}
+		r.expr = expr
+	}
+
+	return nil
+}
+
+// ValidateRules Checks the rules of the list are set and valid.
+// The list name is only used in the error message.
+func ValidateRules(name string, rules []*RowRule) error {
+	for i, r := range rules {
+		if r == nil {
+			return fmt.Errorf("%s rule %d is empty", name, i+1)
+		}
+		if err := r.Compile(); err != nil {
+			return fmt.Errorf("%s rule %d: %w", name, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Match Returns true if the resource row satisfies all the rule conditions.
+func (r *RowRule) Match(item mikrotik.MikrotikItem) (bool, error) {
+	if r.Field != "" {
+		v := item[r.Field]
+
+		if r.Equals == nil && r.re == nil && mikrotik.BoolFromMikrotikJSONToFloat(v) == 0 {
+			return false, nil
+		}
+
+		if r.Equals != nil && v != *r.Equals {
+			return false, nil
+		}
+
+		if r.re != nil && !r.re.MatchString(v) {
+			return false, nil
+		}
+	}
+
+	if r.expr != nil {
+		res, err := r.expr.Eval(item)
+		if err != nil {
+			return false, err
+		}
+		return res != 0, nil
+	}
+
+	return true, nil
+}
+
+// FilterRows Keeps the rows matching any of the include rules (all rows if there are none)
+// and not matching any of the exclude rules.
+// A rule that fails to evaluate doesn't match, the first such error is returned along with the result.
+func FilterRows(items []mikrotik.MikrotikItem, include, exclude []*RowRule) ([]mikrotik.MikrotikItem, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return items, nil
+	}
+
+	var firstErr error
+	matchAny := func(item mikrotik.MikrotikItem, rules []*RowRule) bool {
+		for _, rule := range rules {
+			ok, err := rule.Match(item)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	var res = make([]mikrotik.MikrotikItem, 0, len(items))
+	for _, item := range items {
+		if len(include) > 0 && !matchAny(item, include) {
+			continue
+		}
+		if matchAny(item, exclude) {
+			continue
+		}
+		res = append(res, item)
+	}
+
+	return res, firstErr
+}
@@ -0,0 +1,203 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRowRuleMatch(t *testing.T) {
+	item := mikrotik.MikrotikItem{
+		"name":    "ether1",
+		"type":    "ether",
+		"running": "true",
+		"dynamic": "false",
+		"comment": "",
+		"rx-byte": "1024",
+	}
+
+	tests := []struct {
+		rule string
+		want bool
+		err  string
+	}{
+		{rule: `{field: running}`, want: true},
+		{rule: `{field: dynamic}`, want: false},
+		{rule: `{field: missing}`, want: false},
+		{rule: `{field: type, equals: ether}`, want: true},
+		{rule: `{field: type, equals: vlan}`, want: false},
+		{rule: `{field: comment, equals: ''}`, want: true},
+		// A missing field is an empty value
+		{rule: `{field: missing, equals: ''}`, want: true},
+		{rule: `{field: name, regex: '^ether'}`, want: true},
+		{rule: `{field: name, regex: '^sfp'}`, want: false},
+		// All conditions must match
+		{rule: `{field: name, equals: ether1, regex: '^sfp'}`, want: false},
+		{rule: `{field: name, regex: '^ether', expr: rx-byte > 1000}`, want: true},
+		{rule: `{field: name, regex: '^ether', expr: rx-byte == 0}`, want: false},
+		{rule: `{field: dynamic, expr: rx-byte > 1000}`, want: false},
+		{rule: `{expr: running && !dynamic}`, want: true},
+		{rule: `{expr: has(tx-byte)}`, want: false},
+		{rule: `{expr: 'tx-byte ?? 0 == 0'}`, want: true},
+		// A missing field is only equal to an empty value
+		{rule: `{expr: tx-byte == 0}`, want: false},
+		{rule: `{expr: tx-byte > 0}`, want: false, err: "field 'tx-byte' is missing"},
+		{rule: `{expr: name + 1 > 0}`, want: false, err: "field 'name' value 'ether1' is not a number"},
+	}
+
+	for _, tt := range tests {
+		var r RowRule
+		if err := yaml.Unmarshal([]byte(tt.rule), &r); err != nil {
+			t.Fatalf("%s: %v", tt.rule, err)
+		}
+
+		got, err := r.Match(item)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error '%v'", tt.rule, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: got error '%v', want '%s'", tt.rule, err, tt.err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.rule, got, tt.want)
+		}
+	}
+}
+
+func TestRowRuleErrors(t *testing.T) {
+	tests := []struct {
+		rule string
+		err  string
+	}{
+		{rule: `{equals: x}`, err: "rule must have 'field' or 'expr'"},
+		{rule: `{expr: a > 0, regex: x}`, err: "rule conditions 'equals' and 'regex' require 'field'"},
+		{rule: `{field: name, regex: '[a-'}`, err: "rule regex:"},
+		{rule: `{expr: 'rx-byte >'}`, err: "rule: parsing expression 'rx-byte >'"},
+		{rule: `{expr: 'nope(rx-byte)'}`, err: "unknown function 'nope'"},
+	}
+
+	for _, tt := range tests {
+		var r RowRule
+		if err := yaml.Unmarshal([]byte(tt.rule), &r); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error '%v', want '%s'", tt.rule, err, tt.err)
+		}
+	}
+}
+
+func TestFilterRows(t *testing.T) {
+	items := []mikrotik.MikrotikItem{
+		{"name": "ether1", "type": "ether", "dynamic": "false", "rx-byte": "10"},
+		{"name": "ether2", "type": "ether", "dynamic": "true", "rx-byte": "0"},
+		{"name": "vlan10", "type": "vlan", "dynamic": "false"},
+		{"name": "bridge", "type": "bridge", "dynamic": "false", "rx-byte": "5"},
+	}
+	rules := func(s string) []*RowRule {
+		var res []*RowRule
+		if err := yaml.Unmarshal([]byte(s), &res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	tests := []struct {
+		name    string
+		include string
+		exclude string
+		want    string
+		err     string
+	}{
+		{name: "no rules", want: "ether1,ether2,vlan10,bridge"},
+		{name: "include any", include: `[{field: type, equals: ether}, {field: name, equals: bridge}]`, want: "ether1,ether2,bridge"},
+		{name: "exclude any", exclude: `[{field: dynamic}, {field: type, equals: vlan}]`, want: "ether1,bridge"},
+		{name: "include and exclude", include: `[{field: name, regex: '^ether'}]`, exclude: `[{field: dynamic}]`, want: "ether1"},
+		// The row without the field doesn't match, the error is returned with the result
+		{name: "failing rule", include: `[{expr: rx-byte > 0}]`, want: "ether1,bridge", err: "field 'rx-byte' is missing"},
+		{name: "failing exclude keeps the row", exclude: `[{expr: rx-byte < 1}]`, want: "ether1,vlan10,bridge", err: "field 'rx-byte' is missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := FilterRows(items, rules(tt.include), rules(tt.exclude))
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error '%v'", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+
+			var names []string
+			for _, item := range res {
+				names = append(names, item["name"])
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemaRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{
+			name:   "valid",
+			schema: "include_rows: [{field: name, regex: '^ether'}]\nexclude_rows: [{expr: rx-byte == 0}]",
+		},
+		{
+			name:   "include regex",
+			schema: "include_rows: [{field: name, regex: '(ether'}]",
+			err:    "rule regex: error parsing regexp",
+		},
+		{
+			name:   "exclude expr",
+			schema: "exclude_rows: [{field: name}, {expr: rx-byte ==}]",
+			err:    "parsing expression 'rx-byte =='",
+		},
+		{
+			name:   "empty rule",
+			schema: "exclude_rows: [{field: name}, ~]",
+			err:    "exclude_rows rule 2 is empty",
+		},
+		{
+			name:   "command rule",
+			schema: "command:\n  name: monitor\n  include_rows: [~]",
+			err:    "command include_rows rule 1 is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema(t, "resource_path: /interface\nmetrics: []\n"+tt.schema)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error '%v'", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	// Rules built in code are compiled by the validation
+	rules := []*RowRule{{Field: "name", Regex: "^ether"}, {Expr: "rx-byte > 0"}}
+	if err := ValidateRules("include_rows", rules); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := rules[0].Match(mikrotik.MikrotikItem{"name": "sfp1"}); ok || err != nil {
+		t.Errorf("regex is not compiled: %v, %v", ok, err)
+	}
+	if ok, err := rules[1].Match(mikrotik.MikrotikItem{"rx-byte": "0"}); ok || err != nil {
+		t.Errorf("expr is not compiled: %v, %v", ok, err)
+	}
+
+	err := ValidateRules("exclude_rows", []*RowRule{{Field: "name"}, {Field: "name", Regex: "[a-"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "exclude_rows rule 2: rule regex:") {
+		t.Errorf("got error '%v'", err)
+	}
+}
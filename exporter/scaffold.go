@@ -0,0 +1,224 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// Kinds of the resource fields inferred by the scaffold.
+const (
+	FieldBool       = "bool"
+	FieldInt        = "int"
+	FieldDuration   = "duration"
+	FieldQuantity   = "quantity"
+	FieldIdentifier = "identifier"
+	FieldLabel      = "label"
+	FieldText       = "text"
+	FieldEmpty      = "empty"
+	// FieldSensitive Field holding a secret, see mikrotik.SensitiveFields
+	FieldSensitive = "sensitive"
+)
+
+// ScaffoldMaxLabelValues Maximum number of distinct values of a string field suggested as a label.
+const ScaffoldMaxLabelValues = 10
+
+// identifierFields Fields that identify a row in most RouterOS resources.
+var identifierFields = map[string]bool{
+	"name":         true,
+	"default-name": true,
+	"comment":      true,
+	"interface":    true,
+	"mac-address":  true,
+	"address":      true,
+	"host-name":    true,
+	"ssid":         true,
+	"server":       true,
+	"pool":         true,
+}
+
+var counterFieldRe = regexp.MustCompile(`(^|-)(bytes?|packets?|errors?|drops?|downs)($|-)`)
+
+// ScaffoldField Resource field with the type inferred from its values.
+type ScaffoldField struct {
+	Name string
+	Kind string
+	// Unit Unit of the quantity fields without the multiplier prefix
+	Unit string
+	// Distinct Number of distinct non-empty values
+	Distinct int
+	// Sample First non-empty value
+	Sample string
+}
+
+// InferFields Infers the field types from the resource rows. Fields are sorted by name,
+// internal fields (.id, .nextid, .dead) are skipped.
+func InferFields(items []mikrotik.MikrotikItem) []ScaffoldField {
+	var values = make(map[string][]string)
+	for _, item := range items {
+		for k, v := range item {
+			if strings.HasPrefix(k, ".") {
+				continue
+			}
+			values[k] = append(values[k], v)
+		}
+	}
+
+	var res = make([]ScaffoldField, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		res = append(res, inferField(name, values[name], len(items)))
+	}
+	return res
+}
+
+func inferField(name string, values []string, rows int) ScaffoldField {
+	var f = ScaffoldField{Name: name}
+	var distinct = make(map[string]struct{})
+	var isBool, isInt, isDuration, isQuantity = true, true, true, true
+
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if f.Sample == "" {
+			f.Sample = v
+		}
+		distinct[v] = struct{}{}
+
+		switch strings.ToLower(v) {
+		case "true", "false", "yes", "no":
+		default:
+			isBool = false
+		}
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			isInt = false
+		}
+		if strings.IndexFunc(v, func(r rune) bool { return r >= 'a' && r <= 'z' || r == ':' }) < 0 {
+			isDuration = false
+		} else if _, err := mikrotik.ParseDuration(v); err != nil {
+			isDuration = false
+		}
+		if _, unit, err := parseQuantity(v); err != nil || unit == "" || f.Unit != "" && unit != f.Unit {
+			isQuantity = false
+		} else {
+			f.Unit = unit
+		}
+	}
+	f.Distinct = len(distinct)
+
+	switch {
+	case f.Distinct == 0:
+		f.Kind = FieldEmpty
+	case isBool:
+		f.Kind = FieldBool
+	case isInt:
+		f.Kind = FieldInt
+	case isDuration:
+		f.Kind = FieldDuration
+	case isQuantity:
+		f.Kind = FieldQuantity
+	case identifierFields[name] || rows > 1 && f.Distinct == rows:
+		f.Kind = FieldIdentifier
+	case f.Distinct <= ScaffoldMaxLabelValues:
+		f.Kind = FieldLabel
+	default:
+		f.Kind = FieldText
+	}
+	if f.Kind != FieldQuantity {
+		f.Unit = ""
+	}
+
+	return f
+}
+
+// Scaffold Writes a commented schema draft for the resource rows.
+// String fields are suggested as labels and numeric ones as metrics.
+func Scaffold(w io.Writer, resourcePath string, items []mikrotik.MikrotikItem) error {
+	var fields = InferFields(items)
+	for i, f := range fields {
+		if mikrotik.IsSensitiveField(resourcePath, f.Name) {
+			fields[i].Kind, fields[i].Sample = FieldSensitive, mikrotik.RedactedValue
+		}
+	}
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Schema draft generated from %d rows of %s.\n", len(items), resourcePath)
+	fmt.Fprintf(&b, "# Review the field types, labels and help before use.\n")
+	fmt.Fprintf(&b, "namespace: mikrotik\n")
+	fmt.Fprintf(&b, "subsystem: %s\n", labelName(strings.Trim(resourcePath, "/")))
+	fmt.Fprintf(&b, "resource_path: %s\n", resourcePath)
+
+	b.WriteString("\nglobal_labels:\n")
+	for _, f := range fields {
+		switch f.Kind {
+		case FieldIdentifier:
+			fmt.Fprintf(&b, "  %s: $%s # identifier, %d distinct values\n", labelName(f.Name), f.Name, f.Distinct)
+		case FieldLabel:
+			fmt.Fprintf(&b, "  %s: $%s # %d distinct values\n", labelName(f.Name), f.Name, f.Distinct)
+		case FieldText:
+			fmt.Fprintf(&b, "  # %s: $%s # high cardinality, %d distinct values\n", labelName(f.Name), f.Name, f.Distinct)
+		case FieldSensitive:
+			fmt.Fprintf(&b, "  # %s: $%s # secret, not exported\n", labelName(f.Name), f.Name)
+		}
+	}
+
+	b.WriteString("\nmetrics:\n")
+	var metrics int
+	for _, f := range fields {
+		name, help := labelName(f.Name), f.Name
+
+		var fieldType, expr string
+		switch f.Kind {
+		case FieldBool:
+			fieldType = Bool
+		case FieldInt:
+			fieldType = Int
+			if counterFieldRe.MatchString(f.Name) {
+				name += "_total"
+			}
+		case FieldDuration:
+			fieldType, name = Time, name+"_seconds"
+		case FieldQuantity:
+			expr = fmt.Sprintf("quantity(%s)", f.Name)
+			help = fmt.Sprintf("%s, %s", f.Name, f.Unit)
+		default:
+			continue
+		}
+
+		fmt.Fprintf(&b, "  - name: %s\n", name)
+		fmt.Fprintf(&b, "    help: %s\n", help)
+		fmt.Fprintf(&b, "    type: %s\n", GaugeVec)
+		if expr != "" {
+			fmt.Fprintf(&b, "    expr: %s # e.g. %s\n", expr, f.Sample)
+		} else {
+			fmt.Fprintf(&b, "    field: %s # e.g. %s\n", f.Name, f.Sample)
+			fmt.Fprintf(&b, "    field_type: %s\n", fieldType)
+		}
+		metrics++
+	}
+
+	if metrics == 0 {
+		b.WriteString("  # no numeric fields found, a const metric exposes the labels\n")
+		b.WriteString("  - name: info\n")
+		b.WriteString("    help: Resource information\n")
+		fmt.Fprintf(&b, "    type: %s\n", GaugeVec)
+		fmt.Fprintf(&b, "    field_type: %s\n", Const)
+	}
+
+	var empty []string
+	for _, f := range fields {
+		if f.Kind == FieldEmpty {
+			empty = append(empty, f.Name)
+		}
+	}
+	if len(empty) > 0 {
+		fmt.Fprintf(&b, "\n# Fields without values: %s\n", strings.Join(empty, ", "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+func scaffoldTestRows() []mikrotik.MikrotikItem {
+	return []mikrotik.MikrotikItem{
+		{".id": "*1", "name": "ether1", "type": "ether", "running": "true", "rx-byte": "1024", "mtu": "1500",
+			"uptime": "1d2h3m", "rate": "1Gbps", "mode": "5Mode", "comment": "", "password": "s3cret"},
+		{".id": "*2", "name": "ether2", "type": "ether", "running": "false", "rx-byte": "0", "mtu": "1500",
+			"uptime": "15s", "rate": "100Mbps", "mode": "7Mode", "comment": "", "password": "s3cret"},
+		{".id": "*3", "name": "wlan1", "type": "wlan", "running": "yes", "rx-byte": "12.5", "mtu": "1500",
+			"uptime": "00:01:02", "rate": "54Mbps", "mode": "1Mode", "comment": "", "password": "s3cret"},
+	}
+}
+
+func TestInferFields(t *testing.T) {
+	want := map[string]ScaffoldField{
+		"comment": {Kind: FieldEmpty},
+		// Not multiplied, M is not a prefix of a known unit
+		"mode": {Kind: FieldQuantity, Unit: "Mode", Distinct: 3, Sample: "5Mode"},
+		"mtu":  {Kind: FieldInt, Distinct: 1, Sample: "1500"},
+		"name": {Kind: FieldIdentifier, Distinct: 3, Sample: "ether1"},
+		// The field is sensitive in the scaffold, not in the inferred types
+		"password": {Kind: FieldLabel, Distinct: 1, Sample: "s3cret"},
+		"rate":     {Kind: FieldQuantity, Unit: "bps", Distinct: 3, Sample: "1Gbps"},
+		"running":  {Kind: FieldBool, Distinct: 3, Sample: "true"},
+		"rx-byte":  {Kind: FieldInt, Distinct: 3, Sample: "1024"},
+		"type":     {Kind: FieldLabel, Distinct: 2, Sample: "ether"},
+		"uptime":   {Kind: FieldDuration, Distinct: 3, Sample: "1d2h3m"},
+	}
+
+	fields := InferFields(scaffoldTestRows())
+	if len(fields) != len(want) {
+		t.Fatalf("got %d fields %v, want %d", len(fields), fields, len(want))
+	}
+	for i, f := range fields {
+		if i > 0 && fields[i-1].Name >= f.Name {
+			t.Errorf("fields are not sorted: %s >= %s", fields[i-1].Name, f.Name)
+		}
+		w, ok := want[f.Name]
+		if !ok {
+			t.Errorf("unexpected field %+v", f)
+			continue
+		}
+		w.Name = f.Name
+		if f != w {
+			t.Errorf("got %+v, want %+v", f, w)
+		}
+	}
+}
+
+func TestInferFieldKinds(t *testing.T) {
+	tests := []struct {
+		values []string
+		kind   string
+		unit   string
+	}{
+		{values: []string{"-62dBm", "-70dBm"}, kind: FieldQuantity, unit: "dBm"},
+		{values: []string{"12KiB", "1.5MiB"}, kind: FieldQuantity, unit: "B"},
+		// Mixed units are not a quantity
+		{values: []string{"12KiB", "3Mbps"}, kind: FieldLabel},
+		{values: []string{"1", "", "2"}, kind: FieldInt},
+		{values: []string{"yes", "no"}, kind: FieldBool},
+		{values: []string{"1w2d", "3h"}, kind: FieldDuration},
+		{values: []string{"", ""}, kind: FieldEmpty},
+	}
+
+	for _, tt := range tests {
+		f := inferField("value", tt.values, len(tt.values)+1)
+		if f.Kind != tt.kind || f.Unit != tt.unit {
+			t.Errorf("%q: got kind '%s' unit '%s', want '%s' '%s'", tt.values, f.Kind, f.Unit, tt.kind, tt.unit)
+		}
+	}
+}
+
+func TestScaffold(t *testing.T) {
+	var b strings.Builder
+	if err := Scaffold(&b, "/interface", scaffoldTestRows()); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, s := range []string{
+		"subsystem: interface\n",
+		"  name: $name # identifier, 3 distinct values\n",
+		"  type: $type # 2 distinct values\n",
+		"  # password: $password # secret, not exported\n",
+		"  - name: rx_byte_total\n",
+		"  - name: uptime_seconds\n",
+		"    expr: quantity(rate) # e.g. 1Gbps\n",
+		"    help: rate, bps\n",
+		"# Fields without values: comment\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("scaffold doesn't contain %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Errorf("scaffold exposes the secret:\n%s", out)
+	}
+
+	// The draft is a valid schema
+	file := filepath.Join(t.TempDir(), "interface.yaml")
+	if err := os.WriteFile(file, []byte(out), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := SchemaParser(file)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, out)
+	}
+	if len(s.Metrics) != 6 {
+		t.Errorf("got %d metrics, want 6:\n%s", len(s.Metrics), out)
+	}
+}
+
+func TestScaffoldWithoutMetrics(t *testing.T) {
+	var b strings.Builder
+	rows := []mikrotik.MikrotikItem{{"name": "main"}, {"name": "guest"}}
+	if err := Scaffold(&b, "/ip/dhcp-server", rows); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(b.String(), "  - name: info\n") {
+		t.Errorf("scaffold without numeric fields has no info metric:\n%s", b.String())
+	}
+}
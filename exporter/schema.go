@@ -1,7 +1,14 @@
 package exporter
 
 import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
 )
 
 type MetricType uint
@@ -9,8 +16,10 @@ type MetricType uint
 const (
 	CounterVec = "CounterVec"
 	GaugeVec   = "GaugeVec"
+	Histogram  = "Histogram"
 
 	Int   = "int"
+	Float = "float"
 	Time  = "time"
 	Const = "const"
 	Bool  = "bool"
@@ -24,17 +33,156 @@ const (
 )
 
 type ResourceSchema struct {
+	// Name Schema name used in configuration, defaults to the schema file name without extension
+	Name string `yaml:"name,omitempty"`
 	// https://pkg.go.dev/github.com/prometheus/client_golang/prometheus#BuildFQName
 	PromNamespace string `yaml:"namespace"`
 	PromSubsystem string `yaml:"subsystem"`
 	// ResourcePath Resource path in routeros
 	MikrotikResourcePath string `yaml:"resource_path"`
 	// PromGlobalLabels Global map of labels and label values that will contain all resource metrics
-	PromGlobalLabels prom.Labels `yaml:"global_labels,omitempty"`
+	PromGlobalLabels map[string]*LabelSpec `yaml:"global_labels,omitempty"`
 	// ResourceFilter Filter executed on find to select interfaces (optional)
 	ResourceFilter map[string]string `yaml:"resource_filter,omitempty"`
+	// Requires RouterOS version, packages and board the schema applies to (optional)
+	Requires mikrotik.Requirements `yaml:"requires,omitempty"`
+	// Interval Collection interval, the --interval flag value by default (optional)
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Timeout Timeout of a collection cycle, the collection interval by default (optional)
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// Command Resource command (e.g. monitor) executed once for all listed rows (optional)
+	Command *CommandSpec `yaml:"command,omitempty"`
+	// Joins Secondary resources whose fields are attached to the resource rows (optional)
+	Joins []*Join `yaml:"joins,omitempty"`
+	// Include Rules selecting resource rows after reading, a row must match any of them (optional)
+	Include []*RowRule `yaml:"include_rows,omitempty"`
+	// Exclude Rules dropping resource rows after reading (optional)
+	Exclude []*RowRule `yaml:"exclude_rows,omitempty"`
+	// TimestampField Row field with the router date and time of the values, the outputs writing rows
+	// such as InfluxDB use it instead of the collection time (optional). It must change with every
+	// sample, points with the same series and time overwrite each other.
+	TimestampField string `yaml:"timestamp_field,omitempty"`
 
 	Metrics []ResourceMetric `yaml:"metrics"`
+
+	// allowedSensitive Sensitive fields the labels are allowed to expose
+	allowedSensitive map[string]bool
+}
+
+// MinCollectionInterval The shortest allowed collection interval.
+const MinCollectionInterval = 5 * time.Second
+
+// CheckInterval Validates the collection interval and timeout.
+// defaultInterval is checked if the schema doesn't set the interval.
+func (s *ResourceSchema) CheckInterval(defaultInterval time.Duration) error {
+	var interval = s.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	if interval < MinCollectionInterval {
+		return fmt.Errorf("schema '%s': collection interval '%v' must be greater than or equal to %v",
+			s.Name, interval, MinCollectionInterval)
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("schema '%s': collection timeout '%v' must be positive", s.Name, s.Timeout)
+	}
+	return nil
+}
+
+// RequiredPolicies Returns the user group policies the schema needs besides the transport one:
+// read, test for resource commands such as monitor, and sensitive for labels exposing secrets.
+func (s *ResourceSchema) RequiredPolicies() []string {
+	var res = []string{mikrotik.PolicyRead}
+	if s.Command != nil {
+		res = append(res, mikrotik.PolicyTest)
+	}
+	if len(s.allowedSensitive) > 0 {
+		res = append(res, mikrotik.PolicySensitive)
+	}
+	return res
+}
+
+// validateRules Checks the rules selecting the resource and command rows.
+func (s *ResourceSchema) validateRules() error {
+	if err := ValidateRules("include_rows", s.Include); err != nil {
+		return err
+	}
+	if err := ValidateRules("exclude_rows", s.Exclude); err != nil {
+		return err
+	}
+	if s.Command != nil {
+		return ValidateRules("command include_rows", s.Command.Include)
+	}
+	return nil
+}
+
+// sensitiveField Returns true if the field holds a secret.
+// Joined fields are checked against the joined resource.
+func (s *ResourceSchema) sensitiveField(field string) bool {
+	for _, j := range s.Joins {
+		if name, ok := strings.CutPrefix(field, j.Name+"."); ok {
+			return mikrotik.IsSensitiveField(j.MikrotikResourcePath, name)
+		}
+	}
+	return mikrotik.IsSensitiveField(s.MikrotikResourcePath, field)
+}
+
+// checkSensitiveLabels Rejects labels built from fields holding secrets unless they set allow_sensitive.
+func (s *ResourceSchema) checkSensitiveLabels() error {
+	s.allowedSensitive = make(map[string]bool)
+
+	check := func(labels map[string]*LabelSpec) error {
+		for _, name := range sortedKeys(labels) {
+			l := labels[name]
+			if l == nil {
+				continue
+			}
+			for _, f := range l.SourceFields() {
+				if !s.sensitiveField(f) {
+					continue
+				}
+				if !l.AllowSensitive {
+					return fmt.Errorf("label '%s' exposes the sensitive field '%s', set 'allow_sensitive: true' on the label to export it", name, f)
+				}
+				s.allowedSensitive[f] = true
+			}
+		}
+		return nil
+	}
+
+	if err := check(s.PromGlobalLabels); err != nil {
+		return err
+	}
+	for _, m := range s.Metrics {
+		if err := check(m.PromLabels); err != nil {
+			return fmt.Errorf("metric '%s': %w", m.PromMetricName, err)
+		}
+	}
+
+	return nil
+}
+
+// redactRow Returns the row with the values of the sensitive fields replaced,
+// except the fields the labels are allowed to expose.
+func (s *ResourceSchema) redactRow(item mikrotik.MikrotikItem) mikrotik.MikrotikItem {
+	var res mikrotik.MikrotikItem
+	for k := range item {
+		if s.allowedSensitive[k] || !s.sensitiveField(k) {
+			continue
+		}
+		if res == nil {
+			res = make(mikrotik.MikrotikItem, len(item))
+			for k, v := range item {
+				res[k] = v
+			}
+		}
+		res[k] = mikrotik.RedactedValue
+	}
+
+	if res == nil {
+		return item
+	}
+	return res
 }
 
 type ResourceMetric struct {
@@ -51,13 +199,20 @@ type ResourceMetric struct {
 	// Haven't had time to look, if the types of incoming metrics to add are clearly defined,
 	// then these fields are not needed.
 	MtFieldType string `yaml:"field_type"`
+	// Expr Expression evaluated over the resource fields instead of field/field_type (optional)
+	Expr string `yaml:"expr,omitempty"`
 	// PromLabels Private map of labels and label values that are constant for the metric
-	PromLabels prom.Labels `yaml:"labels,omitempty"`
+	PromLabels map[string]*LabelSpec `yaml:"labels,omitempty"`
+	// Buckets Upper bounds of the Histogram buckets, prometheus.DefBuckets by default
+	Buckets []float64 `yaml:"buckets,omitempty"`
+	// Aggregate Aggregate the values of all rows instead of exposing a series per row (optional)
+	Aggregate *Aggregation `yaml:"aggregate,omitempty"`
 	// PromMetricHelp help description of the metric
 	PromMetricHelp string `yaml:"help,omitempty"`
 
-	labels      prom.Labels
+	labels      map[string]*LabelSpec
 	constLabels prom.Labels
+	expr        *Expr
 }
 
 func (m *ResourceMetric) GetLabels() []string {
@@ -65,5 +220,55 @@ func (m *ResourceMetric) GetLabels() []string {
 	for key := range m.labels {
 		res = append(res, key)
 	}
+	sort.Strings(res)
 	return res
 }
+
+// value Extracts the metric value from the resource row.
+func (m *ResourceMetric) value(item mikrotik.MikrotikItem) (float64, error) {
+	if m.expr != nil {
+		return m.expr.Eval(item)
+	}
+
+	inVal := item[m.MtFieldName]
+	switch strings.ToLower(m.MtFieldType) {
+	case Int, Float:
+		return strconv.ParseFloat(inVal, 64)
+	case Time:
+		d, err := mikrotik.ParseDuration(inVal)
+		if err != nil {
+			return 0, err
+		}
+		return d.Seconds(), nil
+	case Const:
+		return 1.0, nil
+	case Bool:
+		return mikrotik.BoolFromMikrotikJSONToFloat(inVal), nil
+	}
+
+	return 0, nil
+}
+
+// valueFields Returns the source values of the metric for logging.
+func (m *ResourceMetric) valueFields(item mikrotik.MikrotikItem) map[string]any {
+	if m.expr == nil {
+		return map[string]any{m.MtFieldName: item[m.MtFieldName]}
+	}
+
+	var res = map[string]any{"expr": m.Expr}
+	for _, f := range m.expr.Fields() {
+		res[f] = item[f]
+	}
+	return res
+}
+
+// distinctKey Returns the row value counted by the count_distinct aggregation.
+func (m *ResourceMetric) distinctKey(item mikrotik.MikrotikItem) string {
+	if m.expr != nil {
+		if v, err := m.expr.Eval(item); err == nil {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+		return ""
+	}
+	return item[m.MtFieldName]
+}
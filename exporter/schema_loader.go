@@ -2,6 +2,7 @@ package exporter
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,23 +11,7 @@ import (
 )
 
 func LoadResSchemas(ctx context.Context, basedir string) ([]ResourceSchema, error) {
-	var files []string
-	err := filepath.Walk(basedir, func(path string, f os.FileInfo, err error) error {
-		if f == nil {
-			return nil
-		}
-		if !f.IsDir() {
-			if strings.HasSuffix(f.Name(), ".yaml") {
-				absolutefilepath, err := filepath.Abs(path)
-				if err != nil {
-					return err
-				}
-				files = append(files, absolutefilepath)
-			}
-		}
-		return err
-	})
-
+	files, err := SchemaFiles(basedir)
 	if err != nil {
 		return nil, err
 	}
@@ -35,6 +20,10 @@ func LoadResSchemas(ctx context.Context, basedir string) ([]ResourceSchema, erro
 
 	for _, file := range files {
 		s, err := SchemaParser(file)
+		if errors.Is(err, ErrSchemaTemplate) {
+			zerolog.Ctx(ctx).Debug().Str("file", file).Msg("skipping template schema")
+			continue
+		}
 		if err != nil {
 			zerolog.Ctx(ctx).Error().Err(err).Msg("")
 			continue
@@ -45,3 +34,26 @@ func LoadResSchemas(ctx context.Context, basedir string) ([]ResourceSchema, erro
 
 	return res, nil
 }
+
+// SchemaFiles Returns the schema files in the directory and its subdirectories.
+// Files starting with '_' (defaults and included files) are skipped.
+func SchemaFiles(basedir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(basedir, func(path string, f os.FileInfo, err error) error {
+		if f == nil {
+			return nil
+		}
+		if !f.IsDir() {
+			if strings.HasSuffix(f.Name(), ".yaml") && !strings.HasPrefix(f.Name(), "_") {
+				absolutefilepath, err := filepath.Abs(path)
+				if err != nil {
+					return err
+				}
+				files = append(files, absolutefilepath)
+			}
+		}
+		return err
+	})
+
+	return files, err
+}
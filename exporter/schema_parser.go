@@ -3,6 +3,9 @@ package exporter
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	prom "github.com/prometheus/client_golang/prometheus"
 	"gopkg.in/yaml.v3"
@@ -11,11 +14,26 @@ import (
 //TODO It would be nice to have a validator for the scheme....
 
 func SchemaParser(schemaFileName string) (*ResourceSchema, error) {
+	return ParseSchemaInstance(schemaFileName, nil)
+}
+
+// ParseSchemaInstance Parses the schema file. A template schema can only be parsed with an instance
+// filling its parameters, otherwise ErrSchemaTemplate is returned.
+func ParseSchemaInstance(schemaFileName string, instance *SchemaInstance) (*ResourceSchema, error) {
 	if _, err := os.Stat(schemaFileName); err != nil {
 		return nil, fmt.Errorf("failed to read resource schema file '%v', %v", schemaFileName, err)
 	}
 
-	bytes, err := os.ReadFile(schemaFileName)
+	doc, err := ExpandSchemaFile(schemaFileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := instance.apply(doc); err != nil {
+		return nil, fmt.Errorf("schema on file '%s': %w", schemaFileName, err)
+	}
+
+	bytes, err := yaml.Marshal(doc)
 	if err != nil {
 		return nil, err
 	}
@@ -26,19 +44,50 @@ func SchemaParser(schemaFileName string) (*ResourceSchema, error) {
 		return nil, fmt.Errorf("unmarshalling schema on file '%s': %w", schemaFileName, err)
 	}
 
+	if res.Name == "" {
+		res.Name = baseSchemaName(schemaFileName)
+	}
+
+	// The default interval is checked on startup
+	if err := res.CheckInterval(MinCollectionInterval); err != nil {
+		return nil, fmt.Errorf("schema on file '%s': %w", schemaFileName, err)
+	}
+
+	if err := res.Requires.Validate(); err != nil {
+		return nil, fmt.Errorf("schema requirements on file '%s': %w", schemaFileName, err)
+	}
+
+	for _, j := range res.Joins {
+		if err := j.validate(); err != nil {
+			return nil, fmt.Errorf("schema on file '%s': %w", schemaFileName, err)
+		}
+	}
+
+	// Rules are checked on load, the rows are filtered on every collection
+	if err := res.validateRules(); err != nil {
+		return nil, fmt.Errorf("schema on file '%s': %w", schemaFileName, err)
+	}
+
+	if err := res.checkSensitiveLabels(); err != nil {
+		return nil, fmt.Errorf("schema on file '%s': %w", schemaFileName, err)
+	}
+
 	// Add global labels
-	var globalLabels, globalConstLabels = make(prom.Labels), make(prom.Labels)
+	var globalLabels, globalConstLabels = make(map[string]*LabelSpec), make(prom.Labels)
 	for key, val := range res.PromGlobalLabels {
-		if len(val) > 1 && val[0] == '$' {
-			globalLabels[key] = val[1:]
+		if val == nil {
+			continue
+		}
+		if val.IsConst() {
+			globalConstLabels[key] = val.Get(nil, nil)
 		} else {
-			globalConstLabels[key] = val
+			globalLabels[key] = val
 		}
 	}
 
 	for i := range res.Metrics {
 		// Add private labels
-		res.Metrics[i].labels = make(prom.Labels, len(globalLabels))
+		res.Metrics[i].labels = make(map[string]*LabelSpec, len(globalLabels))
 		res.Metrics[i].constLabels = make(prom.Labels, len(globalConstLabels))
 
 		for k, v := range globalLabels {
@@ -50,10 +99,13 @@ func SchemaParser(schemaFileName string) (*ResourceSchema, error) {
 		}
 
 		for key, val := range res.Metrics[i].PromLabels {
-			if len(val) > 1 && val[0] == '$' {
-				res.Metrics[i].labels[key] = val[1:]
+			if val == nil {
+				continue
+			}
+			if val.IsConst() {
+				res.Metrics[i].constLabels[key] = val.Get(nil, nil)
 			} else {
-				res.Metrics[i].constLabels[key] = val
+				res.Metrics[i].labels[key] = val
 			}
 		}
 
@@ -61,7 +113,47 @@ func SchemaParser(schemaFileName string) (*ResourceSchema, error) {
 		if res.Metrics[i].MtFieldType == Const {
 			res.Metrics[i].PromMetricOperation = OperSet
 		}
+
+		if res.Metrics[i].PromMetricType == Histogram {
+			if len(res.Metrics[i].Buckets) == 0 {
+				res.Metrics[i].Buckets = prom.DefBuckets
+			}
+			if !sort.Float64sAreSorted(res.Metrics[i].Buckets) {
+				return nil, fmt.Errorf("metric '%s' on file '%s': histogram buckets must be in increasing order",
+					res.Metrics[i].PromMetricName, schemaFileName)
+			}
+		}
+
+		if agg := res.Metrics[i].Aggregate; agg != nil {
+			if err := agg.validate(); err != nil {
+				return nil, fmt.Errorf("metric '%s' on file '%s': %w", res.Metrics[i].PromMetricName, schemaFileName, err)
+			}
+			if res.Metrics[i].PromMetricType == Histogram {
+				return nil, fmt.Errorf("metric '%s' on file '%s': histogram can't be aggregated", res.Metrics[i].PromMetricName, schemaFileName)
+			}
+			// Only the grouping labels are left
+			res.Metrics[i].labels = agg.groupLabels(res.Metrics[i].labels)
+		}
+
+		if res.Metrics[i].Expr != "" {
+			expr, err := ParseExpr(res.Metrics[i].Expr)
+			if err != nil {
+				return nil, fmt.Errorf("metric '%s' on file '%s': %w", res.Metrics[i].PromMetricName, schemaFileName, err)
+			}
+			res.Metrics[i].expr = expr
+		}
+	}
+
+	if instance != nil {
+		for i := range res.Metrics {
+			res.Metrics[i].constLabels[InstanceLabel] = instance.Name
+		}
 	}
 
 	return &res, nil
 }
+
+// baseSchemaName Returns the schema file name without directory and extension.
+func baseSchemaName(fileName string) string {
+	return strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+}
@@ -0,0 +1,257 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultsFileName Schema file merged into all schemas of its directory.
+// Files starting with '_' are not loaded as schemas, they can only be extended or included.
+const DefaultsFileName = "_defaults.yaml"
+
+// DefaultNamespace Metric namespace of the schemas that don't set one, so a schema copied
+// to a directory without _defaults.yaml keeps its metric names.
+const DefaultNamespace = "mikrotik"
+
+// Schema keys resolved by the loader, they are not present in the expanded schema.
+const (
+	keyExtends      = "extends"
+	keyInclude      = "include"
+	keyLabelSets    = "label_sets"
+	keyUseLabelSets = "use_label_sets"
+)
+
+// ExpandSchemaFile Returns the schema with the directory defaults, the extended schema,
+// the included files and the label sets merged in. The precedence from lowest to highest is:
+// DefaultNamespace, _defaults.yaml, extends, include in the listed order, the schema file itself.
+//
+// Top-level mappings (global_labels, resource_filter, requires, label_sets...) are merged key by key,
+// metrics are merged by name, any other value is replaced.
+func ExpandSchemaFile(fileName string) (map[string]any, error) {
+	fileName, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc = map[string]any{"namespace": DefaultNamespace}
+
+	defaults := filepath.Join(filepath.Dir(fileName), DefaultsFileName)
+	if defaults != fileName {
+		if _, err := os.Stat(defaults); err == nil {
+			d, err := resolveSchemaFile(defaults, nil)
+			if err != nil {
+				return nil, err
+			}
+			doc = mergeSchemaDocs(doc, d, false)
+		}
+	}
+
+	own, err := resolveSchemaFile(fileName, nil)
+	if err != nil {
+		return nil, err
+	}
+	doc = mergeSchemaDocs(doc, own, true)
+
+	if err := applyLabelSets(doc); err != nil {
+		return nil, fmt.Errorf("schema file '%s': %w", fileName, err)
+	}
+
+	return doc, nil
+}
+
+// resolveSchemaFile Reads the schema file and merges the extended and included files.
+func resolveSchemaFile(fileName string, stack []string) (map[string]any, error) {
+	if slices.Contains(stack, fileName) {
+		return nil, fmt.Errorf("schema file '%s' is extended or included recursively", fileName)
+	}
+	stack = append(stack, fileName)
+
+	bytes, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource schema file '%v', %v", fileName, err)
+	}
+
+	var own map[string]any
+	if err := yaml.Unmarshal(bytes, &own); err != nil {
+		return nil, fmt.Errorf("unmarshalling schema on file '%s': %w", fileName, err)
+	}
+	if own == nil {
+		own = make(map[string]any)
+	}
+
+	var parents []string
+	if v, ok := own[keyExtends]; ok {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("schema file '%s': '%s' must be a file name", fileName, keyExtends)
+		}
+		parents = append(parents, s)
+	}
+	if v, ok := own[keyInclude]; ok {
+		files, err := stringList(v)
+		if err != nil {
+			// The row rules are include_rows
+			return nil, fmt.Errorf("schema file '%s': '%s' %w, the rules selecting rows are 'include_rows'", fileName, keyInclude, err)
+		}
+		parents = append(parents, files...)
+	}
+	delete(own, keyExtends)
+	delete(own, keyInclude)
+
+	var res = make(map[string]any)
+	for _, p := range parents {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(filepath.Dir(fileName), p)
+		}
+		doc, err := resolveSchemaFile(filepath.Clean(p), stack)
+		if err != nil {
+			return nil, err
+		}
+		res = mergeSchemaDocs(res, doc, false)
+	}
+
+	return mergeSchemaDocs(res, own, true), nil
+}
+
+// mergeSchemaDocs Merges src into a copy of dst. The schema name is only taken from the schema itself.
+func mergeSchemaDocs(dst, src map[string]any, keepName bool) map[string]any {
+	var res = make(map[string]any, len(dst)+len(src))
+	for k, v := range dst {
+		res[k] = v
+	}
+
+	for k, v := range src {
+		if v == nil || k == "name" && !keepName {
+			continue
+		}
+
+		switch sv := v.(type) {
+		case map[string]any:
+			if dv, ok := res[k].(map[string]any); ok {
+				var m = make(map[string]any, len(dv)+len(sv))
+				for mk, mv := range dv {
+					m[mk] = mv
+				}
+				for mk, mv := range sv {
+					m[mk] = mv
+				}
+				v = m
+			}
+		case []any:
+			if dv, ok := res[k].([]any); ok && k == "metrics" {
+				v = mergeMetricDocs(dv, sv)
+			}
+		}
+
+		res[k] = v
+	}
+
+	return res
+}
+
+// mergeMetricDocs Merges the metrics with the same name and appends the new ones.
+func mergeMetricDocs(dst, src []any) []any {
+	var res = slices.Clone(dst)
+
+next:
+	for _, s := range src {
+		sm, ok := s.(map[string]any)
+		if !ok {
+			res = append(res, s)
+			continue
+		}
+
+		for i, d := range res {
+			if dm, ok := d.(map[string]any); ok && metricDocName(dm) != "" && metricDocName(dm) == metricDocName(sm) {
+				res[i] = mergeSchemaDocs(dm, sm, true)
+				continue next
+			}
+		}
+		res = append(res, s)
+	}
+
+	return res
+}
+
+func metricDocName(m map[string]any) string {
+	name, _ := m["name"].(string)
+	return name
+}
+
+// applyLabelSets Adds the labels of the sets listed in use_label_sets to the schema global labels
+// and to the metric labels. Labels defined explicitly take precedence.
+func applyLabelSets(doc map[string]any) error {
+	sets, _ := doc[keyLabelSets].(map[string]any)
+	delete(doc, keyLabelSets)
+
+	use := func(m map[string]any, labelsKey string) error {
+		v, ok := m[keyUseLabelSets]
+		if !ok {
+			return nil
+		}
+		delete(m, keyUseLabelSets)
+
+		names, err := stringList(v)
+		if err != nil {
+			return fmt.Errorf("'%s' %w", keyUseLabelSets, err)
+		}
+
+		labels, _ := m[labelsKey].(map[string]any)
+		var res = make(map[string]any, len(labels))
+		for _, name := range names {
+			set, ok := sets[name].(map[string]any)
+			if !ok {
+				return fmt.Errorf("label set '%s' is not defined", name)
+			}
+			for k, v := range set {
+				res[k] = v
+			}
+		}
+		for k, v := range labels {
+			res[k] = v
+		}
+		m[labelsKey] = res
+
+		return nil
+	}
+
+	if err := use(doc, "global_labels"); err != nil {
+		return err
+	}
+
+	metrics, _ := doc["metrics"].([]any)
+	for _, m := range metrics {
+		mm, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		if err := use(mm, "labels"); err != nil {
+			return fmt.Errorf("metric '%v': %w", mm["name"], err)
+		}
+	}
+
+	return nil
+}
+
+func stringList(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		var res = make([]string, 0, len(v))
+		for _, s := range v {
+			str, ok := s.(string)
+			if !ok {
+				return nil, errors.New("must be a list of strings")
+			}
+			res = append(res, str)
+		}
+		return res, nil
+	}
+	return nil, errors.New("must be a string or a list of strings")
+}
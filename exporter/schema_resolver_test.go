@@ -0,0 +1,275 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeSchemaFiles Writes the files to a temporary directory and returns it.
+func writeSchemaFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExpandSchemaFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// want Expected expanded schema.yaml in YAML
+		want string
+	}{
+		{
+			name:  "default namespace",
+			files: map[string]string{"schema.yaml": "subsystem: interface"},
+			want:  "{namespace: mikrotik, subsystem: interface}",
+		},
+		{
+			name:  "empty namespace",
+			files: map[string]string{"schema.yaml": "namespace: ''\nsubsystem: interface"},
+			want:  "{namespace: '', subsystem: interface}",
+		},
+		{
+			name: "defaults",
+			files: map[string]string{
+				"_defaults.yaml": "namespace: test\nglobal_labels: {site: dc1, rack: r1}",
+				"schema.yaml":    "subsystem: interface\nglobal_labels: {rack: r2}",
+			},
+			want: "{namespace: test, subsystem: interface, global_labels: {site: dc1, rack: r2}}",
+		},
+		{
+			name: "defaults of the other directory are not used",
+			files: map[string]string{
+				"_defaults.yaml":   "namespace: test",
+				"sub/base.yaml":    "subsystem: base",
+				"sub/schema.yaml":  "extends: base.yaml",
+				"sub/another.yaml": "subsystem: another",
+			},
+			want: "{namespace: mikrotik, subsystem: base}",
+		},
+		{
+			name: "override order",
+			files: map[string]string{
+				"_defaults.yaml": "resource_filter: {a: defaults, b: defaults, c: defaults, d: defaults, e: defaults}",
+				"base.yaml":      "resource_filter: {b: base, c: base, d: base, e: base}",
+				"_inc1.yaml":     "resource_filter: {c: inc1, d: inc1, e: inc1}",
+				"_inc2.yaml":     "resource_filter: {d: inc2, e: inc2}",
+				"schema.yaml":    "extends: base.yaml\ninclude: [_inc1.yaml, _inc2.yaml]\nresource_filter: {e: schema}",
+			},
+			want: "{namespace: mikrotik, resource_filter: {a: defaults, b: base, c: inc1, d: inc2, e: schema}}",
+		},
+		{
+			name: "extends chain",
+			files: map[string]string{
+				"a.yaml":      "name: a\nsubsystem: a\nresource_path: /a\ninterval: 10s",
+				"b.yaml":      "name: b\nextends: a.yaml\nresource_path: /b",
+				"schema.yaml": "extends: b.yaml\ninterval: 20s",
+			},
+			// The name is not inherited
+			want: "{namespace: mikrotik, subsystem: a, resource_path: /b, interval: 20s}",
+		},
+		{
+			name: "include a single file",
+			files: map[string]string{
+				"_common.yaml": "requires: {packages: [wireless]}",
+				"schema.yaml":  "include: _common.yaml\nsubsystem: caps",
+			},
+			want: "{namespace: mikrotik, subsystem: caps, requires: {packages: [wireless]}}",
+		},
+		{
+			name: "metrics merged by name",
+			files: map[string]string{
+				"base.yaml": `
+metrics:
+  - {name: rx, type: CounterVec, field: rx-byte, help: received}
+  - {name: tx, type: CounterVec, field: tx-byte}`,
+				"schema.yaml": `
+extends: base.yaml
+metrics:
+  - {name: rx, help: received bytes}
+  - {name: drops, type: CounterVec, field: rx-drop}`,
+			},
+			want: `
+namespace: mikrotik
+metrics:
+  - {name: rx, type: CounterVec, field: rx-byte, help: received bytes}
+  - {name: tx, type: CounterVec, field: tx-byte}
+  - {name: drops, type: CounterVec, field: rx-drop}`,
+		},
+		{
+			name: "label sets",
+			files: map[string]string{
+				"_defaults.yaml": "label_sets: {rule: {chain: $chain, action: $action}, port: {port: $port}}",
+				"schema.yaml": `
+global_labels: {action: $verdict}
+use_label_sets: [rule, port]
+metrics:
+  - name: bytes
+    use_label_sets: port
+    labels: {proto: $protocol}`,
+			},
+			// The explicit labels take precedence
+			want: `
+namespace: mikrotik
+global_labels: {chain: $chain, action: $verdict, port: $port}
+metrics:
+  - name: bytes
+    labels: {port: $port, proto: $protocol}`,
+		},
+		{
+			name: "label sets of an included file",
+			files: map[string]string{
+				"_sets.yaml":  "label_sets: {rule: {chain: $chain}}",
+				"schema.yaml": "include: _sets.yaml\nuse_label_sets: rule",
+			},
+			want: "{namespace: mikrotik, global_labels: {chain: $chain}}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeSchemaFiles(t, tt.files)
+			file := filepath.Join(dir, "schema.yaml")
+			if _, ok := tt.files["sub/schema.yaml"]; ok {
+				file = filepath.Join(dir, "sub", "schema.yaml")
+			}
+
+			got, err := ExpandSchemaFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want map[string]any
+			if err := yaml.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				g, _ := yaml.Marshal(got)
+				t.Errorf("got:\n%s\nwant:\n%s", g, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandSchemaFileErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		err   string
+	}{
+		{
+			name:  "extends itself",
+			files: map[string]string{"schema.yaml": "extends: schema.yaml"},
+			err:   "is extended or included recursively",
+		},
+		{
+			name: "extends cycle",
+			files: map[string]string{
+				"a.yaml":      "extends: b.yaml",
+				"b.yaml":      "extends: a.yaml",
+				"schema.yaml": "extends: a.yaml",
+			},
+			err: "a.yaml' is extended or included recursively",
+		},
+		{
+			name: "include cycle",
+			files: map[string]string{
+				"_a.yaml":     "include: [_b.yaml]",
+				"_b.yaml":     "extends: schema.yaml",
+				"schema.yaml": "include: _a.yaml",
+			},
+			err: "schema.yaml' is extended or included recursively",
+		},
+		{
+			name:  "missing file",
+			files: map[string]string{"schema.yaml": "extends: missing.yaml"},
+			err:   "failed to read resource schema file",
+		},
+		{
+			name:  "extends list",
+			files: map[string]string{"schema.yaml": "extends: [a.yaml]"},
+			err:   "'extends' must be a file name",
+		},
+		{
+			name:  "row rules in include",
+			files: map[string]string{"schema.yaml": "include:\n  - field: running"},
+			err:   "the rules selecting rows are 'include_rows'",
+		},
+		{
+			name:  "undefined label set",
+			files: map[string]string{"schema.yaml": "use_label_sets: [rule]"},
+			err:   "label set 'rule' is not defined",
+		},
+		{
+			name: "undefined metric label set",
+			files: map[string]string{
+				"schema.yaml": "label_sets: {rule: {chain: $chain}}\nmetrics:\n  - {name: bytes, use_label_sets: [port]}",
+			},
+			err: "metric 'bytes': label set 'port' is not defined",
+		},
+		{
+			name:  "invalid yaml",
+			files: map[string]string{"schema.yaml": "metrics: ["},
+			err:   "unmarshalling schema",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeSchemaFiles(t, tt.files)
+
+			_, err := ExpandSchemaFile(filepath.Join(dir, "schema.yaml"))
+			if err == nil {
+				t.Fatalf("expected error '%s'", tt.err)
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestShippedSchemasWithoutDefaults Checks that the shipped schemas keep their metric names
+// without resources/_defaults.yaml.
+func TestShippedSchemasWithoutDefaults(t *testing.T) {
+	files, err := filepath.Glob("../resources/*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range files {
+		if strings.HasPrefix(filepath.Base(file), "_") {
+			continue
+		}
+
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := writeSchemaFiles(t, map[string]string{"schema.yaml": string(content)})
+
+		doc, err := ExpandSchemaFile(filepath.Join(dir, "schema.yaml"))
+		if err != nil {
+			// Schemas extending, including or using the label sets of other files need them
+			continue
+		}
+		if doc["namespace"] != DefaultNamespace {
+			t.Errorf("%s: namespace is '%v'", file, doc["namespace"])
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/rs/zerolog"
+)
+
+// InstanceLabel Label with the instance name added to all metrics of a template schema instance.
+const InstanceLabel = "schema_instance"
+
+const keyParams = "params"
+
+// ErrSchemaTemplate Returned when a template schema is parsed without an instance.
+var ErrSchemaTemplate = errors.New("schema is a template")
+
+var paramRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// SchemaInstance Template schema instantiated with parameters from the configuration.
+// A template schema declares its parameters and their default values, null marks a required parameter:
+//
+//	params:
+//	  resource_path: null
+//	  prefix: table
+//
+// Every ${param} in the schema keys and values is replaced with the parameter value. An entry whose key
+// is empty after the substitution is dropped, so an optional parameter defaulting to an empty string
+// can leave out e.g. a resource_filter entry.
+type SchemaInstance struct {
+	// Name Instance name, used as the schema name and as the schema_instance label value
+	Name string `yaml:"-"`
+	// Schema Name of the template schema
+	Schema string `yaml:"schema"`
+	// Params Template parameters
+	Params map[string]string `yaml:"params,omitempty"`
+}
+
+// apply Substitutes the template parameters in the expanded schema.
+func (i *SchemaInstance) apply(doc map[string]any) error {
+	declared, _ := doc[keyParams].(map[string]any)
+	delete(doc, keyParams)
+
+	if i == nil {
+		if len(declared) > 0 {
+			return ErrSchemaTemplate
+		}
+		return nil
+	}
+
+	if len(declared) == 0 {
+		return fmt.Errorf("schema '%s' is not a template", i.Schema)
+	}
+
+	var params = make(map[string]string, len(declared))
+	for k, v := range declared {
+		if v != nil {
+			params[k] = fmt.Sprint(v)
+		}
+	}
+	for k, v := range i.Params {
+		if _, ok := declared[k]; !ok {
+			return fmt.Errorf("unknown parameter '%s'", k)
+		}
+		params[k] = v
+	}
+	for k := range declared {
+		if _, ok := params[k]; !ok {
+			return fmt.Errorf("required parameter '%s' is not set", k)
+		}
+	}
+
+	var err error
+	substitute := func(s string) string {
+		return paramRe.ReplaceAllStringFunc(s, func(m string) string {
+			name := m[2 : len(m)-1]
+			v, ok := params[name]
+			if !ok && err == nil {
+				err = fmt.Errorf("parameter '%s' is not declared", name)
+			}
+			return v
+		})
+	}
+
+	res := substituteParams(doc, substitute)
+	for k := range doc {
+		delete(doc, k)
+	}
+	for k, v := range res.(map[string]any) {
+		doc[k] = v
+	}
+	doc["name"] = i.Name
+
+	return err
+}
+
+func substituteParams(v any, substitute func(string) string) any {
+	switch v := v.(type) {
+	case string:
+		return substitute(v)
+	case map[string]any:
+		var res = make(map[string]any, len(v))
+		for k, val := range v {
+			key := substitute(k)
+			if key == "" && k != "" {
+				continue
+			}
+			res[key] = substituteParams(val, substitute)
+		}
+		return res
+	case []any:
+		var res = make([]any, len(v))
+		for i, val := range v {
+			res[i] = substituteParams(val, substitute)
+		}
+		return res
+	}
+	return v
+}
+
+// LoadSchemaInstances Parses the template schemas of the directory for each instance.
+func LoadSchemaInstances(ctx context.Context, basedir string, instances []SchemaInstance) ([]ResourceSchema, error) {
+	if len(instances) == 0 {
+		return nil, nil
+	}
+
+	files, err := SchemaFiles(basedir)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates = make(map[string]string)
+	for _, file := range files {
+		doc, err := ExpandSchemaFile(file)
+		if err != nil {
+			continue
+		}
+		if _, ok := doc[keyParams]; ok {
+			name, _ := doc["name"].(string)
+			if name == "" {
+				name = baseSchemaName(file)
+			}
+			templates[name] = file
+		}
+	}
+
+	var res []ResourceSchema
+	for _, inst := range instances {
+		file, ok := templates[inst.Schema]
+		if !ok {
+			zerolog.Ctx(ctx).Error().Str("instance", inst.Name).Msgf("template schema '%s' is not found", inst.Schema)
+			continue
+		}
+
+		s, err := ParseSchemaInstance(file, &inst)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Str("instance", inst.Name).Msg("")
+			continue
+		}
+
+		res = append(res, *s)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,156 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testTemplateSchema = `
+params:
+  resource_path: null
+  filter_field: ''
+  prefix: table
+subsystem: table
+resource_path: ${resource_path}
+resource_filter:
+  ${filter_field}: yes
+metrics:
+  - name: ${prefix}_entries
+    help: Rows of ${resource_path}
+    type: GaugeVec
+    aggregate:
+      func: count
+`
+
+func TestParseSchemaInstance(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{"table.yaml": testTemplateSchema})
+	file := filepath.Join(dir, "table.yaml")
+
+	tests := []struct {
+		name     string
+		instance *SchemaInstance
+		check    func(t *testing.T, s *ResourceSchema)
+		err      string
+	}{
+		{
+			name:     "defaults",
+			instance: &SchemaInstance{Name: "routes", Schema: "table", Params: map[string]string{"resource_path": "/ip/route"}},
+			check: func(t *testing.T, s *ResourceSchema) {
+				if s.Name != "routes" || s.MikrotikResourcePath != "/ip/route" {
+					t.Errorf("schema name '%s', resource path '%s'", s.Name, s.MikrotikResourcePath)
+				}
+				// The filter with an empty field is dropped
+				if len(s.ResourceFilter) != 0 {
+					t.Errorf("resource filter %v", s.ResourceFilter)
+				}
+				m := s.Metrics[0]
+				if m.PromMetricName != "table_entries" || m.PromMetricHelp != "Rows of /ip/route" {
+					t.Errorf("metric name '%s', help '%s'", m.PromMetricName, m.PromMetricHelp)
+				}
+				if m.constLabels[InstanceLabel] != "routes" {
+					t.Errorf("metric const labels %v", m.constLabels)
+				}
+			},
+		},
+		{
+			name: "params",
+			instance: &SchemaInstance{Name: "blocklist", Schema: "table", Params: map[string]string{
+				"resource_path": "/ip/firewall/address-list", "filter_field": "list", "prefix": "address_list",
+			}},
+			check: func(t *testing.T, s *ResourceSchema) {
+				if want := map[string]string{"list": "yes"}; !reflect.DeepEqual(s.ResourceFilter, want) {
+					t.Errorf("resource filter %v, want %v", s.ResourceFilter, want)
+				}
+				if s.Metrics[0].PromMetricName != "address_list_entries" {
+					t.Errorf("metric name '%s'", s.Metrics[0].PromMetricName)
+				}
+			},
+		},
+		{
+			name:     "missing required param",
+			instance: &SchemaInstance{Name: "routes", Schema: "table"},
+			err:      "required parameter 'resource_path' is not set",
+		},
+		{
+			name:     "unknown param",
+			instance: &SchemaInstance{Name: "routes", Schema: "table", Params: map[string]string{"resource_path": "/ip/route", "filter": "x"}},
+			err:      "unknown parameter 'filter'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchemaInstance(file, tt.instance)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error '%v', want '%s'", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, s)
+		})
+	}
+}
+
+func TestParseSchemaInstanceErrors(t *testing.T) {
+	dir := writeSchemaFiles(t, map[string]string{
+		"table.yaml":      testTemplateSchema,
+		"undeclared.yaml": "params: {prefix: table}\nsubsystem: ${subsystem}",
+		"plain.yaml":      "subsystem: interface\nresource_path: /interface",
+	})
+
+	// A template without an instance
+	if _, err := SchemaParser(filepath.Join(dir, "table.yaml")); !errors.Is(err, ErrSchemaTemplate) {
+		t.Errorf("got error '%v', want '%v'", err, ErrSchemaTemplate)
+	}
+
+	_, err := ParseSchemaInstance(filepath.Join(dir, "undeclared.yaml"), &SchemaInstance{Name: "x", Schema: "undeclared"})
+	if err == nil || !strings.Contains(err.Error(), "parameter 'subsystem' is not declared") {
+		t.Errorf("got error '%v' for an undeclared parameter", err)
+	}
+
+	_, err = ParseSchemaInstance(filepath.Join(dir, "plain.yaml"), &SchemaInstance{Name: "x", Schema: "plain"})
+	if err == nil || !strings.Contains(err.Error(), "schema 'plain' is not a template") {
+		t.Errorf("got error '%v' for a schema without params", err)
+	}
+}
+
+func TestLoadSchemaInstances(t *testing.T) {
+	instances := []SchemaInstance{
+		{Name: "blocklist", Schema: "table_counter", Params: map[string]string{
+			"resource_path": "/ip/firewall/address-list", "filter_field": "list", "filter_value": "blocklist", "prefix": "address_list",
+		}},
+		{Name: "routes", Schema: "table_counter", Params: map[string]string{"resource_path": "/ip/route", "prefix": "route"}},
+		// Skipped
+		{Name: "missing", Schema: "not_found"},
+		{Name: "invalid", Schema: "table_counter"},
+	}
+
+	schemas, err := LoadSchemaInstances(context.Background(), "../resources", instances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schemas) != 2 {
+		t.Fatalf("got %d schemas, want 2", len(schemas))
+	}
+
+	if want := map[string]string{"list": "blocklist"}; !reflect.DeepEqual(schemas[0].ResourceFilter, want) {
+		t.Errorf("blocklist resource filter %v, want %v", schemas[0].ResourceFilter, want)
+	}
+	// All rows are counted without the filter
+	if len(schemas[1].ResourceFilter) != 0 {
+		t.Errorf("routes resource filter %v", schemas[1].ResourceFilter)
+	}
+	for i, name := range []string{"blocklist", "routes"} {
+		if schemas[i].Name != name || schemas[i].Metrics[0].constLabels[InstanceLabel] != name {
+			t.Errorf("schema '%s' labels %v", schemas[i].Name, schemas[i].Metrics[0].constLabels)
+		}
+	}
+}
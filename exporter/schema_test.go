@@ -0,0 +1,180 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+func parseTestSchema(t *testing.T, content string) (*ResourceSchema, error) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "test.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return SchemaParser(file)
+}
+
+func TestSchemaSensitiveLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{
+			name: "global label",
+			schema: `
+resource_path: /ppp/secret
+global_labels:
+  password: $password
+metrics:
+  - name: info
+    type: Gauge
+    field_type: const
+`,
+			err: "label 'password' exposes the sensitive field 'password'",
+		},
+		{
+			name: "metric label",
+			schema: `
+resource_path: /snmp/community
+metrics:
+  - name: info
+    type: GaugeVec
+    field_type: const
+    labels:
+      community: $name
+`,
+			err: "metric 'info': label 'community' exposes the sensitive field 'name'",
+		},
+		{
+			name: "label fields",
+			schema: `
+resource_path: /interface/wireguard
+global_labels:
+  key:
+    fields: [comment, private-key]
+metrics:
+  - name: info
+    type: Gauge
+    field_type: const
+`,
+			err: "label 'key' exposes the sensitive field 'private-key'",
+		},
+		{
+			name: "joined field",
+			schema: `
+resource_path: /interface/wifi/registration-table
+joins:
+  - name: lease
+    resource_path: /ip/dhcp-server/lease
+    on: mac-address
+global_labels:
+  lease_password: $lease.password
+metrics:
+  - name: info
+    type: Gauge
+    field_type: const
+`,
+			err: "label 'lease_password' exposes the sensitive field 'lease.password'",
+		},
+		{
+			name: "joined resource path field",
+			schema: `
+resource_path: /ip/firewall/filter
+joins:
+  - name: snmp
+    resource_path: /snmp/community
+    on: comment
+    key: name
+global_labels:
+  community: $snmp.name
+metrics:
+  - name: info
+    type: Gauge
+    field_type: const
+`,
+			err: "label 'community' exposes the sensitive field 'snmp.name'",
+		},
+		{
+			name: "allowed",
+			schema: `
+resource_path: /ppp/secret
+global_labels:
+  password:
+    field: password
+    allow_sensitive: true
+  name: $name
+metrics:
+  - name: info
+    type: Gauge
+    field_type: const
+`,
+		},
+		{
+			name: "not sensitive in another resource",
+			schema: `
+resource_path: /interface
+global_labels:
+  name: $name
+metrics:
+  - name: info
+    type: Gauge
+    field_type: const
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema(t, tt.schema)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			case tt.err != "" && err == nil:
+				t.Fatalf("expected error '%s'", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSchemaRedactRow(t *testing.T) {
+	s, err := parseTestSchema(t, `
+resource_path: /ppp/secret
+joins:
+  - name: lease
+    resource_path: /ip/dhcp-server/lease
+    on: name
+global_labels:
+  password:
+    field: password
+    allow_sensitive: true
+metrics:
+  - name: info
+    type: Gauge
+    field_type: const
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	row := mikrotik.MikrotikItem{"name": "vpn", "password": "p1", "secret": "s1", "lease.password": "p2"}
+	got := s.redactRow(row)
+
+	// The label is allowed to expose the password, the other secrets are redacted
+	want := mikrotik.MikrotikItem{"name": "vpn", "password": "p1", "secret": mikrotik.RedactedValue, "lease.password": mikrotik.RedactedValue}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s is '%s', want '%s'", k, got[k], v)
+		}
+	}
+	if row["secret"] != "s1" || row["lease.password"] != "p2" {
+		t.Errorf("the row was modified: %v", row)
+	}
+}
@@ -0,0 +1,75 @@
+package exporter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// cycleCollector Collector accumulating the values of all rows during the collection cycle.
+type cycleCollector interface {
+	begin()
+	commit()
+}
+
+// snapshotCollector Collector exposing the metrics computed once per collection cycle.
+// The whole set of metrics is replaced at once, so a scrape never sees partial values.
+type snapshotCollector struct {
+	desc       *prom.Desc
+	labelNames []string
+
+	mu      sync.RWMutex
+	metrics []prom.Metric
+}
+
+func newSnapshotCollector(fqName, help string, labelNames []string, constLabels prom.Labels) *snapshotCollector {
+	return &snapshotCollector{
+		desc:       prom.NewDesc(fqName, help, labelNames, constLabels),
+		labelNames: labelNames,
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *snapshotCollector) Describe(ch chan<- *prom.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector.
+func (c *snapshotCollector) Collect(ch chan<- prom.Metric) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+func (c *snapshotCollector) set(metrics []prom.Metric) {
+	c.mu.Lock()
+	c.metrics = metrics
+	c.mu.Unlock()
+}
+
+// labelValues Returns label values in the order of the collector label names.
+func (c *snapshotCollector) labelValues(labels prom.Labels) []string {
+	var res = make([]string, len(c.labelNames))
+	for i, name := range c.labelNames {
+		res[i] = labels[name]
+	}
+	return res
+}
+
+func labelsKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var res = make([]string, 0, len(m))
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,263 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/vaerh/mikrotik-prom-exporter/mikrotik"
+)
+
+// Collection kinds.
+const (
+	StatusKindSchema    = "schema"
+	StatusKindCollector = "collector"
+)
+
+// CollectionStatus State of a schema or complex collector.
+type CollectionStatus struct {
+	Kind     string
+	Name     string
+	Interval time.Duration
+	// Runs Number of finished collection cycles
+	Runs uint64
+	// Inactive The schema requirements are not satisfied or its resource doesn't exist on the router
+	Inactive     bool
+	LastRun      time.Time
+	LastDuration time.Duration
+	// LastError Error of the last cycle, empty if it succeeded
+	LastError string
+	// LastErrorReason Reason of the last error, see mikrotik.ErrorReason
+	LastErrorReason string
+	// Errors Number of failed cycles by error reason
+	Errors map[string]uint64
+	// Rows Number of resource rows read in the last cycle, -1 if unknown
+	Rows    int
+	NextRun time.Time
+}
+
+// MarshalJSON Implements json.Marshaler with durations in seconds.
+func (s CollectionStatus) MarshalJSON() ([]byte, error) {
+	type status struct {
+		Kind                string            `json:"kind"`
+		Name                string            `json:"name"`
+		IntervalSeconds     float64           `json:"interval_seconds"`
+		Runs                uint64            `json:"runs"`
+		Inactive            bool              `json:"inactive"`
+		LastRun             *time.Time        `json:"last_run,omitempty"`
+		LastDurationSeconds float64           `json:"last_duration_seconds"`
+		LastError           string            `json:"last_error,omitempty"`
+		LastErrorReason     string            `json:"last_error_reason,omitempty"`
+		Errors              map[string]uint64 `json:"errors,omitempty"`
+		Rows                int               `json:"rows"`
+		NextRun             *time.Time        `json:"next_run,omitempty"`
+	}
+
+	var res = status{
+		Kind:                s.Kind,
+		Name:                s.Name,
+		IntervalSeconds:     s.Interval.Seconds(),
+		Runs:                s.Runs,
+		Inactive:            s.Inactive,
+		LastDurationSeconds: s.LastDuration.Seconds(),
+		LastError:           s.LastError,
+		LastErrorReason:     s.LastErrorReason,
+		Errors:              s.Errors,
+		Rows:                s.Rows,
+	}
+	if s.Runs > 0 {
+		res.LastRun, res.NextRun = &s.LastRun, &s.NextRun
+	}
+
+	return json.Marshal(res)
+}
+
+// Status Keeps the state of all schemas and complex collectors for the status page,
+// the readiness check and the exporter metrics.
+type Status struct {
+	mu      sync.RWMutex
+	entries map[string]*CollectionStatus
+	started bool
+
+	durationDesc *prom.Desc
+	successDesc  *prom.Desc
+	rowsDesc     *prom.Desc
+	lastRunDesc  *prom.Desc
+	runsDesc     *prom.Desc
+	errorsDesc   *prom.Desc
+}
+
+func NewStatus(constLabels prom.Labels) *Status {
+	var labels = []string{"kind", "name"}
+	return &Status{
+		entries: make(map[string]*CollectionStatus),
+		durationDesc: prom.NewDesc("mikrotik_exporter_collection_duration_seconds",
+			"Duration of the last collection cycle", labels, constLabels),
+		successDesc: prom.NewDesc("mikrotik_exporter_collection_success",
+			"Whether the last collection cycle succeeded", labels, constLabels),
+		rowsDesc: prom.NewDesc("mikrotik_exporter_collection_rows",
+			"Number of resource rows read in the last collection cycle", labels, constLabels),
+		lastRunDesc: prom.NewDesc("mikrotik_exporter_collection_last_run_timestamp_seconds",
+			"Start time of the last collection cycle", labels, constLabels),
+		runsDesc: prom.NewDesc("mikrotik_exporter_collection_runs_total",
+			"Number of finished collection cycles", labels, constLabels),
+		errorsDesc: prom.NewDesc("mikrotik_exporter_collection_errors_total",
+			"Number of failed collection cycles by error reason", append(labels, "reason"), constLabels),
+	}
+}
+
+// Track Adds the schema or collector and returns the tracker of its collection cycles.
+func (s *Status) Track(kind, name string, interval time.Duration) *StatusTracker {
+	var e = &CollectionStatus{Kind: kind, Name: name, Interval: interval, Rows: -1}
+
+	s.mu.Lock()
+	s.entries[kind+"/"+name] = e
+	s.mu.Unlock()
+
+	return &StatusTracker{status: s, entry: e}
+}
+
+// Started Marks that all schemas and collectors are tracked.
+func (s *Status) Started() {
+	s.mu.Lock()
+	s.started = true
+	s.mu.Unlock()
+}
+
+// Collections Returns a copy of all states sorted by kind and name.
+func (s *Status) Collections() []CollectionStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var res = make([]CollectionStatus, 0, len(s.entries))
+	for _, key := range sortedKeys(s.entries) {
+		var c = *s.entries[key]
+		if c.Errors != nil {
+			c.Errors = make(map[string]uint64, len(c.Errors))
+			for reason, n := range s.entries[key].Errors {
+				c.Errors[reason] = n
+			}
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// Ready Returns an error until every schema and collector has finished its first collection cycle.
+func (s *Status) Ready() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !s.started {
+		return fmt.Errorf("exporter is starting")
+	}
+
+	var pending []string
+	for _, key := range sortedKeys(s.entries) {
+		if s.entries[key].Runs == 0 {
+			pending = append(pending, key)
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("waiting for the first collection of %s", strings.Join(pending, ", "))
+	}
+
+	return nil
+}
+
+// Describe implements prometheus.Collector.
+func (s *Status) Describe(ch chan<- *prom.Desc) {
+	ch <- s.durationDesc
+	ch <- s.successDesc
+	ch <- s.rowsDesc
+	ch <- s.lastRunDesc
+	ch <- s.runsDesc
+	ch <- s.errorsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (s *Status) Collect(ch chan<- prom.Metric) {
+	for _, c := range s.Collections() {
+		ch <- prom.MustNewConstMetric(s.runsDesc, prom.CounterValue, float64(c.Runs), c.Kind, c.Name)
+		for reason, n := range c.Errors {
+			ch <- prom.MustNewConstMetric(s.errorsDesc, prom.CounterValue, float64(n), c.Kind, c.Name, reason)
+		}
+		if c.Runs == 0 || c.Inactive {
+			continue
+		}
+
+		var success float64
+		if c.LastError == "" {
+			success = 1
+		}
+		ch <- prom.MustNewConstMetric(s.successDesc, prom.GaugeValue, success, c.Kind, c.Name)
+		ch <- prom.MustNewConstMetric(s.durationDesc, prom.GaugeValue, c.LastDuration.Seconds(), c.Kind, c.Name)
+		ch <- prom.MustNewConstMetric(s.lastRunDesc, prom.GaugeValue, float64(c.LastRun.UnixNano())/1e9, c.Kind, c.Name)
+		if c.Rows >= 0 {
+			ch <- prom.MustNewConstMetric(s.rowsDesc, prom.GaugeValue, float64(c.Rows), c.Kind, c.Name)
+		}
+	}
+}
+
+// StatusTracker Records the collection cycles of a schema or collector. A nil tracker records nothing.
+type StatusTracker struct {
+	status *Status
+	entry  *CollectionStatus
+}
+
+// Done Records a finished collection cycle. Rows is -1 if the number of rows is unknown.
+func (t *StatusTracker) Done(start time.Time, rows int, err error) {
+	t.update(start, func(e *CollectionStatus) {
+		e.Inactive = false
+		e.Rows = rows
+		e.LastError, e.LastErrorReason = "", ""
+		if err != nil {
+			e.LastError, e.LastErrorReason = err.Error(), mikrotik.ErrorReason(err)
+			if e.Errors == nil {
+				e.Errors = make(map[string]uint64)
+			}
+			e.Errors[e.LastErrorReason]++
+		}
+	})
+}
+
+// Inactive Records a cycle skipped because the schema or collector requirements are not satisfied.
+func (t *StatusTracker) Inactive(start time.Time) {
+	t.update(start, func(e *CollectionStatus) {
+		e.Inactive = true
+		e.Rows = -1
+		e.LastError, e.LastErrorReason = "", ""
+	})
+}
+
+func (t *StatusTracker) update(start time.Time, fn func(e *CollectionStatus)) {
+	if t == nil {
+		return
+	}
+
+	t.status.mu.Lock()
+	defer t.status.mu.Unlock()
+
+	fn(t.entry)
+	t.entry.Runs++
+	t.entry.LastRun = start
+	t.entry.LastDuration = time.Since(start)
+	t.entry.NextRun = start.Add(t.entry.Interval)
+}
+
+type statusTrackerKey struct{}
+
+// WithContext Returns a copy of the context carrying the tracker.
+func (t *StatusTracker) WithContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, statusTrackerKey{}, t)
+}
+
+// StatusCtx Returns the tracker stored in the context or nil.
+func StatusCtx(ctx context.Context) *StatusTracker {
+	t, _ := ctx.Value(statusTrackerKey{}).(*StatusTracker)
+	return t
+}
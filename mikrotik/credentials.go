@@ -0,0 +1,96 @@
+package mikrotik
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultPasswordFileCheckInterval = 30 * time.Second
+
+// Credentials Router user name and password. The password can be read from a file,
+// Watch re-reads it so a rotated password is used on the next login.
+type Credentials struct {
+	Username string
+
+	file         string
+	mu           sync.RWMutex
+	password     string
+	authFailures atomic.Uint64
+}
+
+// NewCredentials Creates the credentials. If passwordFile is set, the password is read from it.
+func NewCredentials(username, password, passwordFile string) (*Credentials, error) {
+	var c = &Credentials{Username: username, password: password, file: passwordFile}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Password Returns the current password.
+func (c *Credentials) Password() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.password
+}
+
+// Reload Reads the password file. Returns true if the password has changed.
+func (c *Credentials) Reload() (bool, error) {
+	if c.file == "" {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(c.file)
+	if err != nil {
+		return false, fmt.Errorf("reading password file '%s': %w", c.file, err)
+	}
+	// Files created with echo or editors end with a new line
+	password := strings.TrimRight(string(b), "\r\n")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	changed := c.password != password
+	c.password = password
+	return changed, nil
+}
+
+// Watch Re-reads the password file every interval until the context is cancelled.
+func (c *Credentials) Watch(ctx context.Context, interval time.Duration) {
+	if c.file == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			changed, err := c.Reload()
+			if err != nil {
+				LogMessage(ctx, WARN, "Failed to reload the password", map[string]interface{}{"error": err})
+			} else if changed {
+				LogMessage(ctx, INFO, "Password file '"+c.file+"' changed, the new password is used on the next login")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// AuthFailures Returns the number of logins rejected by the router.
+func (c *Credentials) AuthFailures() uint64 {
+	return c.authFailures.Load()
+}
+
+// authFailed Counts the rejected login and returns the error wrapping ErrAuth.
+func (c *Credentials) authFailed(format string, args ...any) error {
+	c.authFailures.Add(1)
+	return fmt.Errorf("%w: "+format, append([]any{ErrAuth}, args...)...)
+}
@@ -0,0 +1,79 @@
+package mikrotik
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCredentialsReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "password")
+	write := func(s string) {
+		if err := os.WriteFile(file, []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("secret\n")
+	c, err := NewCredentials("admin", "ignored", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Password(); got != "secret" {
+		t.Errorf("password is %q, want %q", got, "secret")
+	}
+
+	tests := []struct {
+		content string
+		want    string
+		changed bool
+	}{
+		{content: "secret\n", want: "secret", changed: false},
+		{content: "secret\r\n", want: "secret", changed: false},
+		{content: "secret", want: "secret", changed: false},
+		{content: "rotated\n\n", want: "rotated", changed: true},
+		// Only the line end is trimmed, the spaces are a part of the password
+		{content: " rotated \n", want: " rotated ", changed: true},
+		{content: "", want: "", changed: true},
+	}
+
+	for _, tt := range tests {
+		write(tt.content)
+		changed, err := c.Reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != tt.changed || c.Password() != tt.want {
+			t.Errorf("%q: got %q, changed %v, want %q, changed %v", tt.content, c.Password(), changed, tt.want, tt.changed)
+		}
+	}
+
+	// A failed reload keeps the password
+	os.Remove(file)
+	if _, err := c.Reload(); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if c.Password() != "" {
+		t.Errorf("password is %q after a failed reload", c.Password())
+	}
+}
+
+func TestCredentialsWithoutFile(t *testing.T) {
+	c, err := NewCredentials("admin", "secret\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := c.Reload()
+	if err != nil || changed {
+		t.Errorf("Reload() = %v, %v, want false, nil", changed, err)
+	}
+	// A password given directly is used as is
+	if c.Password() != "secret\n" {
+		t.Errorf("password is %q", c.Password())
+	}
+
+	if _, err := NewCredentials("admin", "", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing password file")
+	}
+}
@@ -0,0 +1,152 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-routeros/routeros"
+)
+
+var (
+	// ErrAuth The router rejected the user name or password.
+	ErrAuth = errors.New("authentication failed")
+	// ErrPermission The user group lacks a policy the request needs.
+	ErrPermission = errors.New("not enough permissions")
+	// ErrNotFound The resource path or command doesn't exist, e.g. its package is not installed.
+	// A missing item, e.g. an interface removed between two requests, is not ErrNotFound.
+	ErrNotFound = errors.New("no such command or directory")
+	// ErrTimeout The request didn't complete in time.
+	ErrTimeout = errors.New("request timed out")
+	// ErrNetwork The router can't be reached or the connection failed.
+	ErrNetwork = errors.New("network error")
+	// ErrDecode The response can't be decoded.
+	ErrDecode = errors.New("invalid response")
+)
+
+// API trap categories, https://help.mikrotik.com/docs/display/ROS/API
+const (
+	TrapCategoryNone        = -1
+	TrapCategoryMissingItem = 0
+	TrapCategoryArgument    = 1
+	TrapCategoryInterrupted = 2
+	TrapCategoryScripting   = 3
+	TrapCategoryGeneral     = 4
+	TrapCategoryAPI         = 5
+)
+
+// TrapError Error reply of the router to a request: an API !trap or a REST error response.
+// It matches ErrNotFound, ErrPermission and ErrAuth with errors.Is when the reply reports them.
+type TrapError struct {
+	// Request REST method and URL or API command
+	Request string
+	// StatusCode REST response code, 0 for API
+	StatusCode int
+	// Category API trap category, TrapCategoryNone for REST
+	Category int
+	Message  string
+	// Detail REST error details
+	Detail string
+}
+
+func (e *TrapError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("%s returned response code: %v, message: '%v', details: '%v'", e.Request, e.StatusCode, e.Message, e.Detail)
+	}
+	return fmt.Sprintf("%s: from RouterOS device: %s", e.Request, e.Message)
+}
+
+// Is Implements errors.Is.
+func (e *TrapError) Is(target error) bool {
+	text := strings.ToLower(e.Message + " " + e.Detail)
+	switch target {
+	case ErrNotFound:
+		// Only an unknown path or command, 'no such command or directory' for REST and
+		// 'no such command' or 'no such command prefix' for API. REST 404 and API category 0
+		// are also returned for missing items, e.g. 'no such item', which may exist next time.
+		return strings.Contains(text, "no such command")
+	case ErrPermission:
+		return e.StatusCode == http.StatusForbidden || isPermissionMessage(text)
+	case ErrAuth:
+		return e.StatusCode == http.StatusUnauthorized && !isPermissionMessage(text)
+	}
+	return false
+}
+
+// newAPITrapError Converts the routeros reply error. !fatal replies close the session,
+// they are network errors.
+func newAPITrapError(request string, err *routeros.DeviceError) error {
+	if err.Sentence.Word == "!fatal" {
+		return fmt.Errorf("%w: %s: %w", ErrNetwork, request, err)
+	}
+
+	var res = &TrapError{Request: request, Category: TrapCategoryNone, Message: err.Sentence.Map["message"]}
+	if c, ok := err.Sentence.Map["category"]; ok {
+		if n, err := strconv.Atoi(c); err == nil {
+			res.Category = n
+		}
+	}
+	return res
+}
+
+// transportError Classifies the error of the connection or the request as ErrTimeout or ErrNetwork.
+// Cancelled requests are returned as is.
+func transportError(err error) error {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return err
+	case errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return fmt.Errorf("%w: %w", ErrNetwork, err)
+}
+
+// IsTemporary Returns true if the request may succeed when retried: timeouts and network errors.
+func IsTemporary(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrNetwork)
+}
+
+// isPermissionMessage Returns true if the router error message reports missing policies,
+// e.g. 'not enough permissions (9)'.
+func isPermissionMessage(msg string) bool {
+	return strings.Contains(strings.ToLower(msg), "not enough permissions")
+}
+
+// Error reasons used in metric labels.
+const (
+	ReasonAuth       = "auth"
+	ReasonPermission = "permission"
+	ReasonNotFound   = "not_found"
+	ReasonTimeout    = "timeout"
+	ReasonNetwork    = "network"
+	ReasonDecode     = "decode"
+	ReasonTrap       = "trap"
+	ReasonOther      = "other"
+)
+
+// ErrorReason Returns the reason of the error for metric labels.
+func ErrorReason(err error) string {
+	var trap *TrapError
+	switch {
+	case errors.Is(err, ErrAuth):
+		return ReasonAuth
+	case errors.Is(err, ErrPermission):
+		return ReasonPermission
+	case errors.Is(err, ErrNotFound):
+		return ReasonNotFound
+	case errors.Is(err, ErrTimeout):
+		return ReasonTimeout
+	case errors.Is(err, ErrNetwork):
+		return ReasonNetwork
+	case errors.Is(err, ErrDecode):
+		return ReasonDecode
+	case errors.As(err, &trap):
+		return ReasonTrap
+	}
+	return ReasonOther
+}
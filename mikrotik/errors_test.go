@@ -0,0 +1,225 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-routeros/routeros"
+	"github.com/go-routeros/routeros/proto"
+)
+
+var sentinelErrors = []error{ErrAuth, ErrPermission, ErrNotFound, ErrTimeout, ErrNetwork, ErrDecode}
+
+// checkError Checks that the error matches only the wanted sentinel error and has the reason.
+func checkError(t *testing.T, err error, want error, reason string) {
+	t.Helper()
+
+	for _, target := range sentinelErrors {
+		if got := errors.Is(err, target); got != (target == want) {
+			t.Errorf("errors.Is(%v, %v) = %v", err, target, got)
+		}
+	}
+	if got := ErrorReason(err); got != reason {
+		t.Errorf("ErrorReason(%v) = %s, want %s", err, got, reason)
+	}
+}
+
+func TestRestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   int
+		body   string
+		want   error
+		reason string
+	}{
+		{
+			name: "unknown path", code: http.StatusNotFound,
+			body: `{"error":404,"message":"Not Found","detail":"no such command or directory (cloud)"}`,
+			want: ErrNotFound, reason: ReasonNotFound,
+		},
+		{
+			name: "missing item", code: http.StatusNotFound,
+			body: `{"error":404,"message":"Not Found","detail":"no such item"}`,
+			want: nil, reason: ReasonTrap,
+		},
+		{
+			name: "permission", code: http.StatusBadRequest,
+			body: `{"error":400,"message":"Bad Request","detail":"not enough permissions (9)"}`,
+			want: ErrPermission, reason: ReasonPermission,
+		},
+		{
+			name: "forbidden", code: http.StatusForbidden,
+			body: `{"error":403,"message":"Forbidden"}`,
+			want: ErrPermission, reason: ReasonPermission,
+		},
+		{
+			name: "unauthorized", code: http.StatusUnauthorized,
+			body: `{"error":401,"message":"Unauthorized"}`,
+			want: ErrAuth, reason: ReasonAuth,
+		},
+		{
+			name: "unauthorized without a body", code: http.StatusUnauthorized,
+			body: `<html>Unauthorized</html>`,
+			want: ErrAuth, reason: ReasonAuth,
+		},
+		{
+			name: "unauthorized policy", code: http.StatusUnauthorized,
+			body: `{"error":401,"message":"Unauthorized","detail":"not enough permissions (9)"}`,
+			want: ErrPermission, reason: ReasonPermission,
+		},
+		{
+			name: "invalid argument", code: http.StatusBadRequest,
+			body: `{"error":400,"message":"Bad Request","detail":"expected end of command"}`,
+			want: nil, reason: ReasonTrap,
+		},
+		{
+			name: "proxy page", code: http.StatusBadGateway,
+			body: `<html>Bad Gateway</html>`,
+			want: nil, reason: ReasonTrap,
+		},
+		{
+			name: "invalid response", code: http.StatusOK,
+			body: `[{"name":`,
+			want: ErrDecode, reason: ReasonDecode,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.code)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			creds := &Credentials{Username: "test"}
+			c := &RestClient{ctx: context.Background(), HostURL: srv.URL, creds: creds, Client: srv.Client()}
+			_, err := c.SendRequest(CrudRead, &URL{Path: "/ip/cloud"}, nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			checkError(t, err, tt.want, tt.reason)
+
+			// Only rejected logins are counted
+			var failures uint64
+			if tt.want == ErrAuth {
+				failures = 1
+			}
+			if got := creds.AuthFailures(); got != failures {
+				t.Errorf("auth failures %d, want %d", got, failures)
+			}
+
+			var trap *TrapError
+			if tt.code != http.StatusOK && tt.want != ErrAuth {
+				if !errors.As(err, &trap) {
+					t.Fatalf("errors.As(%v, *TrapError) = false", err)
+				}
+				if trap.StatusCode != tt.code {
+					t.Errorf("status code is %d, want %d", trap.StatusCode, tt.code)
+				}
+			}
+		})
+	}
+}
+
+func TestRestTransportErrors(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c := &RestClient{ctx: context.Background(), HostURL: srv.URL, creds: &Credentials{Username: "test"}, Client: srv.Client()}
+	_, err := c.SendRequest(CrudRead, &URL{Path: "/system/resource"}, nil)
+	checkError(t, err, ErrNetwork, ReasonNetwork)
+	if !IsTemporary(err) {
+		t.Errorf("IsTemporary(%v) = false", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+	c.ctx = ctx
+	_, err = c.SendRequest(CrudRead, &URL{Path: "/system/resource"}, nil)
+	checkError(t, err, ErrTimeout, ReasonTimeout)
+	if !IsTemporary(err) {
+		t.Errorf("IsTemporary(%v) = false", err)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		word     string
+		category string
+		message  string
+		want     error
+		reason   string
+	}{
+		{name: "unknown command", word: "!trap", category: "", message: "no such command prefix", want: ErrNotFound, reason: ReasonNotFound},
+		{name: "unknown command in category 0", word: "!trap", category: "0", message: "no such command", want: ErrNotFound, reason: ReasonNotFound},
+		{name: "missing item", word: "!trap", category: "0", message: "no such item", want: nil, reason: ReasonTrap},
+		{name: "invalid argument", word: "!trap", category: "1", message: "unknown parameter", want: nil, reason: ReasonTrap},
+		{name: "permission", word: "!trap", category: "", message: "not enough permissions (9)", want: ErrPermission, reason: ReasonPermission},
+		{name: "fatal", word: "!fatal", message: "session terminated on request", want: ErrNetwork, reason: ReasonNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = &proto.Sentence{Word: tt.word, Map: map[string]string{"message": tt.message}}
+			if tt.category != "" {
+				s.Map["category"] = tt.category
+			}
+
+			err := newAPITrapError("/interface/monitor-traffic", &routeros.DeviceError{Sentence: s})
+			checkError(t, err, tt.want, tt.reason)
+
+			var trap *TrapError
+			if tt.word == "!trap" {
+				if !errors.As(err, &trap) {
+					t.Fatalf("errors.As(%v, *TrapError) = false", err)
+				}
+				if trap.StatusCode != 0 || trap.Message != tt.message {
+					t.Errorf("trap is %+v", trap)
+				}
+			}
+		})
+	}
+}
+
+func TestTransportError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		want   error
+		reason string
+	}{
+		{name: "deadline", err: fmt.Errorf("/system/resource: %w", context.DeadlineExceeded), want: ErrTimeout, reason: ReasonTimeout},
+		{name: "net timeout", err: &net.OpError{Op: "dial", Err: timeoutError{}}, want: ErrTimeout, reason: ReasonTimeout},
+		{name: "refused", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: ErrNetwork, reason: ReasonNetwork},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := transportError(tt.err)
+			checkError(t, err, tt.want, tt.reason)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("the original error is not wrapped: %v", err)
+			}
+		})
+	}
+
+	// Cancelled requests are not temporary errors
+	err := transportError(context.Canceled)
+	if IsTemporary(err) || !errors.Is(err, context.Canceled) {
+		t.Errorf("transportError(context.Canceled) = %v", err)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
@@ -37,3 +37,8 @@ func LogMessage(ctx context.Context, level logLevel, msg string, args ...map[str
 		logger.Error().Fields(args).Msg(msg)
 	}
 }
+
+// traceEnabled Returns true if the trace messages are logged, to skip preparing them otherwise.
+func traceEnabled(ctx context.Context) bool {
+	return zerolog.Ctx(ctx).GetLevel() <= zerolog.TraceLevel
+}
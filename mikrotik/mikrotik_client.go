@@ -12,8 +12,6 @@ import (
 	"os"
 	"strings"
 	"time"
-
-	"github.com/go-routeros/routeros"
 )
 
 type Client interface {
@@ -29,14 +27,14 @@ const (
 	CrudRead CrudMethod = iota
 	CrudPost
 	CrudMonitor
+	CrudCommand
 )
 
 type Config struct {
 	Insecure      bool
 	CaCertificate string
 	HostURL       string
-	Username      string
-	Password      string
+	Credentials   *Credentials
 }
 
 func NewClient(ctx context.Context, conf *Config) (Client, error) {
@@ -101,33 +99,28 @@ func NewClient(ctx context.Context, conf *Config) (Client, error) {
 		api := &ApiClient{
 			ctx:       ctx,
 			HostURL:   routerUrl.Host,
-			Username:  conf.Username,
-			Password:  conf.Password,
 			Transport: TransportAPI,
+			conn: &apiConn{
+				hostURL: routerUrl.Host,
+				creds:   conf.Credentials,
+			},
 		}
-
 		if useTLS {
-			api.Client, err = routeros.DialTLS(api.HostURL, api.Username, api.Password, &tlsConf)
-		} else {
-			api.Client, err = routeros.Dial(api.HostURL, api.Username, api.Password)
+			api.conn.tlsConf = &tlsConf
 		}
-		if err != nil {
+
+		if _, err := api.conn.get(ctx); err != nil {
 			return nil, err
 		}
 
-		// The synchronous client has an infinite wait issue
-		// when an error occurs while creating multiple resources.
-		api.Async()
-
 		return api, nil
 	}
 
 	rest := &RestClient{
 		ctx:       ctx,
 		HostURL:   routerUrl.String(),
-		Username:  conf.Username,
-		Password:  conf.Password,
 		Transport: TransportREST,
+		creds:     conf.Credentials,
 	}
 
 	rest.Client = &http.Client{
@@ -151,6 +144,16 @@ func Ctx(ctx context.Context) Client {
 	return nil
 }
 
+// WithTimeout Returns a context with the timeout and the client from ctx bound to it,
+// so that the client requests are cancelled when the timeout expires.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	if c := Ctx(ctx); c != nil {
+		ctx = c.WithContext(ctx)
+	}
+	return ctx, cancel
+}
+
 type URL struct {
 	Path  string   // URL path without '/rest'.
 	Query []string // Query values.
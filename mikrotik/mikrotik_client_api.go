@@ -2,19 +2,110 @@ package mikrotik
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-routeros/routeros"
 )
 
+const apiDialTimeout = time.Minute
+
 type ApiClient struct {
 	ctx       context.Context
 	HostURL   string
-	Username  string
-	Password  string
 	Transport TransportType
-	*routeros.Client
+	conn      *apiConn
+}
+
+// apiConn Connection shared by the client copies. A failed connection is closed and the next
+// request connects and logs in again with the current credentials.
+type apiConn struct {
+	hostURL string
+	// tlsConf TLS configuration, nil for plain connections
+	tlsConf *tls.Config
+	creds   *Credentials
+
+	mu     sync.Mutex
+	client *routeros.Client
+}
+
+// get Returns the connection, connecting and logging in if needed. Connecting stops when
+// the context is done.
+func (a *apiConn) get(ctx context.Context) (*routeros.Client, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.client != nil {
+		return a.client, nil
+	}
+
+	var dialer = &net.Dialer{Timeout: apiDialTimeout}
+	var conn net.Conn
+	var err error
+	if a.tlsConf != nil {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: a.tlsConf}).DialContext(ctx, "tcp", a.hostURL)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", a.hostURL)
+	}
+	if err != nil {
+		return nil, transportError(err)
+	}
+
+	// The login doesn't take a context, the deadline bounds it
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	client, err := routeros.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := client.Login(a.creds.Username, a.creds.Password()); err != nil {
+		client.Close()
+		var devErr *routeros.DeviceError
+		switch {
+		case errors.As(err, &devErr) && isPermissionMessage(err.Error()):
+			// The group lacks the api policy
+			return nil, fmt.Errorf("%w: logging in to '%s': %v", ErrPermission, a.hostURL, err)
+		case errors.As(err, &devErr):
+			return nil, a.creds.authFailed("logging in to '%s': %v", a.hostURL, err)
+		}
+		return nil, transportError(err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	// The synchronous client has an infinite wait issue
+	// when an error occurs while creating multiple resources.
+	client.Async()
+
+	a.client = client
+	return client, nil
+}
+
+// reset Closes the failed connection unless it has already been replaced.
+func (a *apiConn) reset(client *routeros.Client) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.client == client {
+		a.client.Close()
+		a.client = nil
+	}
+}
+
+// isConnError Returns true if the error is not a reply of the router to the command, !trap,
+// so the connection has to be re-established.
+func isConnError(err error) bool {
+	var devErr *routeros.DeviceError
+	return !errors.As(err, &devErr) || devErr.Sentence.Word != "!trap"
 }
 
 var (
@@ -22,6 +113,7 @@ var (
 		CrudRead:    "/print",
 		CrudPost:    "/set",
 		CrudMonitor: "/monitor",
+		CrudCommand: "",
 	}
 )
 
@@ -37,18 +129,25 @@ func (c *ApiClient) SendRequest(method CrudMethod, url *URL, data map[string]str
 	// The first element is the Path
 	cmd[0] += apiMethodName[method]
 
+	var logCmd = append([]string{}, cmd...)
 	for k, v := range data {
 		cmd = append(cmd, fmt.Sprintf("=%v=%v", k, v))
 	}
-	LogMessage(c.ctx, DEBUG, "request CMD:  "+strings.Join(cmd, ""))
+	// The request may set secrets, they are not logged
+	for k, v := range RedactValues(url.Path, data) {
+		logCmd = append(logCmd, fmt.Sprintf("=%v=%v", k, v))
+	}
+	LogMessage(c.ctx, DEBUG, "request CMD:  "+strings.Join(logCmd, ""))
 
-	resp, err := c.RunArgs(cmd)
+	resp, err := c.runArgs(cmd)
 	if err != nil {
+		var devErr *routeros.DeviceError
+		if errors.As(err, &devErr) {
+			return nil, newAPITrapError(strings.Join(logCmd, ""), devErr)
+		}
 		return nil, err
 	}
 
-	LogMessage(c.ctx, TRACE, "response body: "+resp.String())
-
 	// Unmarshal
 	var res []MikrotikItem
 
@@ -60,6 +159,10 @@ func (c *ApiClient) SendRequest(method CrudMethod, url *URL, data map[string]str
 		res = append(res, m)
 	}
 
+	if traceEnabled(c.ctx) {
+		LogMessage(c.ctx, TRACE, fmt.Sprintf("response: %v", RedactItems(url.Path, res)))
+	}
+
 	if len(res) == 0 {
 		return nil, nil
 	}
@@ -67,9 +170,73 @@ func (c *ApiClient) SendRequest(method CrudMethod, url *URL, data map[string]str
 	return res, nil
 }
 
-func (c *ApiClient) WithContext(ctx context.Context) context.Context {
-	if _, ok := ctx.Value(ctxKey{}).(*ApiClient); !ok {
-		return ctx
+// runArgs Connects if needed, runs the command and waits for the reply until the client context is done.
+func (c *ApiClient) runArgs(cmd []string) (*routeros.Reply, error) {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
 	}
-	return context.WithValue(ctx, ctxKey{}, c)
+
+	// running Connection the command is sent on, reset when the context is done first
+	var running atomic.Pointer[routeros.Client]
+
+	run := func() (*routeros.Reply, error) {
+		// Connecting waits for the other requests connecting meanwhile, it's cancellable too
+		client, err := c.conn.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		running.Store(client)
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		reply, err := client.RunArgs(cmd)
+		if err != nil && isConnError(err) {
+			LogMessage(c.ctx, DEBUG, "API connection failed, reconnecting on the next request", map[string]interface{}{"error": err})
+			c.conn.reset(client)
+
+			var devErr *routeros.DeviceError
+			if !errors.As(err, &devErr) {
+				err = transportError(err)
+			}
+		}
+		return reply, err
+	}
+
+	if ctx.Done() == nil {
+		return run()
+	}
+
+	type result struct {
+		reply *routeros.Reply
+		err   error
+	}
+	var done = make(chan result, 1)
+
+	go func() {
+		reply, err := run()
+		done <- result{reply, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.reply, res.err
+	case <-ctx.Done():
+		// The reply may never come, closing the connection stops the waiting command.
+		// The other commands on it fail and the next request reconnects.
+		if client := running.Load(); client != nil {
+			c.conn.reset(client)
+		}
+		// Only the command path, the arguments may hold secrets
+		return nil, transportError(fmt.Errorf("%s: %w", cmd[0], ctx.Err()))
+	}
+}
+
+// WithContext Returns a context with a copy of the client bound to it,
+// requests of the copy stop waiting for the reply when the context is done.
+func (c *ApiClient) WithContext(ctx context.Context) context.Context {
+	cc := *c
+	cc.ctx = ctx
+	return context.WithValue(ctx, ctxKey{}, &cc)
 }
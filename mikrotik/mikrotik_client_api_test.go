@@ -0,0 +1,159 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hangingRouter Accepts API connections and never replies.
+func hangingRouter(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		l.Close()
+		mu.Lock()
+		for _, c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	})
+
+	return l.Addr().String()
+}
+
+func TestApiConnectHonoursContext(t *testing.T) {
+	addr := hangingRouter(t)
+	client := &ApiClient{
+		HostURL:   addr,
+		Transport: TransportAPI,
+		conn:      &apiConn{hostURL: addr, creds: &Credentials{Username: "test"}},
+	}
+
+	// Concurrent requests waiting for the connection stop at their own timeout
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			c := Ctx(client.WithContext(ctx)).(*ApiClient)
+
+			start := time.Now()
+			_, err := c.runArgs([]string{"/system/resource/print"})
+			if !errors.Is(err, ErrTimeout) {
+				t.Errorf("got error %v, want ErrTimeout", err)
+			}
+			if d := time.Since(start); d > time.Second {
+				t.Errorf("request returned after %v", d)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The login timed out, the connection is not kept
+	time.Sleep(100 * time.Millisecond)
+	client.conn.mu.Lock()
+	defer client.conn.mu.Unlock()
+	if client.conn.client != nil {
+		t.Error("the failed connection is kept")
+	}
+}
+
+// stuckRouter Accepts the API login and never replies to the commands. The closed channel
+// reports the connection was closed by the client.
+func stuckRouter(t *testing.T) (string, <-chan struct{}) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	closed := make(chan struct{})
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		// The sentences are short, the word length is a single byte
+		var sentences int
+		var b = make([]byte, 256)
+		for {
+			if _, err := c.Read(b[:1]); err != nil {
+				close(closed)
+				return
+			}
+			if b[0] != 0 {
+				if _, err := io.ReadFull(c, b[:b[0]]); err != nil {
+					close(closed)
+					return
+				}
+				continue
+			}
+			// The end of the sentence, only the login is answered
+			if sentences++; sentences == 1 {
+				_, _ = c.Write([]byte("\x05!done\x00"))
+			}
+		}
+	}()
+
+	return l.Addr().String(), closed
+}
+
+func TestApiCommandTimeoutResetsConnection(t *testing.T) {
+	addr, closed := stuckRouter(t)
+	client := &ApiClient{
+		HostURL:   addr,
+		Transport: TransportAPI,
+		conn:      &apiConn{hostURL: addr, creds: &Credentials{Username: "test"}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	c := Ctx(client.WithContext(ctx)).(*ApiClient)
+
+	if _, err := c.runArgs([]string{"/system/resource/print"}); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("got error %v, want ErrTimeout", err)
+	}
+
+	// The waiting command is stopped by closing the connection
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("the connection of the timed out command is not closed")
+	}
+
+	client.conn.mu.Lock()
+	defer client.conn.mu.Unlock()
+	if client.conn.client != nil {
+		t.Error("the connection of the timed out command is kept")
+	}
+}
@@ -13,9 +13,8 @@ import (
 type RestClient struct {
 	ctx       context.Context
 	HostURL   string
-	Username  string
-	Password  string
 	Transport TransportType
+	creds     *Credentials
 	*http.Client
 }
 
@@ -30,6 +29,7 @@ var (
 		CrudRead:    "GET",
 		CrudPost:    "POST",
 		CrudMonitor: "POST",
+		CrudCommand: "POST",
 	}
 )
 
@@ -48,9 +48,11 @@ func (c *RestClient) SendRequest(method CrudMethod, url *URL, data map[string]st
 		if err != nil {
 			return nil, err
 		}
-		bb = string(b)
-
 		buf = bytes.NewBuffer(b)
+
+		// The request may set secrets, they are not logged
+		b, _ = json.Marshal(RedactValues(url.Path, data))
+		bb = string(b)
 	}
 
 	// https://mikrotik + /rest + /interface/vlan + ? + .id=*39
@@ -64,16 +66,20 @@ func (c *RestClient) SendRequest(method CrudMethod, url *URL, data map[string]st
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(c.Username, c.Password)
+	// The password is read on every request, so a rotated password is used right away
+	req.SetBasicAuth(c.creds.Username, c.creds.Password())
 
 	res, err := c.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, transportError(err)
 	}
 
 	defer func() { _ = res.Body.Close() }()
 
-	body, _ := io.ReadAll(res.Body)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, transportError(err)
+	}
 
 	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
 		var errRes errorResponse
@@ -81,14 +87,23 @@ func (c *RestClient) SendRequest(method CrudMethod, url *URL, data map[string]st
 		LogMessage(c.ctx, DEBUG, fmt.Sprintf("error response body:\n%s", body))
 
 		if err = json.Unmarshal(body, &errRes); err != nil {
-			return nil, fmt.Errorf("json.Unmarshal - %v", err)
-		} else {
-			return nil, fmt.Errorf("%v '%v' returned response code: %v, message: '%v', details: '%v'",
-				restMethodName[method], requestUrl, res.StatusCode, errRes.Message, errRes.Detail)
+			// Not a RouterOS error, e.g. a proxy page
+			errRes.Message = http.StatusText(res.StatusCode)
 		}
-	}
 
-	LogMessage(c.ctx, TRACE, "response body: "+string(body))
+		// RouterOS also returns 401 for some policy denials, their detail is a permission message
+		if res.StatusCode == http.StatusUnauthorized && !isPermissionMessage(errRes.Message+" "+errRes.Detail) {
+			return nil, c.creds.authFailed("%v '%v' returned response code: %v", restMethodName[method], requestUrl, res.StatusCode)
+		}
+
+		return nil, &TrapError{
+			Request:    fmt.Sprintf("%v '%v'", restMethodName[method], requestUrl),
+			StatusCode: res.StatusCode,
+			Category:   TrapCategoryNone,
+			Message:    errRes.Message,
+			Detail:     errRes.Detail,
+		}
+	}
 
 	if len(body) > 2 {
 		var result []MikrotikItem
@@ -106,19 +121,29 @@ func (c *RestClient) SendRequest(method CrudMethod, url *URL, data map[string]st
 				LogMessage(c.ctx, DEBUG, fmt.Sprintf("json.Unmarshal(response body): syntax error at byte offset %d", e.Offset))
 
 				if err = json.Unmarshal(EscapeChars(body), &rp); err != nil {
-					return nil, fmt.Errorf("json.Unmarshal(response body): %v", err)
+					return nil, fmt.Errorf("%w: json.Unmarshal(response body): %w", ErrDecode, err)
 				}
 			} else {
-				return nil, err
+				return nil, fmt.Errorf("%w: json.Unmarshal(response body): %w", ErrDecode, err)
 			}
 		}
 
+		// The response is logged after parsing to redact the secrets
+		if traceEnabled(c.ctx) {
+			b, _ := json.Marshal(RedactItems(url.Path, result))
+			LogMessage(c.ctx, TRACE, "response body: "+string(b))
+		}
+
 		return result, nil
 	}
 
 	return nil, nil
 }
 
+// WithContext Returns a context with a copy of the client bound to it,
+// requests of the copy are cancelled with the context.
 func (c *RestClient) WithContext(ctx context.Context) context.Context {
-	return context.WithValue(ctx, ctxKey{}, c)
+	cc := *c
+	cc.ctx = ctx
+	return context.WithValue(ctx, ctxKey{}, &cc)
 }
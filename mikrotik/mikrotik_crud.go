@@ -2,6 +2,7 @@ package mikrotik
 
 import (
 	"fmt"
+	"strings"
 )
 
 var (
@@ -9,11 +10,16 @@ var (
 )
 
 func Monitor(resourcePath string, c Client, data map[string]string) ([]MikrotikItem, error) {
+	return Command(resourcePath, "monitor", c, data)
+}
+
+// Command Runs the resource command, e.g. '/interface/ethernet' + 'monitor'.
+func Command(resourcePath, command string, c Client, data map[string]string) ([]MikrotikItem, error) {
 	if resourcePath == "" {
 		return nil, errEmptyPath
 	}
 
-	return c.SendRequest(CrudMonitor, &URL{Path: resourcePath + "/monitor"}, data)
+	return c.SendRequest(CrudCommand, &URL{Path: resourcePath + "/" + command}, data)
 }
 
 func Read(resourcePath string, c Client, data map[string]string) ([]MikrotikItem, error) {
@@ -39,3 +45,91 @@ func ReadFiltered(filter []string, resourcePath string, c Client, data map[strin
 	}
 	return c.SendRequest(CrudRead, &URL{Path: resourcePath, Query: filter}, data)
 }
+
+// BatchCommand Command executed once for a batch of resource rows in a single request,
+// e.g. '/interface/ethernet/monitor numbers=*1,*2 once'.
+type BatchCommand struct {
+	ResourcePath string
+	Command      string
+	// IdField Field of the rows passed to the command, '.id' by default
+	IdField string
+	// NumbersArg Command argument with the comma-separated list of ids, 'numbers' by default
+	NumbersArg string
+	// Args Additional command arguments
+	Args map[string]string
+}
+
+// Run Runs the command for the rows and returns the results in the order of the rows.
+// The results are matched to the rows by the id field, or by the name when the results don't carry
+// the ids; only results without both are matched by their order. Rows without an id or
+// without a result get nil.
+func (b *BatchCommand) Run(c Client, items []MikrotikItem) ([]MikrotikItem, error) {
+	idField, numbersArg := b.IdField, b.NumbersArg
+	if idField == "" {
+		idField = ".id"
+	}
+	if numbersArg == "" {
+		numbersArg = "numbers"
+	}
+
+	var ids []string
+	var rows []int
+	for i, item := range items {
+		if id, ok := item[idField]; ok && id != "" {
+			ids = append(ids, id)
+			rows = append(rows, i)
+		}
+	}
+
+	var res = make([]MikrotikItem, len(items))
+	if len(ids) == 0 {
+		return res, nil
+	}
+
+	var data = make(map[string]string, len(b.Args)+2)
+	for k, v := range b.Args {
+		data[k] = v
+	}
+	data[numbersArg] = strings.Join(ids, ",")
+	data["once"] = ""
+
+	out, err := Command(b.ResourcePath, b.Command, c, data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{idField, "name"} {
+		byKey, ok := indexResults(out, key)
+		if !ok {
+			continue
+		}
+		for _, i := range rows {
+			if v := items[i][key]; v != "" {
+				res[i] = byKey[v]
+			}
+		}
+		return res, nil
+	}
+
+	// Results without ids and names are returned in the order of ids
+	if len(out) == len(ids) {
+		for i, r := range out {
+			res[rows[i]] = r
+		}
+	}
+
+	return res, nil
+}
+
+// indexResults Indexes the results by the field. Returns false if a result doesn't have the field.
+func indexResults(out []MikrotikItem, field string) (map[string]MikrotikItem, bool) {
+	var res = make(map[string]MikrotikItem, len(out))
+	for _, r := range out {
+		v := r[field]
+		if v == "" {
+			return nil, false
+		}
+		res[v] = r
+	}
+	return res, true
+}
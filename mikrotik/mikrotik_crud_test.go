@@ -0,0 +1,131 @@
+package mikrotik
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// commandClient Client returning the command results and recording the requests.
+type commandClient struct {
+	out      []MikrotikItem
+	requests []*URL
+	data     []map[string]string
+}
+
+func (c *commandClient) GetTransport() TransportType { return TransportREST }
+
+func (c *commandClient) SendRequest(method CrudMethod, url *URL, data map[string]string) ([]MikrotikItem, error) {
+	c.requests = append(c.requests, url)
+	c.data = append(c.data, data)
+	return c.out, nil
+}
+
+func (c *commandClient) WithContext(ctx context.Context) context.Context { return ctx }
+
+func TestBatchCommandRequest(t *testing.T) {
+	c := &commandClient{}
+	cmd := &BatchCommand{ResourcePath: "/interface/ethernet", Command: "monitor", Args: map[string]string{"duration": "1s"}}
+
+	items := []MikrotikItem{{".id": "*1"}, {"name": "no-id"}, {".id": "*3"}}
+	if _, err := cmd.Run(c, items); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.requests) != 1 || c.requests[0].Path != "/interface/ethernet/monitor" {
+		t.Fatalf("requests %v", c.requests)
+	}
+	want := map[string]string{"numbers": "*1,*3", "once": "", "duration": "1s"}
+	if !reflect.DeepEqual(c.data[0], want) {
+		t.Errorf("command data %v, want %v", c.data[0], want)
+	}
+
+	// Custom id field and argument
+	c = &commandClient{}
+	cmd = &BatchCommand{ResourcePath: "/interface/wireless", Command: "monitor", IdField: "name", NumbersArg: "interface"}
+	if _, err := cmd.Run(c, []MikrotikItem{{"name": "wlan1"}, {"name": "wlan2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "wlan1,wlan2"; c.data[0]["interface"] != want {
+		t.Errorf("interface argument '%s', want '%s'", c.data[0]["interface"], want)
+	}
+
+	// No request without ids
+	c = &commandClient{}
+	res, err := cmd.Run(c, []MikrotikItem{{"comment": "x"}})
+	if err != nil || len(c.requests) != 0 || len(res) != 1 || res[0] != nil {
+		t.Errorf("rows without ids: requests %v, result %v, error %v", c.requests, res, err)
+	}
+}
+
+func TestBatchCommandResults(t *testing.T) {
+	items := []MikrotikItem{
+		{".id": "*1", "name": "sfp1"},
+		{".id": "*2", "name": "sfp2"},
+		{"name": "no-id"},
+		{".id": "*4", "name": "sfp4"},
+	}
+
+	tests := []struct {
+		name string
+		out  []MikrotikItem
+		// want Expected result values by row
+		want []string
+	}{
+		{
+			name: "by id",
+			out:  []MikrotikItem{{".id": "*4", "v": "4"}, {".id": "*1", "v": "1"}, {".id": "*2", "v": "2"}},
+			want: []string{"1", "2", "", "4"},
+		},
+		{
+			name: "by id with a missing result",
+			out:  []MikrotikItem{{".id": "*4", "v": "4"}, {".id": "*1", "v": "1"}},
+			want: []string{"1", "", "", "4"},
+		},
+		{
+			name: "by name",
+			out:  []MikrotikItem{{"name": "sfp2", "v": "2"}, {"name": "sfp1", "v": "1"}, {"name": "sfp4", "v": "4"}},
+			want: []string{"1", "2", "", "4"},
+		},
+		{
+			name: "by name with a missing result",
+			out:  []MikrotikItem{{"name": "sfp4", "v": "4"}, {"name": "sfp1", "v": "1"}},
+			want: []string{"1", "", "", "4"},
+		},
+		{
+			name: "by order",
+			out:  []MikrotikItem{{"v": "1"}, {"v": "2"}, {"v": "4"}},
+			want: []string{"1", "2", "", "4"},
+		},
+		{
+			name: "results without keys can't be matched",
+			out:  []MikrotikItem{{"v": "1"}, {"v": "2"}},
+			want: []string{"", "", "", ""},
+		},
+		{
+			name: "no results",
+			want: []string{"", "", "", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &BatchCommand{ResourcePath: "/interface/ethernet", Command: "monitor"}
+			res, err := cmd.Run(&commandClient{out: tt.out}, items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) != len(items) {
+				t.Fatalf("got %d results, want %d", len(res), len(items))
+			}
+
+			var got = make([]string, len(res))
+			for i, r := range res {
+				got[i] = r["v"]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
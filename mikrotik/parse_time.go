@@ -0,0 +1,30 @@
+package mikrotik
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeLayouts Date and time formats of RouterOS fields: 7.10 and later, older versions.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"Jan/02/2006 15:04:05",
+}
+
+// ParseTime Parses the RouterOS date and time, e.g. '2024-05-10 12:00:00' or 'may/10/2024 12:00:00'.
+// RouterOS returns the router local time, loc is its time zone.
+func ParseTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if len(s) > 0 {
+		// Month names are lower case
+		s = strings.ToUpper(s[:1]) + s[1:]
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date and time '%s'", s)
+}
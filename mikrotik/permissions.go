@@ -0,0 +1,91 @@
+package mikrotik
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// User group policies the exporter may need.
+const (
+	PolicyRead      = "read"
+	PolicyTest      = "test"
+	PolicySensitive = "sensitive"
+	PolicyAPI       = "api"
+	PolicyRestAPI   = "rest-api"
+)
+
+// TransportPolicy Returns the policy the transport needs to log in.
+func TransportPolicy(t TransportType) string {
+	if t == TransportAPI {
+		return PolicyAPI
+	}
+	return PolicyRestAPI
+}
+
+// UserPolicies Policies of the user group.
+type UserPolicies struct {
+	User  string
+	Group string
+	// policies Granted (true) and denied (false) policies, policies unknown to the RouterOS version are absent
+	policies map[string]bool
+}
+
+// ReadUserPolicies Reads the group of the user and its policies.
+func ReadUserPolicies(c Client, username string) (*UserPolicies, error) {
+	users, err := ReadFiltered([]string{"name=" + username}, "/user", c, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading user '%s': %w", username, err)
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("reading user '%s': user not found", username)
+	}
+
+	var res = &UserPolicies{User: username, Group: users[0]["group"], policies: make(map[string]bool)}
+
+	groups, err := ReadFiltered([]string{"name=" + res.Group}, "/user/group", c, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading user group '%s': %w", res.Group, err)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("reading user group '%s': group not found", res.Group)
+	}
+
+	// local,telnet,ssh,!ftp,!reboot,read,...
+	for _, p := range strings.Split(groups[0]["policy"], ",") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if name, denied := strings.CutPrefix(p, "!"); denied {
+			res.policies[name] = false
+		} else {
+			res.policies[p] = true
+		}
+	}
+
+	return res, nil
+}
+
+// Granted Returns the granted policies sorted by name.
+func (u *UserPolicies) Granted() []string {
+	var res []string
+	for p, granted := range u.policies {
+		if granted {
+			res = append(res, p)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Missing Returns the required policies that are not granted. Policies the RouterOS version
+// doesn't know, e.g. rest-api before 7.x, are not reported.
+func (u *UserPolicies) Missing(required []string) []string {
+	var res []string
+	for _, p := range required {
+		if granted, known := u.policies[p]; known && !granted {
+			res = append(res, p)
+		}
+	}
+	return res
+}
@@ -0,0 +1,110 @@
+package mikrotik
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// readClient Client returning the rows of the path matching the 'name=value' query.
+type readClient struct {
+	rows map[string][]MikrotikItem
+	err  error
+}
+
+func (c *readClient) GetTransport() TransportType { return TransportREST }
+
+func (c *readClient) SendRequest(method CrudMethod, url *URL, data map[string]string) ([]MikrotikItem, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	var res []MikrotikItem
+next:
+	for _, row := range c.rows[url.Path] {
+		for _, q := range url.Query {
+			k, v, _ := strings.Cut(q, "=")
+			if row[k] != v {
+				continue next
+			}
+		}
+		res = append(res, row)
+	}
+	return res, nil
+}
+
+func (c *readClient) WithContext(ctx context.Context) context.Context { return ctx }
+
+func TestReadUserPolicies(t *testing.T) {
+	c := &readClient{rows: map[string][]MikrotikItem{
+		"/user": {
+			{"name": "admin", "group": "full"},
+			{"name": "prometheus", "group": "monitoring"},
+			{"name": "orphan", "group": "removed"},
+		},
+		"/user/group": {
+			{"name": "full", "policy": "local,telnet,ssh,read,write,test,api,rest-api,sensitive"},
+			{"name": "monitoring", "policy": "local,!telnet,!ssh, read ,!write,!test,api,!rest-api,!sensitive,"},
+		},
+	}}
+
+	u, err := ReadUserPolicies(c, "prometheus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.User != "prometheus" || u.Group != "monitoring" {
+		t.Errorf("user '%s', group '%s'", u.User, u.Group)
+	}
+	if want := []string{"api", "local", "read"}; !reflect.DeepEqual(u.Granted(), want) {
+		t.Errorf("granted %v, want %v", u.Granted(), want)
+	}
+
+	tests := []struct {
+		required []string
+		want     []string
+	}{
+		{required: []string{PolicyRestAPI, PolicyRead}, want: []string{PolicyRestAPI}},
+		{required: []string{PolicyAPI, PolicyRead, PolicyTest, PolicySensitive}, want: []string{PolicyTest, PolicySensitive}},
+		{required: []string{PolicyAPI, PolicyRead}, want: nil},
+		// Unknown to the RouterOS version
+		{required: []string{"romon"}, want: nil},
+	}
+	for _, tt := range tests {
+		if got := u.Missing(tt.required); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Missing(%v) = %v, want %v", tt.required, got, tt.want)
+		}
+	}
+
+	u, err = ReadUserPolicies(c, "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Missing([]string{PolicyRestAPI, PolicyRead, PolicyTest, PolicySensitive}); got != nil {
+		t.Errorf("full group misses %v", got)
+	}
+}
+
+func TestReadUserPoliciesErrors(t *testing.T) {
+	c := &readClient{rows: map[string][]MikrotikItem{
+		"/user":       {{"name": "orphan", "group": "removed"}},
+		"/user/group": {{"name": "full", "policy": "read"}},
+	}}
+
+	tests := map[string]string{
+		"unknown": "reading user 'unknown': user not found",
+		"orphan":  "reading user group 'removed': group not found",
+	}
+	for user, want := range tests {
+		if _, err := ReadUserPolicies(c, user); err == nil || err.Error() != want {
+			t.Errorf("%s: got error '%v', want '%s'", user, err, want)
+		}
+	}
+
+	// Reading /user needs the read policy
+	c.err = &TrapError{StatusCode: 400, Message: "Bad Request", Detail: "not enough permissions (9)"}
+	if _, err := ReadUserPolicies(c, "orphan"); !errors.Is(err, ErrPermission) {
+		t.Errorf("got error '%v', want '%v'", err, ErrPermission)
+	}
+}
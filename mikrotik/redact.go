@@ -0,0 +1,67 @@
+package mikrotik
+
+import "strings"
+
+// RedactedValue Replaces the values of the sensitive fields.
+const RedactedValue = "<redacted>"
+
+// SensitiveFields Fields holding secrets in any resource, e.g. /interface/wireguard private-key,
+// /ppp/secret password, /ip/ipsec/identity secret.
+var SensitiveFields = map[string]bool{
+	"password":                true,
+	"private-key":             true,
+	"preshared-key":           true,
+	"secret":                  true,
+	"passphrase":              true,
+	"wpa-pre-shared-key":      true,
+	"wpa2-pre-shared-key":     true,
+	"authentication-password": true,
+	"encryption-password":     true,
+	"auth-key":                true,
+}
+
+// sensitivePathFields Fields holding secrets in a particular resource.
+var sensitivePathFields = map[string]map[string]bool{
+	// The SNMP v1/v2c community name is the shared secret
+	"/snmp/community": {"name": true},
+}
+
+// IsSensitiveField Returns true if the resource field holds a secret.
+func IsSensitiveField(resourcePath, field string) bool {
+	if SensitiveFields[field] {
+		return true
+	}
+	return sensitivePathFields["/"+strings.Trim(resourcePath, "/")][field]
+}
+
+// RedactItems Returns the rows with the values of the sensitive fields replaced.
+// Rows without sensitive fields are not copied.
+func RedactItems(resourcePath string, items []MikrotikItem) []MikrotikItem {
+	var res = make([]MikrotikItem, len(items))
+	for i, item := range items {
+		res[i] = RedactValues(resourcePath, item)
+	}
+	return res
+}
+
+// RedactValues Returns the values with the sensitive fields replaced, a copy is made only if needed.
+func RedactValues(resourcePath string, values map[string]string) map[string]string {
+	var res map[string]string
+	for k := range values {
+		if !IsSensitiveField(resourcePath, k) {
+			continue
+		}
+		if res == nil {
+			res = make(map[string]string, len(values))
+			for k, v := range values {
+				res[k] = v
+			}
+		}
+		res[k] = RedactedValue
+	}
+
+	if res == nil {
+		return values
+	}
+	return res
+}
@@ -0,0 +1,68 @@
+package mikrotik
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsSensitiveField(t *testing.T) {
+	tests := []struct {
+		path, field string
+		want        bool
+	}{
+		{path: "/ppp/secret", field: "password", want: true},
+		{path: "/interface/wireguard", field: "private-key", want: true},
+		{path: "/interface/wireguard/peers", field: "preshared-key", want: true},
+		{path: "/ip/ipsec/identity", field: "secret", want: true},
+		{path: "/interface/wifi/security", field: "passphrase", want: true},
+		{path: "/snmp/community", field: "authentication-password", want: true},
+		// The community name is the secret of SNMP v1/v2c
+		{path: "/snmp/community", field: "name", want: true},
+		{path: "snmp/community/", field: "name", want: true},
+		{path: "/snmp/community", field: "addresses", want: false},
+		{path: "/interface", field: "name", want: false},
+		{path: "/ppp/secret", field: "service", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := IsSensitiveField(tt.path, tt.field); got != tt.want {
+			t.Errorf("IsSensitiveField(%s, %s) = %v, want %v", tt.path, tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	values := map[string]string{"name": "vpn", "password": "secret", "service": "any"}
+	orig := map[string]string{"name": "vpn", "password": "secret", "service": "any"}
+
+	got := RedactValues("/ppp/secret", values)
+	want := map[string]string{"name": "vpn", "password": RedactedValue, "service": "any"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(values, orig) {
+		t.Errorf("the input was modified: %v", values)
+	}
+
+	// Values without secrets are returned as is
+	plain := map[string]string{"name": "ether1"}
+	if got := RedactValues("/interface", plain); reflect.ValueOf(got).Pointer() != reflect.ValueOf(plain).Pointer() {
+		t.Error("values without secrets were copied")
+	}
+
+	if got := RedactValues("/snmp/community", map[string]string{"name": "public"}); got["name"] != RedactedValue {
+		t.Errorf("community name is not redacted: %v", got)
+	}
+}
+
+func TestRedactItems(t *testing.T) {
+	items := []MikrotikItem{{"name": "a", "private-key": "k1"}, {"name": "b"}}
+
+	got := RedactItems("/interface/wireguard", items)
+	if got[0]["private-key"] != RedactedValue || got[0]["name"] != "a" || got[1]["name"] != "b" {
+		t.Errorf("got %v", got)
+	}
+	if items[0]["private-key"] != "k1" {
+		t.Errorf("the input was modified: %v", items)
+	}
+}
@@ -0,0 +1,287 @@
+package mikrotik
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SystemInfo RouterOS facts used to check the requirements of schemas and collectors.
+type SystemInfo struct {
+	// Version RouterOS version without the channel, e.g. 7.14.2
+	Version string
+	// BoardName Board name from /system/resource
+	BoardName string
+	// Packages Installed and enabled packages
+	Packages map[string]string
+	// Uptime Time since the router boot
+	Uptime time.Duration
+}
+
+// ReadSystemInfo Reads /system/resource and /system/package.
+func ReadSystemInfo(c Client) (*SystemInfo, error) {
+	res, err := Read("/system/resource", c, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading system resource: %w", err)
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("reading system resource: empty response")
+	}
+
+	var info = &SystemInfo{
+		Version:   res[0]["version"],
+		BoardName: res[0]["board-name"],
+		Packages:  make(map[string]string),
+	}
+	info.Uptime, _ = ParseDuration(res[0]["uptime"])
+	// 7.14.2 (stable)
+	if i := strings.IndexByte(info.Version, ' '); i > 0 {
+		info.Version = info.Version[:i]
+	}
+
+	packages, err := Read("/system/package", c, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading system packages: %w", err)
+	}
+	for _, p := range packages {
+		if BoolFromMikrotikJSONToFloat(p["disabled"]) == 0 {
+			info.Packages[p["name"]] = p["version"]
+		}
+	}
+
+	return info, nil
+}
+
+// Equal Returns true if the facts used to check requirements are the same.
+func (info *SystemInfo) Equal(other *SystemInfo) bool {
+	if info == nil || other == nil {
+		return info == other
+	}
+	if info.Version != other.Version || info.BoardName != other.BoardName || len(info.Packages) != len(other.Packages) {
+		return false
+	}
+	for k, v := range info.Packages {
+		if ov, ok := other.Packages[k]; !ok || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Requirements RouterOS version, packages and resources required by a schema or a collector.
+type Requirements struct {
+	// Version Comma-separated list of version constraints, e.g. '>=7.13,<8'
+	Version string `yaml:"version,omitempty"`
+	// Packages Packages that must be installed and enabled
+	Packages []string `yaml:"packages,omitempty"`
+	// Board Regular expression the board name must match
+	Board string `yaml:"board,omitempty"`
+	// Paths Resource paths that must be readable, e.g. /interface/ethernet/poe
+	Paths []string `yaml:"paths,omitempty"`
+}
+
+// IsEmpty Returns true if there are no requirements.
+func (r *Requirements) IsEmpty() bool {
+	return r.Version == "" && len(r.Packages) == 0 && r.Board == "" && len(r.Paths) == 0
+}
+
+// Validate Checks the syntax of the version constraints and the board regular expression.
+func (r *Requirements) Validate() error {
+	if _, err := parseVersionConstraints(r.Version); err != nil {
+		return err
+	}
+	if _, err := regexp.Compile(r.Board); err != nil {
+		return fmt.Errorf("board: %w", err)
+	}
+	return nil
+}
+
+// Check Returns an error describing the first unsatisfied requirement.
+// Resource paths are probed with the client from the context.
+func (r *Requirements) Check(ctx context.Context, info *SystemInfo) error {
+	constraints, err := parseVersionConstraints(r.Version)
+	if err != nil {
+		return err
+	}
+
+	if info == nil && (len(constraints) > 0 || len(r.Packages) > 0 || r.Board != "") {
+		return fmt.Errorf("router system information is not available")
+	}
+
+	for _, c := range constraints {
+		if !c.match(info.Version) {
+			return fmt.Errorf("requires RouterOS version %s%s, found %s", c.op, c.version, info.Version)
+		}
+	}
+
+	for _, p := range r.Packages {
+		if _, ok := info.Packages[p]; !ok {
+			return fmt.Errorf("requires package '%s'", p)
+		}
+	}
+
+	if r.Board != "" {
+		re, err := regexp.Compile(r.Board)
+		if err != nil {
+			return fmt.Errorf("board: %w", err)
+		}
+		if !re.MatchString(info.BoardName) {
+			return fmt.Errorf("requires board matching '%s', found '%s'", r.Board, info.BoardName)
+		}
+	}
+
+	for _, p := range r.Paths {
+		if _, err := Read(p, Ctx(ctx), nil); err != nil {
+			return fmt.Errorf("requires resource '%s': %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+type versionConstraint struct {
+	op      string
+	version string
+}
+
+func parseVersionConstraints(s string) ([]versionConstraint, error) {
+	var res []versionConstraint
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+
+		var op = "=="
+		for _, o := range []string{">=", "<=", "==", "!=", ">", "<", "="} {
+			if strings.HasPrefix(c, o) {
+				op = o
+				c = strings.TrimSpace(c[len(o):])
+				break
+			}
+		}
+		if op == "=" {
+			op = "=="
+		}
+
+		if _, err := parseVersion(c); err != nil {
+			return nil, err
+		}
+		res = append(res, versionConstraint{op: op, version: c})
+	}
+	return res, nil
+}
+
+func (c versionConstraint) match(version string) bool {
+	res := CompareVersions(version, c.version)
+	switch c.op {
+	case ">=":
+		return res >= 0
+	case "<=":
+		return res <= 0
+	case ">":
+		return res > 0
+	case "<":
+		return res < 0
+	case "!=":
+		return res != 0
+	default:
+		return res == 0
+	}
+}
+
+// preReleases Pre-release suffixes in ascending order, a release is after all of them.
+var preReleases = []string{"alpha", "beta", "rc"}
+
+// parseVersion Parses versions like 7.14.2 or 7.15beta4 into the numbers, followed by the pre-release
+// rank and number: 7.15beta4 is [7 15 1 4], 7.15 is [7 15 3 0].
+func parseVersion(s string) ([]int, error) {
+	if s == "" {
+		return nil, fmt.Errorf("empty version")
+	}
+
+	var res []int
+	var pre = []int{len(preReleases), 0}
+	parts := strings.Split(s, ".")
+	for n, part := range parts {
+		i := 0
+		for i < len(part) && part[i] >= '0' && part[i] <= '9' {
+			i++
+		}
+		if i == 0 {
+			return nil, fmt.Errorf("invalid version '%s'", s)
+		}
+		v, _ := strconv.Atoi(part[:i])
+		res = append(res, v)
+
+		if suffix := part[i:]; suffix != "" {
+			if n != len(parts)-1 {
+				return nil, fmt.Errorf("invalid version '%s'", s)
+			}
+			var err error
+			if pre, err = parsePreRelease(suffix); err != nil {
+				return nil, fmt.Errorf("invalid version '%s': %w", s, err)
+			}
+		}
+	}
+	return append(res, pre...), nil
+}
+
+func parsePreRelease(s string) ([]int, error) {
+	for rank, p := range preReleases {
+		num, ok := strings.CutPrefix(s, p)
+		if !ok {
+			continue
+		}
+		if num == "" {
+			return []int{rank, 0}, nil
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil || n < 0 {
+			break
+		}
+		return []int{rank, n}, nil
+	}
+	return nil, fmt.Errorf("unknown pre-release '%s'", s)
+}
+
+// CompareVersions Compares RouterOS versions, returns -1, 0 or 1.
+// Missing numbers are zeros, 7.15 equals 7.15.0. Pre-releases are before the release,
+// 7.15beta4 < 7.15rc2 < 7.15. An unparsable version is less than any other.
+func CompareVersions(a, b string) int {
+	va, errA := parseVersion(a)
+	vb, errB := parseVersion(b)
+	switch {
+	case errA != nil && errB != nil:
+		return 0
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+
+	// The numbers are compared up to the pre-release of the longer version
+	na, nb := len(va)-2, len(vb)-2
+	for i := 0; i < na || i < nb; i++ {
+		var x, y int
+		if i < na {
+			x = va[i]
+		}
+		if i < nb {
+			y = vb[i]
+		}
+		if x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if x, y := va[na+i], vb[nb+i]; x != y {
+			return cmp.Compare(x, y)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,150 @@
+package mikrotik
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"7.14.2", "7.14.2", 0},
+		{"7.15", "7.15.0", 0},
+		{"7", "7.0.0", 0},
+		{"7.14.2", "7.15", -1},
+		{"7.15", "7.14.2", 1},
+		{"7.9", "7.10", -1},
+		{"6.49.10", "7", -1},
+		{"8.0", "7.99", 1},
+		// Pre-releases
+		{"7.15rc2", "7.15", -1},
+		{"7.15rc2", "7.15rc2", 0},
+		{"7.15rc1", "7.15rc2", -1},
+		{"7.15beta4", "7.15rc1", -1},
+		{"7.15alpha", "7.15beta1", -1},
+		{"7.15rc2", "7.14.3", 1},
+		{"7.15rc2", "7.15.1", -1},
+		{"7.15.1", "7.15rc2", 1},
+		{"7.15rc", "7.15rc0", 0},
+		// Unparsable versions are the lowest
+		{"", "6.0", -1},
+		{"7.15", "abc", 1},
+		{"7.x", "", 0},
+		{"7.15dev", "1", -1},
+		{"7.15rc2.1", "1", -1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// pathsRouter Returns the context with a REST client of a router having only the paths.
+func pathsRouter(t *testing.T, paths ...string) context.Context {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range paths {
+			if r.URL.Path == "/rest"+p {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":400,"message":"Bad Request","detail":"no such command or directory"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := &RestClient{ctx: context.Background(), HostURL: srv.URL, creds: &Credentials{Username: "test"}, Client: srv.Client()}
+	return c.WithContext(context.Background())
+}
+
+func TestRequirementsCheck(t *testing.T) {
+	ctx := pathsRouter(t, "/interface/wifi")
+	info := &SystemInfo{
+		Version:   "7.15rc2",
+		BoardName: "hAP ax^3",
+		Packages:  map[string]string{"routeros": "7.15rc2", "wifi-qcom": "7.15rc2"},
+	}
+
+	tests := []struct {
+		name     string
+		requires Requirements
+		// info System information, the common one by default
+		info   *SystemInfo
+		noInfo bool
+		// err Expected error substring, empty if satisfied
+		err string
+	}{
+		{name: "none"},
+		{name: "major version", requires: Requirements{Version: ">=7"}},
+		{name: "range", requires: Requirements{Version: ">=7.13, <8"}},
+		{name: "pre-release is before the release", requires: Requirements{Version: ">=7.15"}, err: "requires RouterOS version >=7.15, found 7.15rc2"},
+		{name: "pre-release constraint", requires: Requirements{Version: ">=7.15rc1"}},
+		{name: "below the release", requires: Requirements{Version: "<7.15"}},
+		{name: "exact", requires: Requirements{Version: "7.15rc2"}},
+		{name: "not equal", requires: Requirements{Version: "!=7.15rc2"}, err: "requires RouterOS version !=7.15rc2"},
+		{name: "upper bound", requires: Requirements{Version: ">=7.13,<7.14"}, err: "requires RouterOS version <7.14"},
+		{name: "v6", requires: Requirements{Version: ">=7"}, info: &SystemInfo{Version: "6.49.10"}, err: "found 6.49.10"},
+		{name: "packages", requires: Requirements{Packages: []string{"routeros", "wifi-qcom"}}},
+		{name: "missing package", requires: Requirements{Packages: []string{"routeros", "wireless"}}, err: "requires package 'wireless'"},
+		{name: "board", requires: Requirements{Board: `^hAP ax`}},
+		{name: "other board", requires: Requirements{Board: `^CCR`}, err: "requires board matching '^CCR', found 'hAP ax^3'"},
+		{name: "path", requires: Requirements{Paths: []string{"/interface/wifi"}}},
+		{name: "missing path", requires: Requirements{Paths: []string{"/interface/wifi", "/caps-man/interface"}}, err: "requires resource '/caps-man/interface'"},
+		{name: "paths without system information", requires: Requirements{Paths: []string{"/interface/wifi"}}, noInfo: true},
+		{name: "version without system information", requires: Requirements{Version: ">=7"}, noInfo: true, err: "router system information is not available"},
+		{name: "invalid version", requires: Requirements{Version: ">=seven"}, err: "invalid version 'seven'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := info
+			switch {
+			case tt.noInfo:
+				i = nil
+			case tt.info != nil:
+				i = tt.info
+			}
+
+			err := tt.requires.Check(ctx, i)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("unexpected error '%v'", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("got error '%v', want '%s'", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRequirementsValidate(t *testing.T) {
+	tests := []struct {
+		requires Requirements
+		err      string
+	}{
+		{requires: Requirements{Version: ">=7.13,<8", Board: "^(CCR|CRS)"}},
+		{requires: Requirements{Version: "=>7"}, err: "invalid version '>7'"},
+		{requires: Requirements{Version: ">=7.15beta"}},
+		{requires: Requirements{Version: ">=7.15gamma1"}, err: "unknown pre-release 'gamma1'"},
+		{requires: Requirements{Version: ">="}, err: "empty version"},
+		{requires: Requirements{Board: "(CCR"}, err: "board:"},
+	}
+
+	for _, tt := range tests {
+		err := tt.requires.Validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%+v: unexpected error '%v'", tt.requires, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%+v: got error '%v', want '%s'", tt.requires, err, tt.err)
+		}
+	}
+}
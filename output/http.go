@@ -0,0 +1,118 @@
+package output
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const userAgent = "mikrotik-prom-exporter"
+
+// HTTPClientConfig Authentication, TLS and headers of the requests to a push receiver.
+// Secrets are read from files on every request, so rotated ones are picked up.
+//
+//	headers:
+//	  X-Scope-OrgID: branch
+//	basic_auth:
+//	  username: exporter
+//	  password_file: /run/secrets/receiver-password
+//	bearer_token_file: /run/secrets/receiver-token
+//	ca_file: /etc/ssl/receiver-ca.pem
+//	insecure_skip_verify: false
+type HTTPClientConfig struct {
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	BasicAuth *BasicAuth        `yaml:"basic_auth,omitempty"`
+	// BearerTokenFile File with the token sent in the 'Authorization: Bearer' header
+	BearerTokenFile    string `yaml:"bearer_token_file,omitempty"`
+	CAFile             string `yaml:"ca_file,omitempty"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"`
+}
+
+type BasicAuth struct {
+	Username     string `yaml:"username"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+}
+
+// newHTTPClient Creates the client with the TLS settings.
+func (c *HTTPClientConfig) newHTTPClient(timeout time.Duration) (*http.Client, error) {
+	tlsConf, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+func (c *HTTPClientConfig) tlsConfig() (*tls.Config, error) {
+	if c.BasicAuth != nil && c.BearerTokenFile != "" {
+		return nil, fmt.Errorf("basic_auth and bearer_token_file are mutually exclusive")
+	}
+
+	res := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file '%s': %w", c.CAFile, err)
+		}
+		res.RootCAs = x509.NewCertPool()
+		if !res.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file '%s'", c.CAFile)
+		}
+	}
+	return res, nil
+}
+
+// prepare Sets the headers and the credentials of the request.
+func (c *HTTPClientConfig) prepare(req *http.Request) error {
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range c.Headers {
+		req.Header.Set(k, v)
+	}
+
+	auth, err := c.authorization()
+	if err != nil {
+		return err
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return nil
+}
+
+// authorization Returns the Authorization header value, empty if no credentials are set.
+func (c *HTTPClientConfig) authorization() (string, error) {
+	switch {
+	case c.BasicAuth != nil:
+		var password string
+		if c.BasicAuth.PasswordFile != "" {
+			var err error
+			if password, err = readSecret(c.BasicAuth.PasswordFile); err != nil {
+				return "", err
+			}
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(c.BasicAuth.Username+":"+password)), nil
+	case c.BearerTokenFile != "":
+		token, err := readSecret(c.BearerTokenFile)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return "", nil
+}
+
+func readSecret(fileName string) (string, error) {
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file '%s': %w", fileName, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
@@ -0,0 +1,210 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+)
+
+const DefaultInfluxBatchSize = 5000
+
+// InfluxConfig Writes the resource rows of every schema collection cycle in the InfluxDB line protocol
+// to the InfluxDB v2 HTTP API or to a file. The measurement is the schema namespace and subsystem,
+// the tags are the metric labels and the fields are the metric values.
+//
+//	influxdb:
+//	  url: http://influxdb:8086
+//	  org: noc
+//	  bucket: mikrotik
+//	  token_file: /run/secrets/influxdb-token
+//	  batch_size: 5000        # lines per request
+//	  interval: 10s           # how often the lines are flushed, the --interval flag value by default
+//
+//	influxdb:
+//	  file: '-'               # stdout, or a file the lines are appended to
+type InfluxConfig struct {
+	// URL InfluxDB URL, the lines are written to /api/v2/write
+	URL    string `yaml:"url,omitempty"`
+	Org    string `yaml:"org,omitempty"`
+	Bucket string `yaml:"bucket,omitempty"`
+	// TokenFile File with the API token, re-read on every request
+	TokenFile string `yaml:"token_file,omitempty"`
+	// File File the lines are appended to instead of the HTTP API, '-' for stdout
+	File string `yaml:"file,omitempty"`
+	// Precision Timestamp precision: ns, us, ms or s, ns by default
+	Precision string `yaml:"precision,omitempty"`
+	// BatchSize Maximal number of lines per request
+	BatchSize        int `yaml:"batch_size,omitempty"`
+	PushConfig       `yaml:",inline"`
+	HTTPClientConfig `yaml:",inline"`
+}
+
+// InfluxWriter Batches the schema points as line protocol and writes them to InfluxDB or a file.
+// A batch is queued when it's full or on the interval.
+type InfluxWriter struct {
+	*pusher
+	conf     InfluxConfig
+	writeURL string
+	client   *http.Client
+	file     io.Writer
+
+	mu      sync.Mutex
+	pending []byte
+	lines   int
+}
+
+// NewInfluxWriter Creates the writer. The interval is used if the configuration doesn't set one.
+func NewInfluxWriter(conf InfluxConfig, interval time.Duration, constLabels prom.Labels) (*InfluxWriter, error) {
+	if err := conf.setDefaults(interval); err != nil {
+		return nil, fmt.Errorf("influxdb: %w", err)
+	}
+	if conf.BatchSize <= 0 {
+		conf.BatchSize = DefaultInfluxBatchSize
+	}
+	if conf.Precision == "" {
+		conf.Precision = "ns"
+	}
+	if _, ok := precisions[conf.Precision]; !ok {
+		return nil, fmt.Errorf("influxdb precision '%s' must be ns, us, ms or s", conf.Precision)
+	}
+
+	var w = &InfluxWriter{
+		pusher: newPusher("influxdb", "influxdb", conf.PushConfig, constLabels),
+		conf:   conf,
+	}
+	w.pusher.gather = w.gather
+	w.pusher.gatherOnShutdown = true
+
+	switch {
+	case conf.File != "" && conf.URL != "":
+		return nil, fmt.Errorf("influxdb url and file are mutually exclusive")
+	case conf.File == "-":
+		w.file = os.Stdout
+		w.pusher.post = w.writeFile
+	case conf.File != "":
+		f, err := os.OpenFile(conf.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("opening influxdb file: %w", err)
+		}
+		w.file = f
+		w.pusher.post = w.writeFile
+	default:
+		u, err := url.Parse(conf.URL)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("influxdb url '%s' is invalid", conf.URL)
+		}
+		if conf.Bucket == "" {
+			return nil, fmt.Errorf("influxdb bucket is not set")
+		}
+		u = u.JoinPath("/api/v2/write")
+		u.RawQuery = url.Values{"org": {conf.Org}, "bucket": {conf.Bucket}, "precision": {conf.Precision}}.Encode()
+		w.writeURL = u.String()
+
+		if w.client, err = conf.newHTTPClient(conf.Timeout); err != nil {
+			return nil, fmt.Errorf("influxdb: %w", err)
+		}
+		w.pusher.post = w.postHTTP
+	}
+
+	return w, nil
+}
+
+// Run Writes the batches until the context is cancelled.
+func (w *InfluxWriter) Run(ctx context.Context) {
+	dest := w.conf.URL
+	if w.file != nil {
+		dest = w.conf.File
+	}
+	zerolog.Ctx(ctx).Info().Str("destination", dest).Msgf("writing schema rows to InfluxDB every %v", w.conf.Interval)
+
+	w.pusher.Run(ctx)
+
+	if f, ok := w.file.(*os.File); ok && f != os.Stdout {
+		_ = f.Close()
+	}
+}
+
+// WritePoints implements exporter.PointWriter.
+func (w *InfluxWriter) WritePoints(ctx context.Context, points []exporter.Point) {
+	precision := precisions[w.conf.Precision]
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range points {
+		var ok bool
+		if w.pending, ok = appendLine(w.pending, &points[i], precision); !ok {
+			continue
+		}
+		w.lines++
+
+		if w.lines >= w.conf.BatchSize {
+			w.push(ctx, w.pending, w.lines)
+			w.pending, w.lines = nil, 0
+		}
+	}
+}
+
+// gather Returns the pending lines.
+func (w *InfluxWriter) gather(_ context.Context) ([]byte, int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data, lines := w.pending, w.lines
+	w.pending, w.lines = nil, 0
+	return data, lines
+}
+
+// postHTTP Writes the batch with the v2 API. Network errors, 5xx and 429 responses are recoverable.
+func (w *InfluxWriter) postHTTP(ctx context.Context, b batch) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.writeURL, bytes.NewReader(b.data))
+	if err != nil {
+		return err
+	}
+	if err := w.conf.prepare(req); err != nil {
+		return &recoverableError{err: err}
+	}
+	if w.conf.TokenFile != "" {
+		token, err := readSecret(w.conf.TokenFile)
+		if err != nil {
+			return &recoverableError{err: err}
+		}
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return &recoverableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	err = fmt.Errorf("POST '%s' returned response code: %v, message: '%s'", w.conf.URL, resp.StatusCode, bytes.TrimSpace(body))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return &recoverableError{err: err, retryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return err
+}
+
+// writeFile Appends the batch to the file. Write errors, e.g. a full disk, are recoverable.
+func (w *InfluxWriter) writeFile(_ context.Context, b batch) error {
+	if _, err := w.file.Write(b.data); err != nil {
+		return &recoverableError{err: fmt.Errorf("writing '%s': %w", w.conf.File, err)}
+	}
+	return nil
+}
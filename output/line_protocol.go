@@ -0,0 +1,71 @@
+package output
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// Line protocol timestamp precisions.
+var precisions = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// appendLine Appends the point in the InfluxDB line protocol, tags and fields sorted by key.
+// Empty tags and NaN or infinite values, which the line protocol can't represent, are skipped.
+// Returns false if the point has no fields left.
+func appendLine(b []byte, p *exporter.Point, precision time.Duration) ([]byte, bool) {
+	var fields = make([]string, 0, len(p.Fields))
+	for k, v := range p.Fields {
+		if !math.IsNaN(v) && !math.IsInf(v, 0) {
+			fields = append(fields, k)
+		}
+	}
+	if len(fields) == 0 {
+		return b, false
+	}
+	sort.Strings(fields)
+
+	var tags = make([]string, 0, len(p.Tags))
+	for k, v := range p.Tags {
+		if v != "" {
+			tags = append(tags, k)
+		}
+	}
+	sort.Strings(tags)
+
+	b = append(b, measurementEscaper.Replace(p.Measurement)...)
+	for _, k := range tags {
+		b = append(b, ',')
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, keyEscaper.Replace(p.Tags[k])...)
+	}
+	for i, k := range fields {
+		if i == 0 {
+			b = append(b, ' ')
+		} else {
+			b = append(b, ',')
+		}
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = strconv.AppendFloat(b, p.Fields[k], 'g', -1, 64)
+	}
+	b = append(b, ' ')
+	b = strconv.AppendInt(b, p.Time.UnixNano()/int64(precision), 10)
+	b = append(b, '\n')
+
+	return b, true
+}
@@ -0,0 +1,108 @@
+package output
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+)
+
+func TestAppendLine(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+
+	tests := []struct {
+		name  string
+		point exporter.Point
+		want  string
+	}{
+		{
+			name: "sorted tags and fields",
+			point: exporter.Point{
+				Measurement: "mikrotik_interface",
+				Tags:        map[string]string{"name": "ether1", "comment": "uplink"},
+				Fields:      map[string]float64{"tx_byte": 2, "rx_byte": 1.5},
+				Time:        ts,
+			},
+			want: "mikrotik_interface,comment=uplink,name=ether1 rx_byte=1.5,tx_byte=2 1700000000123456789\n",
+		},
+		{
+			name: "measurement escaping",
+			point: exporter.Point{
+				Measurement: "a,b c=d\ne",
+				Fields:      map[string]float64{"v": 1},
+				Time:        ts,
+			},
+			want: `a\,b\ c=d\ne v=1 1700000000123456789` + "\n",
+		},
+		{
+			name: "tag and field escaping",
+			point: exporter.Point{
+				Measurement: "m",
+				Tags:        map[string]string{"a b": "x,y=z", "c": "line\nbreak"},
+				Fields:      map[string]float64{"f=1,2 3": 1},
+				Time:        ts,
+			},
+			want: `m,a\ b=x\,y\=z,c=line\nbreak f\=1\,2\ 3=1 1700000000123456789` + "\n",
+		},
+		{
+			name: "empty tags",
+			point: exporter.Point{
+				Measurement: "m",
+				Tags:        map[string]string{"comment": "", "name": "ether1"},
+				Fields:      map[string]float64{"v": -3e-7},
+				Time:        ts,
+			},
+			want: "m,name=ether1 v=-3e-07 1700000000123456789\n",
+		},
+		{
+			name: "nan and inf fields",
+			point: exporter.Point{
+				Measurement: "m",
+				Fields:      map[string]float64{"a": math.NaN(), "b": 4, "c": math.Inf(1), "d": math.Inf(-1)},
+				Time:        ts,
+			},
+			want: "m b=4 1700000000123456789\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := appendLine([]byte("prev\n"), &tt.point, time.Nanosecond)
+			if !ok {
+				t.Fatal("the point is skipped")
+			}
+			if want := "prev\n" + tt.want; string(got) != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAppendLineNoFields(t *testing.T) {
+	for _, fields := range []map[string]float64{nil, {"a": math.NaN(), "b": math.Inf(1)}} {
+		p := exporter.Point{Measurement: "m", Tags: map[string]string{"name": "ether1"}, Fields: fields, Time: time.Now()}
+		got, ok := appendLine([]byte("prev\n"), &p, time.Nanosecond)
+		if ok || string(got) != "prev\n" {
+			t.Errorf("fields %v: got %q, %v", fields, got, ok)
+		}
+	}
+}
+
+func TestAppendLinePrecision(t *testing.T) {
+	ts := time.Unix(1700000000, 123456789)
+	p := exporter.Point{Measurement: "m", Fields: map[string]float64{"v": 1}, Time: ts}
+
+	tests := map[string]string{
+		"ns": "m v=1 1700000000123456789\n",
+		"us": "m v=1 1700000000123456\n",
+		"ms": "m v=1 1700000000123\n",
+		"s":  "m v=1 1700000000\n",
+	}
+	for precision, want := range tests {
+		got, _ := appendLine(nil, &p, precisions[precision])
+		if string(got) != want {
+			t.Errorf("precision %s: got %q, want %q", precision, got, want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP protocols.
+const (
+	OTLPProtocolHTTP = "http/protobuf"
+	OTLPProtocolGRPC = "grpc"
+)
+
+// otlpScope Instrumentation scope of the exported metrics.
+const otlpScope = "github.com/vaerh/mikrotik-prom-exporter"
+
+// OTLPConfig Pushes the metrics to an OpenTelemetry collector. The router identity is sent as
+// resource attributes instead of the routerboard_* labels.
+//
+//	otlp:
+//	  protocol: grpc          # or http/protobuf, the default
+//	  endpoint: otel-collector:4317
+//	  insecure: true          # gRPC without TLS
+//	  interval: 30s           # the --interval flag value by default
+//	  resource_attributes:
+//	    deployment.environment: production
+type OTLPConfig struct {
+	Protocol string `yaml:"protocol,omitempty"`
+	// Endpoint host:port for gRPC, the URL for HTTP. /v1/metrics is added to the URL without a path.
+	Endpoint string `yaml:"endpoint"`
+	// Insecure Use gRPC without TLS
+	Insecure   bool `yaml:"insecure,omitempty"`
+	PushConfig `yaml:",inline"`
+	// ResourceAttributes Attributes added to the router resource, they override the router identity
+	ResourceAttributes map[string]string `yaml:"resource_attributes,omitempty"`
+	HTTPClientConfig   `yaml:",inline"`
+}
+
+// OTLPExporter Gathers the metrics on the interval and pushes them to the OpenTelemetry collector.
+// Failed requests are retried with exponential backoff if the OTLP specification marks them
+// as retryable, other failures drop the batch.
+type OTLPExporter struct {
+	*pusher
+	conf       OTLPConfig
+	gatherer   prom.Gatherer
+	resource   func() map[string]string
+	dropLabels map[string]bool
+	starts     *seriesStarts
+
+	httpClient *http.Client
+	grpcConn   *grpc.ClientConn
+	grpcClient collectorpb.MetricsServiceClient
+}
+
+// NewOTLPExporter Creates the exporter of the metrics gathered from the gatherer. The resource function
+// returns the router resource attributes on every push. The dropped labels are removed from the data
+// points, they are the router labels the resource replaces.
+func NewOTLPExporter(conf OTLPConfig, gatherer prom.Gatherer, interval time.Duration,
+	resource func() map[string]string, dropLabels []string, constLabels prom.Labels) (*OTLPExporter, error) {
+	if err := conf.setDefaults(interval); err != nil {
+		return nil, fmt.Errorf("otlp: %w", err)
+	}
+
+	var e = &OTLPExporter{
+		pusher:     newPusher("otlp", "otlp", conf.PushConfig, constLabels),
+		gatherer:   gatherer,
+		resource:   resource,
+		dropLabels: make(map[string]bool, len(dropLabels)),
+		starts:     newSeriesStarts(uint64(time.Now().UnixNano())),
+	}
+	for _, l := range dropLabels {
+		e.dropLabels[l] = true
+	}
+
+	switch conf.Protocol {
+	case "", OTLPProtocolHTTP:
+		conf.Protocol = OTLPProtocolHTTP
+		u, err := url.Parse(conf.Endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("otlp endpoint '%s' must be a URL", conf.Endpoint)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/metrics"
+		}
+		conf.Endpoint = u.String()
+
+		if e.httpClient, err = conf.newHTTPClient(conf.Timeout); err != nil {
+			return nil, fmt.Errorf("otlp: %w", err)
+		}
+		e.pusher.post = e.postHTTP
+	case OTLPProtocolGRPC:
+		if conf.Endpoint == "" {
+			return nil, fmt.Errorf("otlp endpoint is not set")
+		}
+
+		var creds = insecure.NewCredentials()
+		if !conf.Insecure {
+			tlsConf, err := conf.tlsConfig()
+			if err != nil {
+				return nil, fmt.Errorf("otlp: %w", err)
+			}
+			creds = credentials.NewTLS(tlsConf)
+		}
+
+		conn, err := grpc.NewClient(conf.Endpoint, grpc.WithTransportCredentials(creds), grpc.WithUserAgent(userAgent))
+		if err != nil {
+			return nil, fmt.Errorf("otlp: %w", err)
+		}
+		e.grpcConn, e.grpcClient = conn, collectorpb.NewMetricsServiceClient(conn)
+		e.pusher.post = e.postGRPC
+	default:
+		return nil, fmt.Errorf("otlp protocol '%s' must be '%s' or '%s'", conf.Protocol, OTLPProtocolHTTP, OTLPProtocolGRPC)
+	}
+
+	e.conf = conf
+	e.pusher.gather = e.gather
+
+	return e, nil
+}
+
+// Run Gathers and sends the metrics until the context is cancelled.
+func (e *OTLPExporter) Run(ctx context.Context) {
+	zerolog.Ctx(ctx).Info().Str("endpoint", e.conf.Endpoint).Str("protocol", e.conf.Protocol).
+		Msgf("pushing metrics with OTLP every %v", e.conf.Interval)
+	e.pusher.Run(ctx)
+
+	if e.grpcConn != nil {
+		_ = e.grpcConn.Close()
+	}
+}
+
+// gather Returns the gathered metrics as an encoded ExportMetricsServiceRequest.
+func (e *OTLPExporter) gather(ctx context.Context) ([]byte, int) {
+	logger := zerolog.Ctx(ctx)
+
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		// The metrics that could be gathered are still sent
+		logger.Warn().Err(err).Msg("otlp: gathering metrics")
+	}
+
+	metrics, points := otlpMetrics(families, e.dropLabels, e.starts, uint64(time.Now().UnixNano()))
+	if points == 0 {
+		return nil, 0
+	}
+
+	var attrs = e.resource()
+	for k, v := range e.conf.ResourceAttributes {
+		attrs[k] = v
+	}
+
+	data, err := proto.Marshal(&collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{Attributes: stringAttributes(attrs)},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: otlpScope},
+				Metrics: metrics,
+			}},
+		}},
+	})
+	if err != nil {
+		logger.Err(err).Msg("otlp: encoding metrics")
+		return nil, 0
+	}
+
+	return data, points
+}
+
+// postHTTP Sends the batch with OTLP/HTTP. Network errors, 429, 502, 503 and 504 responses are recoverable.
+func (e *OTLPExporter) postHTTP(ctx context.Context, b batch) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.conf.Endpoint, bytes.NewReader(b.data))
+	if err != nil {
+		return err
+	}
+	if err := e.conf.prepare(req); err != nil {
+		return &recoverableError{err: err}
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return &recoverableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode/100 == 2 {
+		var res collectorpb.ExportMetricsServiceResponse
+		if err := proto.Unmarshal(body, &res); err == nil {
+			e.partialSuccess(ctx, &res)
+		}
+		return nil
+	}
+
+	err = fmt.Errorf("POST '%s' returned response code: %v, message: '%s'", e.conf.Endpoint, resp.StatusCode, bytes.TrimSpace(body))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return &recoverableError{err: err, retryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return err
+}
+
+// postGRPC Sends the batch with OTLP/gRPC. The codes the OTLP specification marks as retryable are recoverable.
+func (e *OTLPExporter) postGRPC(ctx context.Context, b batch) error {
+	var req collectorpb.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(b.data, &req); err != nil {
+		return err
+	}
+
+	auth, err := e.conf.authorization()
+	if err != nil {
+		return &recoverableError{err: err}
+	}
+	var md = metadata.New(e.conf.Headers)
+	if auth != "" {
+		md.Set("authorization", auth)
+	}
+
+	ctx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, md), e.conf.Timeout)
+	defer cancel()
+
+	res, err := e.grpcClient.Export(ctx, &req)
+	if err != nil {
+		err = fmt.Errorf("export to '%s': %w", e.conf.Endpoint, err)
+		switch status.Code(err) {
+		case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+			codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+			return &recoverableError{err: err}
+		}
+		return err
+	}
+
+	e.partialSuccess(ctx, res)
+	return nil
+}
+
+// partialSuccess Logs and counts the data points the collector rejected.
+func (e *OTLPExporter) partialSuccess(ctx context.Context, res *collectorpb.ExportMetricsServiceResponse) {
+	ps := res.GetPartialSuccess()
+	if ps.GetRejectedDataPoints() == 0 && ps.GetErrorMessage() == "" {
+		return
+	}
+
+	e.samplesDropped.Add(float64(ps.GetRejectedDataPoints()))
+	zerolog.Ctx(ctx).Warn().Msgf("otlp: collector rejected %d data points: %s", ps.GetRejectedDataPoints(), ps.GetErrorMessage())
+}
@@ -0,0 +1,214 @@
+package output
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// seriesStarts Start times of the cumulative series. A series starts when it's first gathered,
+// or again when its value goes down or it disappears and comes back, e.g. after the schema metrics
+// were reset on deactivation. The new start is the time of the previous gather, the reset happened after it.
+type seriesStarts struct {
+	// last Time of the previous gather, the process start before the first one
+	last   uint64
+	series map[string]seriesStart
+	next   map[string]seriesStart
+}
+
+type seriesStart struct {
+	start uint64
+	value float64
+}
+
+func newSeriesStarts(startNs uint64) *seriesStarts {
+	return &seriesStarts{last: startNs, series: make(map[string]seriesStart)}
+}
+
+// get Returns the start time of the series with the gathered value.
+func (s *seriesStarts) get(name string, m *dto.Metric, value float64) uint64 {
+	var key strings.Builder
+	key.WriteString(name)
+	for _, l := range m.GetLabel() {
+		key.WriteString("\xff" + l.GetName() + "\xff" + l.GetValue())
+	}
+
+	k := key.String()
+	prev, ok := s.series[k]
+	if !ok || value < prev.value {
+		prev.start = s.last
+	}
+	if s.next == nil {
+		s.next = make(map[string]seriesStart, len(s.series))
+	}
+	s.next[k] = seriesStart{start: prev.start, value: value}
+	return prev.start
+}
+
+// done Ends the gather, the series not gathered are forgotten.
+func (s *seriesStarts) done(nowNs uint64) {
+	s.series, s.next, s.last = s.next, nil, nowNs
+	if s.series == nil {
+		s.series = make(map[string]seriesStart)
+	}
+}
+
+// otlpMetrics Converts the metric families: counters to monotonic cumulative sums, gauges and
+// untyped metrics to gauges, histograms and summaries to their OTLP counterparts.
+// The dropped labels are removed from the data point attributes. Returns the metrics and
+// the number of data points.
+func otlpMetrics(families []*dto.MetricFamily, dropLabels map[string]bool, starts *seriesStarts, nowNs uint64) ([]*metricspb.Metric, int) {
+	var res = make([]*metricspb.Metric, 0, len(families))
+	var points int
+	defer starts.done(nowNs)
+
+	for _, mf := range families {
+		var metric = &metricspb.Metric{Name: mf.GetName(), Description: mf.GetHelp()}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			sum := &metricspb.Sum{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, IsMonotonic: true}
+			for _, m := range mf.GetMetric() {
+				value := m.GetCounter().GetValue()
+				startNs := starts.get(mf.GetName(), m, value)
+				sum.DataPoints = append(sum.DataPoints, numberPoint(m, value, dropLabels, startNs, nowNs))
+			}
+			metric.Data = &metricspb.Metric_Sum{Sum: sum}
+			points += len(sum.DataPoints)
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := &metricspb.Gauge{}
+			for _, m := range mf.GetMetric() {
+				value := m.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = m.GetUntyped().GetValue()
+				}
+				// Gauges have no start time
+				gauge.DataPoints = append(gauge.DataPoints, numberPoint(m, value, dropLabels, 0, nowNs))
+			}
+			metric.Data = &metricspb.Metric_Gauge{Gauge: gauge}
+			points += len(gauge.DataPoints)
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			hist := &metricspb.Histogram{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+			for _, m := range mf.GetMetric() {
+				startNs := starts.get(mf.GetName(), m, float64(m.GetHistogram().GetSampleCount()))
+				hist.DataPoints = append(hist.DataPoints, histogramPoint(m, dropLabels, startNs, nowNs))
+			}
+			metric.Data = &metricspb.Metric_Histogram{Histogram: hist}
+			points += len(hist.DataPoints)
+		case dto.MetricType_SUMMARY:
+			summary := &metricspb.Summary{}
+			for _, m := range mf.GetMetric() {
+				startNs := starts.get(mf.GetName(), m, float64(m.GetSummary().GetSampleCount()))
+				summary.DataPoints = append(summary.DataPoints, summaryPoint(m, dropLabels, startNs, nowNs))
+			}
+			metric.Data = &metricspb.Metric_Summary{Summary: summary}
+			points += len(summary.DataPoints)
+		default:
+			continue
+		}
+
+		res = append(res, metric)
+	}
+
+	return res, points
+}
+
+func numberPoint(m *dto.Metric, value float64, dropLabels map[string]bool, startNs, nowNs uint64) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:        otlpAttributes(m.GetLabel(), dropLabels),
+		StartTimeUnixNano: startNs,
+		TimeUnixNano:      pointTime(m, nowNs),
+		Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// histogramPoint Converts the cumulative Prometheus buckets to the OTLP bucket counts.
+func histogramPoint(m *dto.Metric, dropLabels map[string]bool, startNs, nowNs uint64) *metricspb.HistogramDataPoint {
+	h := m.GetHistogram()
+	sum := h.GetSampleSum()
+
+	var res = &metricspb.HistogramDataPoint{
+		Attributes:        otlpAttributes(m.GetLabel(), dropLabels),
+		StartTimeUnixNano: startNs,
+		TimeUnixNano:      pointTime(m, nowNs),
+		Count:             h.GetSampleCount(),
+		Sum:               &sum,
+	}
+
+	var prev uint64
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), +1) {
+			break
+		}
+		res.ExplicitBounds = append(res.ExplicitBounds, b.GetUpperBound())
+		res.BucketCounts = append(res.BucketCounts, b.GetCumulativeCount()-prev)
+		prev = b.GetCumulativeCount()
+	}
+	// The +Inf bucket
+	res.BucketCounts = append(res.BucketCounts, h.GetSampleCount()-prev)
+
+	return res
+}
+
+func summaryPoint(m *dto.Metric, dropLabels map[string]bool, startNs, nowNs uint64) *metricspb.SummaryDataPoint {
+	s := m.GetSummary()
+
+	var res = &metricspb.SummaryDataPoint{
+		Attributes:        otlpAttributes(m.GetLabel(), dropLabels),
+		StartTimeUnixNano: startNs,
+		TimeUnixNano:      pointTime(m, nowNs),
+		Count:             s.GetSampleCount(),
+		Sum:               s.GetSampleSum(),
+	}
+	for _, q := range s.GetQuantile() {
+		res.QuantileValues = append(res.QuantileValues, &metricspb.SummaryDataPoint_ValueAtQuantile{
+			Quantile: q.GetQuantile(),
+			Value:    q.GetValue(),
+		})
+	}
+
+	return res
+}
+
+func pointTime(m *dto.Metric, nowNs uint64) uint64 {
+	if m.TimestampMs != nil {
+		return uint64(m.GetTimestampMs()) * 1e6
+	}
+	return nowNs
+}
+
+func otlpAttributes(labels []*dto.LabelPair, dropLabels map[string]bool) []*commonpb.KeyValue {
+	var res = make([]*commonpb.KeyValue, 0, len(labels))
+	for _, l := range labels {
+		if dropLabels[l.GetName()] {
+			continue
+		}
+		res = append(res, stringAttribute(l.GetName(), l.GetValue()))
+	}
+	return res
+}
+
+// stringAttributes Converts the map to attributes sorted by key, empty values are skipped.
+func stringAttributes(attrs map[string]string) []*commonpb.KeyValue {
+	var keys = make([]string, 0, len(attrs))
+	for k, v := range attrs {
+		if v != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var res = make([]*commonpb.KeyValue, len(keys))
+	for i, k := range keys {
+		res[i] = stringAttribute(k, attrs[k])
+	}
+	return res
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
@@ -0,0 +1,460 @@
+package output
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/vaerh/mikrotik-prom-exporter/exporter"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+func otlpTestRegistry() *prom.Registry {
+	reg := prom.NewRegistry()
+
+	c := prom.NewCounterVec(prom.CounterOpts{Name: "test_rx_bytes_total", Help: "Received bytes"}, []string{"name", "routerboard_id"})
+	c.WithLabelValues("ether1", "core").Add(100)
+	g := prom.NewGauge(prom.GaugeOpts{Name: "test_cpu_load", Help: "CPU load"})
+	g.Set(12)
+	h := prom.NewHistogram(prom.HistogramOpts{Name: "test_duration_seconds", Help: "test", Buckets: []float64{0.5, 1}})
+	h.Observe(0.2)
+	h.Observe(0.7)
+	h.Observe(3)
+	reg.MustRegister(c, g, h)
+
+	return reg
+}
+
+// otlpTestResource Returns the resource function of the router with the test identity.
+func otlpTestResource() func() map[string]string {
+	vars := exporter.NewGlobalVars(map[string]string{
+		"HOSTURL":       "10.0.0.1",
+		"ROUTER_ID":     "core",
+		"BOARD_NAME":    "CCR2004",
+		"SERIAL_NUMBER": "HF1234",
+		"VERSION":       "7.15.2",
+	}, nil)
+	return func() map[string]string {
+		return exporter.RouterResourceAttributes(vars)
+	}
+}
+
+// runOTLP Runs the exporter gathering once, stopping it at the end of the test.
+func runOTLP(t *testing.T, conf OTLPConfig) *OTLPExporter {
+	t.Helper()
+
+	conf.Interval = time.Hour
+	e, err := NewOTLPExporter(conf, otlpTestRegistry(), time.Minute, otlpTestResource(), []string{"routerboard_id"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return e
+}
+
+func attributeMap(attrs []*commonpb.KeyValue) map[string]string {
+	var res = make(map[string]string, len(attrs))
+	for _, a := range attrs {
+		res[a.GetKey()] = a.GetValue().GetStringValue()
+	}
+	return res
+}
+
+// checkOTLPRequest Checks the resource, the scope and the conversion of the test registry metrics.
+func checkOTLPRequest(t *testing.T, req *collectorpb.ExportMetricsServiceRequest) {
+	t.Helper()
+
+	if len(req.GetResourceMetrics()) != 1 {
+		t.Fatalf("got %d resources, want 1", len(req.GetResourceMetrics()))
+	}
+	rm := req.GetResourceMetrics()[0]
+
+	attrs := attributeMap(rm.GetResource().GetAttributes())
+	want := map[string]string{
+		"service.name":           "mikrotik-prom-exporter",
+		"service.instance.id":    "10.0.0.1",
+		"host.name":              "core",
+		"host.id":                "HF1234",
+		"device.model.name":      "CCR2004",
+		"os.name":                "RouterOS",
+		"os.version":             "7.15.2",
+		"deployment.environment": "test",
+	}
+	for k, v := range want {
+		if attrs[k] != v {
+			t.Errorf("resource attribute %s is '%s', want '%s'", k, attrs[k], v)
+		}
+	}
+	// Empty attributes are not sent
+	if v, ok := attrs["mikrotik.alias"]; ok {
+		t.Errorf("empty attribute mikrotik.alias is sent as '%s'", v)
+	}
+
+	if len(rm.GetScopeMetrics()) != 1 || rm.GetScopeMetrics()[0].GetScope().GetName() != otlpScope {
+		t.Fatalf("unexpected scope metrics %v", rm.GetScopeMetrics())
+	}
+
+	var metrics = make(map[string]*metricspb.Metric)
+	for _, m := range rm.GetScopeMetrics()[0].GetMetrics() {
+		metrics[m.GetName()] = m
+	}
+	if len(metrics) != 3 {
+		t.Fatalf("got %d metrics, want 3", len(metrics))
+	}
+
+	sum := metrics["test_rx_bytes_total"].GetSum()
+	switch {
+	case sum == nil:
+		t.Fatal("the counter is not a sum")
+	case !sum.GetIsMonotonic() || sum.GetAggregationTemporality() != metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE:
+		t.Errorf("the counter is not a monotonic cumulative sum: %v", sum)
+	case len(sum.GetDataPoints()) != 1:
+		t.Fatalf("the sum has %d data points, want 1", len(sum.GetDataPoints()))
+	}
+	dp := sum.GetDataPoints()[0]
+	if dp.GetAsDouble() != 100 {
+		t.Errorf("sum value is %v, want 100", dp.GetAsDouble())
+	}
+	if dp.GetStartTimeUnixNano() == 0 || dp.GetStartTimeUnixNano() > dp.GetTimeUnixNano() {
+		t.Errorf("sum start time %d, time %d", dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano())
+	}
+	// The router labels are replaced by the resource
+	if got := attributeMap(dp.GetAttributes()); len(got) != 1 || got["name"] != "ether1" {
+		t.Errorf("sum attributes are %v, want only name", got)
+	}
+
+	gauge := metrics["test_cpu_load"].GetGauge()
+	if gauge == nil || len(gauge.GetDataPoints()) != 1 {
+		t.Fatalf("unexpected gauge %v", metrics["test_cpu_load"])
+	}
+	if dp := gauge.GetDataPoints()[0]; dp.GetAsDouble() != 12 || dp.GetStartTimeUnixNano() != 0 {
+		t.Errorf("gauge value %v, start time %d, want 12 without start time", dp.GetAsDouble(), dp.GetStartTimeUnixNano())
+	}
+	if metrics["test_cpu_load"].GetDescription() != "CPU load" {
+		t.Errorf("gauge description is '%s'", metrics["test_cpu_load"].GetDescription())
+	}
+
+	hist := metrics["test_duration_seconds"].GetHistogram()
+	if hist == nil || len(hist.GetDataPoints()) != 1 {
+		t.Fatalf("unexpected histogram %v", metrics["test_duration_seconds"])
+	}
+	hp := hist.GetDataPoints()[0]
+	if hp.GetCount() != 3 || hp.GetSum() != 3.9 {
+		t.Errorf("histogram count %d, sum %v, want 3 and 3.9", hp.GetCount(), hp.GetSum())
+	}
+	// The cumulative buckets become per-bucket counts with the +Inf bucket last
+	if bounds, counts := hp.GetExplicitBounds(), hp.GetBucketCounts(); len(bounds) != 2 || bounds[0] != 0.5 || bounds[1] != 1 ||
+		len(counts) != 3 || counts[0] != 1 || counts[1] != 1 || counts[2] != 1 {
+		t.Errorf("histogram bounds %v, counts %v, want [0.5 1] and [1 1 1]", bounds, counts)
+	}
+}
+
+func TestOTLPHTTP(t *testing.T) {
+	rcv, srv := newTestReceiver()
+	defer srv.Close()
+
+	e := runOTLP(t, OTLPConfig{
+		Endpoint:           srv.URL,
+		ResourceAttributes: map[string]string{"deployment.environment": "test"},
+	})
+	rcv.wait(t, 1)
+
+	var req collectorpb.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(rcv.bodies[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	checkOTLPRequest(t, &req)
+
+	time.Sleep(50 * time.Millisecond)
+	if got := counterValue(e.samplesSent); got != 3 {
+		t.Errorf("samples_sent_total is %v, want 3", got)
+	}
+}
+
+func TestOTLPHTTPEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"http://collector:4318":            "http://collector:4318/v1/metrics",
+		"http://collector:4318/":           "http://collector:4318/v1/metrics",
+		"https://collector/otlp/v1/metric": "https://collector/otlp/v1/metric",
+	}
+	for endpoint, want := range tests {
+		e, err := NewOTLPExporter(OTLPConfig{Endpoint: endpoint}, prom.NewRegistry(), time.Minute, otlpTestResource(), nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.conf.Endpoint != want {
+			t.Errorf("endpoint '%s' is '%s', want '%s'", endpoint, e.conf.Endpoint, want)
+		}
+	}
+
+	for _, conf := range []OTLPConfig{{Endpoint: "collector:4318"}, {Protocol: "udp", Endpoint: "http://collector"}, {Protocol: OTLPProtocolGRPC}} {
+		if _, err := NewOTLPExporter(conf, prom.NewRegistry(), time.Minute, otlpTestResource(), nil, nil); err == nil {
+			t.Errorf("%+v: expected an error", conf)
+		}
+	}
+}
+
+func TestOTLPHTTPRetry(t *testing.T) {
+	tests := []struct {
+		code    int
+		retried bool
+	}{
+		{code: http.StatusServiceUnavailable, retried: true},
+		{code: http.StatusTooManyRequests, retried: true},
+		{code: http.StatusBadRequest, retried: false},
+		{code: http.StatusInternalServerError, retried: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.code), func(t *testing.T) {
+			rcv, srv := newTestReceiver(tt.code)
+			defer srv.Close()
+
+			e := runOTLP(t, OTLPConfig{
+				Endpoint:   srv.URL,
+				PushConfig: PushConfig{MinBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond},
+			})
+			rcv.wait(t, 1)
+
+			select {
+			case <-rcv.received:
+				if !tt.retried {
+					t.Fatal("the rejected batch was retried")
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.retried {
+					t.Fatal("the batch was not retried")
+				}
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			sent, dropped := 0.0, 3.0
+			if tt.retried {
+				sent, dropped = 3, 0
+			}
+			if got := counterValue(e.samplesSent); got != sent {
+				t.Errorf("samples_sent_total is %v, want %v", got, sent)
+			}
+			if got := counterValue(e.samplesDropped); got != dropped {
+				t.Errorf("samples_dropped_total is %v, want %v", got, dropped)
+			}
+		})
+	}
+}
+
+// testCollector OTLP/gRPC metrics service answering with the errors in turn, then accepting.
+type testCollector struct {
+	collectorpb.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	errs     []error
+	rejected int64
+	requests []*collectorpb.ExportMetricsServiceRequest
+	md       []metadata.MD
+	received chan struct{}
+}
+
+func (c *testCollector) Export(ctx context.Context, req *collectorpb.ExportMetricsServiceRequest) (*collectorpb.ExportMetricsServiceResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	c.mu.Lock()
+	c.requests = append(c.requests, req)
+	c.md = append(c.md, md)
+	var err error
+	if len(c.errs) > 0 {
+		err, c.errs = c.errs[0], c.errs[1:]
+	}
+	c.mu.Unlock()
+
+	defer func() { c.received <- struct{}{} }()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &collectorpb.ExportMetricsServiceResponse{}
+	if c.rejected > 0 {
+		res.PartialSuccess = &collectorpb.ExportMetricsPartialSuccess{RejectedDataPoints: c.rejected, ErrorMessage: "invalid name"}
+	}
+	return res, nil
+}
+
+func (c *testCollector) wait(t *testing.T, requests int) {
+	t.Helper()
+	for i := 0; i < requests; i++ {
+		select {
+		case <-c.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d requests, want %d", i, requests)
+		}
+	}
+}
+
+func newTestCollector(t *testing.T, errs ...error) (*testCollector, string) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &testCollector{errs: errs, received: make(chan struct{}, 16)}
+	srv := grpc.NewServer()
+	collectorpb.RegisterMetricsServiceServer(srv, c)
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(srv.Stop)
+
+	return c, l.Addr().String()
+}
+
+func TestOTLPGRPC(t *testing.T) {
+	col, addr := newTestCollector(t)
+
+	token := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(token, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	runOTLP(t, OTLPConfig{
+		Protocol:           OTLPProtocolGRPC,
+		Endpoint:           addr,
+		Insecure:           true,
+		ResourceAttributes: map[string]string{"deployment.environment": "test"},
+		HTTPClientConfig: HTTPClientConfig{
+			Headers:         map[string]string{"X-Scope-OrgID": "branch"},
+			BearerTokenFile: token,
+		},
+	})
+	col.wait(t, 1)
+
+	checkOTLPRequest(t, col.requests[0])
+
+	md := col.md[0]
+	if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer secret" {
+		t.Errorf("authorization is %v, want 'Bearer secret'", got)
+	}
+	if got := md.Get("x-scope-orgid"); len(got) != 1 || got[0] != "branch" {
+		t.Errorf("x-scope-orgid is %v, want 'branch'", got)
+	}
+}
+
+func TestOTLPGRPCRetry(t *testing.T) {
+	tests := []struct {
+		code    codes.Code
+		retried bool
+	}{
+		{code: codes.Unavailable, retried: true},
+		{code: codes.ResourceExhausted, retried: true},
+		{code: codes.InvalidArgument, retried: false},
+		{code: codes.Unauthenticated, retried: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			col, addr := newTestCollector(t, status.Error(tt.code, "test"))
+
+			e := runOTLP(t, OTLPConfig{
+				Protocol:   OTLPProtocolGRPC,
+				Endpoint:   addr,
+				Insecure:   true,
+				PushConfig: PushConfig{MinBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond},
+			})
+			col.wait(t, 1)
+
+			select {
+			case <-col.received:
+				if !tt.retried {
+					t.Fatal("the rejected batch was retried")
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.retried {
+					t.Fatal("the batch was not retried")
+				}
+			}
+
+			time.Sleep(50 * time.Millisecond)
+			if got := counterValue(e.failedRequests); got != 1 {
+				t.Errorf("failed_requests_total is %v, want 1", got)
+			}
+			if got, want := counterValue(e.samplesDropped), map[bool]float64{true: 0, false: 3}[tt.retried]; got != want {
+				t.Errorf("samples_dropped_total is %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestOTLPPartialSuccess(t *testing.T) {
+	col, addr := newTestCollector(t)
+	col.rejected = 2
+
+	e := runOTLP(t, OTLPConfig{Protocol: OTLPProtocolGRPC, Endpoint: addr, Insecure: true})
+	col.wait(t, 1)
+
+	time.Sleep(50 * time.Millisecond)
+	if got := counterValue(e.samplesDropped); got != 2 {
+		t.Errorf("samples_dropped_total is %v, want 2", got)
+	}
+}
+
+func TestOTLPStartTime(t *testing.T) {
+	counter := func(value float64) []*dto.MetricFamily {
+		name, label, typ := "test_total", "name", dto.MetricType_COUNTER
+		return []*dto.MetricFamily{{
+			Name: &name, Type: &typ,
+			Metric: []*dto.Metric{{
+				Label:   []*dto.LabelPair{{Name: &label, Value: &name}},
+				Counter: &dto.Counter{Value: &value},
+			}},
+		}}
+	}
+
+	starts := newSeriesStarts(1)
+	gathers := []struct {
+		families []*dto.MetricFamily
+		now      uint64
+		start    uint64
+	}{
+		{families: counter(10), now: 10, start: 1},
+		{families: counter(15), now: 20, start: 1},
+		// The counter was reset after the previous gather
+		{families: counter(3), now: 30, start: 20},
+		{families: counter(8), now: 40, start: 20},
+		// The series were reset and are not collected yet
+		{families: nil, now: 50},
+		// The series is back, its value is higher than before the reset
+		{families: counter(20), now: 60, start: 50},
+	}
+
+	for i, g := range gathers {
+		metrics, _ := otlpMetrics(g.families, nil, starts, g.now)
+		if len(metrics) == 0 {
+			continue
+		}
+		if got := metrics[0].GetSum().GetDataPoints()[0].GetStartTimeUnixNano(); got != g.start {
+			t.Errorf("gather %d: start time is %d, want %d", i, got, g.start)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package output
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the remote write protobuf messages,
+// https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+const (
+	writeRequestTimeseries = 1
+	timeSeriesLabels       = 1
+	timeSeriesSamples      = 2
+	labelName              = 1
+	labelValue             = 2
+	sampleValue            = 1
+	sampleTimestamp        = 2
+)
+
+type label struct {
+	name, value string
+}
+
+// writeRequest Encodes the metric families as a remote write WriteRequest. Samples without
+// their own timestamp get timestampMs. External labels are added to the series without them.
+// Returns the encoded request and the number of samples.
+func writeRequest(families []*dto.MetricFamily, externalLabels map[string]string, timestampMs int64) ([]byte, int) {
+	var w = requestWriter{externalLabels: externalLabels}
+
+	for _, mf := range families {
+		name := mf.GetName()
+		for _, m := range mf.GetMetric() {
+			ts := timestampMs
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				w.series(name, m.GetLabel(), nil, m.GetCounter().GetValue(), ts)
+			case dto.MetricType_GAUGE:
+				w.series(name, m.GetLabel(), nil, m.GetGauge().GetValue(), ts)
+			case dto.MetricType_UNTYPED:
+				w.series(name, m.GetLabel(), nil, m.GetUntyped().GetValue(), ts)
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					w.series(name, m.GetLabel(), &label{"quantile", formatFloat(q.GetQuantile())}, q.GetValue(), ts)
+				}
+				w.series(name+"_sum", m.GetLabel(), nil, s.GetSampleSum(), ts)
+				w.series(name+"_count", m.GetLabel(), nil, float64(s.GetSampleCount()), ts)
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				var inf bool
+				for _, b := range h.GetBucket() {
+					inf = inf || math.IsInf(b.GetUpperBound(), +1)
+					w.series(name+"_bucket", m.GetLabel(), &label{"le", formatFloat(b.GetUpperBound())}, float64(b.GetCumulativeCount()), ts)
+				}
+				if !inf {
+					w.series(name+"_bucket", m.GetLabel(), &label{"le", "+Inf"}, float64(h.GetSampleCount()), ts)
+				}
+				w.series(name+"_sum", m.GetLabel(), nil, h.GetSampleSum(), ts)
+				w.series(name+"_count", m.GetLabel(), nil, float64(h.GetSampleCount()), ts)
+			}
+		}
+	}
+
+	return w.buf, w.samples
+}
+
+// requestWriter Appends the series to the WriteRequest reusing the buffers of the nested messages.
+type requestWriter struct {
+	externalLabels map[string]string
+	buf            []byte
+	samples        int
+	labels         []label
+	ts             []byte
+	msg            []byte
+}
+
+// series Appends a TimeSeries with a single sample.
+func (w *requestWriter) series(name string, labels []*dto.LabelPair, extra *label, value float64, ts int64) {
+	w.labels = append(w.labels[:0], label{"__name__", name})
+	for _, l := range labels {
+		w.labels = append(w.labels, label{l.GetName(), l.GetValue()})
+	}
+	if extra != nil {
+		w.labels = append(w.labels, *extra)
+	}
+	for name, value := range w.externalLabels {
+		if !hasLabel(w.labels, name) {
+			w.labels = append(w.labels, label{name, value})
+		}
+	}
+	sort.Slice(w.labels, func(i, j int) bool { return w.labels[i].name < w.labels[j].name })
+
+	w.ts = w.ts[:0]
+	for _, l := range w.labels {
+		w.msg = w.msg[:0]
+		w.msg = protowire.AppendTag(w.msg, labelName, protowire.BytesType)
+		w.msg = protowire.AppendString(w.msg, l.name)
+		w.msg = protowire.AppendTag(w.msg, labelValue, protowire.BytesType)
+		w.msg = protowire.AppendString(w.msg, l.value)
+
+		w.ts = protowire.AppendTag(w.ts, timeSeriesLabels, protowire.BytesType)
+		w.ts = protowire.AppendBytes(w.ts, w.msg)
+	}
+
+	w.msg = w.msg[:0]
+	w.msg = protowire.AppendTag(w.msg, sampleValue, protowire.Fixed64Type)
+	w.msg = protowire.AppendFixed64(w.msg, math.Float64bits(value))
+	w.msg = protowire.AppendTag(w.msg, sampleTimestamp, protowire.VarintType)
+	w.msg = protowire.AppendVarint(w.msg, uint64(ts))
+
+	w.ts = protowire.AppendTag(w.ts, timeSeriesSamples, protowire.BytesType)
+	w.ts = protowire.AppendBytes(w.ts, w.msg)
+
+	w.buf = protowire.AppendTag(w.buf, writeRequestTimeseries, protowire.BytesType)
+	w.buf = protowire.AppendBytes(w.buf, w.ts)
+	w.samples++
+}
+
+func hasLabel(labels []label, name string) bool {
+	for _, l := range labels {
+		if l.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
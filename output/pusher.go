@@ -0,0 +1,266 @@
+package output
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+const (
+	DefaultPushTimeout    = 30 * time.Second
+	DefaultPushQueueSize  = 120
+	DefaultPushMinBackoff = time.Second
+	DefaultPushMaxBackoff = time.Minute
+)
+
+// PushConfig Interval, queue and retry settings of the push outputs.
+type PushConfig struct {
+	// Interval How often the metrics are gathered and queued, the --interval flag value by default
+	Interval time.Duration `yaml:"interval,omitempty"`
+	// Timeout Timeout of a request
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+	// QueueSize Number of collections kept while the receiver is unavailable, the oldest are dropped
+	QueueSize  int           `yaml:"queue_size,omitempty"`
+	MinBackoff time.Duration `yaml:"min_backoff,omitempty"`
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty"`
+}
+
+// setDefaults Validates the settings and sets the defaults of the unset ones.
+func (c *PushConfig) setDefaults(interval time.Duration) error {
+	if c.Interval < 0 {
+		return fmt.Errorf("interval '%v' must not be negative", c.Interval)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout '%v' must not be negative", c.Timeout)
+	}
+
+	if c.Interval == 0 {
+		c.Interval = interval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultPushTimeout
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultPushQueueSize
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = DefaultPushMinBackoff
+	}
+	if c.MaxBackoff < c.MinBackoff {
+		c.MaxBackoff = max(DefaultPushMaxBackoff, c.MinBackoff)
+	}
+	return nil
+}
+
+// recoverableError Error of a request that can be retried.
+type recoverableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *recoverableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *recoverableError) Unwrap() error {
+	return e.err
+}
+
+// pusher Gathers the metrics on the interval, queues them and sends the batches.
+// Recoverable errors are retried with exponential backoff, other errors drop the batch.
+type pusher struct {
+	// name Output name in the log messages
+	name   string
+	conf   PushConfig
+	queue  *queue
+	gather func(ctx context.Context) ([]byte, int)
+	post   func(ctx context.Context, b batch) error
+	// gatherOnShutdown Gather once more before the last flush, for outputs buffering the data themselves
+	gatherOnShutdown bool
+
+	samplesSent    prom.Counter
+	samplesDropped prom.Counter
+	failedRequests prom.Counter
+	queueLength    prom.GaugeFunc
+	lastSuccess    prom.Gauge
+}
+
+// newPusher Creates the pusher, its metrics are named mikrotik_exporter_<subsystem>_*.
+func newPusher(name, subsystem string, conf PushConfig, constLabels prom.Labels) *pusher {
+	var p = &pusher{
+		name:  name,
+		conf:  conf,
+		queue: newQueue(conf.QueueSize),
+		samplesSent: prom.NewCounter(prom.CounterOpts{
+			Namespace: "mikrotik_exporter", Subsystem: subsystem, Name: "samples_sent_total",
+			Help:        "Number of samples accepted by the receiver",
+			ConstLabels: constLabels,
+		}),
+		samplesDropped: prom.NewCounter(prom.CounterOpts{
+			Namespace: "mikrotik_exporter", Subsystem: subsystem, Name: "samples_dropped_total",
+			Help:        "Number of samples dropped because the queue was full or the receiver rejected them",
+			ConstLabels: constLabels,
+		}),
+		failedRequests: prom.NewCounter(prom.CounterOpts{
+			Namespace: "mikrotik_exporter", Subsystem: subsystem, Name: "failed_requests_total",
+			Help:        "Number of failed requests",
+			ConstLabels: constLabels,
+		}),
+		lastSuccess: prom.NewGauge(prom.GaugeOpts{
+			Namespace: "mikrotik_exporter", Subsystem: subsystem, Name: "last_success_timestamp_seconds",
+			Help:        "Time of the last successful request",
+			ConstLabels: constLabels,
+		}),
+	}
+	p.queueLength = prom.NewGaugeFunc(prom.GaugeOpts{
+		Namespace: "mikrotik_exporter", Subsystem: subsystem, Name: "queue_batches",
+		Help:        "Number of collections waiting to be sent",
+		ConstLabels: constLabels,
+	}, func() float64 {
+		return float64(p.queue.len())
+	})
+
+	return p
+}
+
+// Describe implements prometheus.Collector.
+func (p *pusher) Describe(ch chan<- *prom.Desc) {
+	p.samplesSent.Describe(ch)
+	p.samplesDropped.Describe(ch)
+	p.failedRequests.Describe(ch)
+	p.queueLength.Describe(ch)
+	p.lastSuccess.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (p *pusher) Collect(ch chan<- prom.Metric) {
+	p.samplesSent.Collect(ch)
+	p.samplesDropped.Collect(ch)
+	p.failedRequests.Collect(ch)
+	p.queueLength.Collect(ch)
+	p.lastSuccess.Collect(ch)
+}
+
+// Run Gathers and sends the metrics until the context is cancelled, then tries once
+// to send the queued batches.
+func (p *pusher) Run(ctx context.Context) {
+	senderDone := make(chan struct{})
+	go func() {
+		p.send(ctx)
+		close(senderDone)
+	}()
+
+	ticker := time.NewTicker(p.conf.Interval)
+	defer ticker.Stop()
+
+	p.enqueue(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			p.enqueue(ctx)
+		case <-ctx.Done():
+			<-senderDone
+			if p.gatherOnShutdown {
+				p.enqueue(ctx)
+			}
+			p.flush(ctx)
+			return
+		}
+	}
+}
+
+// enqueue Gathers the metrics and queues them as a batch.
+func (p *pusher) enqueue(ctx context.Context) {
+	data, samples := p.gather(ctx)
+	p.push(ctx, data, samples)
+}
+
+// push Queues the batch.
+func (p *pusher) push(ctx context.Context, data []byte, samples int) {
+	if samples == 0 {
+		return
+	}
+
+	if dropped := p.queue.push(data, samples); dropped > 0 {
+		p.samplesDropped.Add(float64(dropped))
+		zerolog.Ctx(ctx).Warn().Msgf("%s: queue is full, dropped the oldest batch of %d samples", p.name, dropped)
+	}
+}
+
+// send Sends the queued batches until the context is cancelled.
+func (p *pusher) send(ctx context.Context) {
+	logger := zerolog.Ctx(ctx)
+	backoff := p.conf.MinBackoff
+
+	for {
+		b, ok := p.queue.peek()
+		if !ok {
+			select {
+			case <-p.queue.notify:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		err := p.post(ctx, b)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var retryErr *recoverableError
+		switch {
+		case err == nil:
+			p.sent(b)
+			backoff = p.conf.MinBackoff
+		case errors.As(err, &retryErr):
+			p.failedRequests.Inc()
+			// Retry-After may ask for longer than the receiver should be waited for
+			wait := max(backoff, min(retryErr.retryAfter, p.conf.MaxBackoff))
+			logger.Warn().Err(err).Msgf("%s: retrying in %v", p.name, wait)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+			backoff = min(backoff*2, p.conf.MaxBackoff)
+		default:
+			p.failedRequests.Inc()
+			p.queue.remove(b)
+			p.samplesDropped.Add(float64(b.samples))
+			logger.Error().Err(err).Msgf("%s: dropped the batch of %d samples", p.name, b.samples)
+		}
+	}
+}
+
+// flush Tries once to send the queued batches within the request timeout.
+func (p *pusher) flush(ctx context.Context) {
+	if p.queue.len() == 0 {
+		return
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.conf.Timeout)
+	defer cancel()
+
+	for {
+		b, ok := p.queue.peek()
+		if !ok {
+			return
+		}
+		if err := p.post(flushCtx, b); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msgf("%s: %d batches not sent on shutdown", p.name, p.queue.len())
+			return
+		}
+		p.sent(b)
+	}
+}
+
+func (p *pusher) sent(b batch) {
+	p.queue.remove(b)
+	p.samplesSent.Add(float64(b.samples))
+	p.lastSuccess.SetToCurrentTime()
+}
@@ -0,0 +1,71 @@
+package output
+
+import "sync"
+
+// batch Encoded request waiting to be sent.
+type batch struct {
+	seq     uint64
+	data    []byte
+	samples int
+}
+
+// queue Bounded in-memory queue of batches kept while the receiver is unavailable.
+// When it is full, the oldest batch is dropped.
+type queue struct {
+	mu      sync.Mutex
+	items   []batch
+	size    int
+	nextSeq uint64
+	// notify Signals the sender that a batch was added
+	notify chan struct{}
+}
+
+func newQueue(size int) *queue {
+	return &queue{size: size, notify: make(chan struct{}, 1)}
+}
+
+// push Adds the batch. Returns the number of samples of the dropped batch, if any.
+func (q *queue) push(data []byte, samples int) (dropped int) {
+	q.mu.Lock()
+	if len(q.items) >= q.size {
+		dropped = q.items[0].samples
+		q.items = q.items[1:]
+	}
+	q.nextSeq++
+	q.items = append(q.items, batch{seq: q.nextSeq, data: data, samples: samples})
+	q.mu.Unlock()
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return dropped
+}
+
+// peek Returns the oldest batch without removing it.
+func (q *queue) peek() (batch, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return batch{}, false
+	}
+	return q.items[0], true
+}
+
+// remove Removes the batch if it has not been dropped meanwhile.
+func (q *queue) remove(b batch) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) > 0 && q.items[0].seq == b.seq {
+		q.items[0] = batch{}
+		q.items = q.items[1:]
+	}
+}
+
+func (q *queue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
@@ -0,0 +1,139 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// RemoteWriteConfig Pushes the metrics to a Prometheus remote write receiver, e.g. when
+// Prometheus can't reach the exporter behind NAT.
+//
+//	remote_write:
+//	  url: https://prometheus.example.com/api/v1/write
+//	  interval: 30s           # the --interval flag value by default
+//	  timeout: 30s
+//	  queue_size: 120         # collections kept while the receiver is unavailable
+//	  min_backoff: 1s
+//	  max_backoff: 1m
+//	  external_labels:
+//	    site: branch
+//	  basic_auth:
+//	    username: exporter
+//	    password_file: /run/secrets/remote-write-password
+type RemoteWriteConfig struct {
+	URL        string `yaml:"url"`
+	PushConfig `yaml:",inline"`
+	// ExternalLabels Labels added to all series that don't have them
+	ExternalLabels   map[string]string `yaml:"external_labels,omitempty"`
+	HTTPClientConfig `yaml:",inline"`
+}
+
+// RemoteWriter Gathers the metrics on the interval and pushes them to the remote write receiver.
+// Failed requests are retried with exponential backoff, 4xx responses other than
+// 429 Too Many Requests drop the batch.
+type RemoteWriter struct {
+	*pusher
+	conf     RemoteWriteConfig
+	gatherer prom.Gatherer
+	client   *http.Client
+}
+
+// NewRemoteWriter Creates the writer of the metrics gathered from the gatherer. The interval is used
+// if the configuration doesn't set one.
+func NewRemoteWriter(conf RemoteWriteConfig, gatherer prom.Gatherer, interval time.Duration, constLabels prom.Labels) (*RemoteWriter, error) {
+	if u, err := url.Parse(conf.URL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("remote write url '%s' is invalid", conf.URL)
+	}
+	if err := conf.setDefaults(interval); err != nil {
+		return nil, fmt.Errorf("remote write: %w", err)
+	}
+
+	client, err := conf.newHTTPClient(conf.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("remote write: %w", err)
+	}
+
+	var w = &RemoteWriter{
+		pusher:   newPusher("remote write", "remote_write", conf.PushConfig, constLabels),
+		conf:     conf,
+		gatherer: gatherer,
+		client:   client,
+	}
+	w.pusher.gather, w.pusher.post = w.gather, w.post
+
+	return w, nil
+}
+
+// Run Gathers and sends the metrics until the context is cancelled.
+func (w *RemoteWriter) Run(ctx context.Context) {
+	zerolog.Ctx(ctx).Info().Str("url", w.conf.URL).Msgf("pushing metrics with remote write every %v", w.conf.Interval)
+	w.pusher.Run(ctx)
+}
+
+// gather Returns the gathered metrics as a compressed WriteRequest.
+func (w *RemoteWriter) gather(ctx context.Context) ([]byte, int) {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		// The metrics that could be gathered are still sent
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("remote write: gathering metrics")
+	}
+
+	data, samples := writeRequest(families, w.conf.ExternalLabels, time.Now().UnixMilli())
+	return snappy.Encode(nil, data), samples
+}
+
+// post Sends the batch. Network errors, 5xx and 429 responses are recoverable.
+func (w *RemoteWriter) post(ctx context.Context, b batch) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.conf.URL, bytes.NewReader(b.data))
+	if err != nil {
+		return err
+	}
+	if err := w.conf.prepare(req); err != nil {
+		return &recoverableError{err: err}
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return &recoverableError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("POST '%s' returned response code: %v, message: '%s'", w.conf.URL, resp.StatusCode, bytes.TrimSpace(body))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return &recoverableError{err: err, retryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return err
+}
+
+// retryAfter Parses the Retry-After header in seconds or as an HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
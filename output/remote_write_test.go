@@ -0,0 +1,390 @@
+package output
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type testSeries struct {
+	labels    []label
+	value     float64
+	timestamp int64
+}
+
+func (s testSeries) label(name string) (string, bool) {
+	for _, l := range s.labels {
+		if l.name == name {
+			return l.value, true
+		}
+	}
+	return "", false
+}
+
+// decodeWriteRequest Decodes the snappy-compressed WriteRequest.
+func decodeWriteRequest(t *testing.T, body []byte) []testSeries {
+	t.Helper()
+
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("snappy decoding: %v", err)
+	}
+
+	var res []testSeries
+	for _, ts := range decodeFields(t, data, writeRequestTimeseries) {
+		var s testSeries
+		for _, l := range decodeFields(t, ts, timeSeriesLabels) {
+			var lb label
+			for _, f := range decodeFields(t, l, labelName) {
+				lb.name = string(f)
+			}
+			for _, f := range decodeFields(t, l, labelValue) {
+				lb.value = string(f)
+			}
+			s.labels = append(s.labels, lb)
+		}
+		samples := decodeFields(t, ts, timeSeriesSamples)
+		if len(samples) != 1 {
+			t.Fatalf("series %v has %d samples, want 1", s.labels, len(samples))
+		}
+		for b := samples[0]; len(b) > 0; {
+			num, typ, n := protowire.ConsumeTag(b)
+			b = b[n:]
+			switch {
+			case num == sampleValue && typ == protowire.Fixed64Type:
+				v, n := protowire.ConsumeFixed64(b)
+				s.value, b = math.Float64frombits(v), b[n:]
+			case num == sampleTimestamp && typ == protowire.VarintType:
+				v, n := protowire.ConsumeVarint(b)
+				s.timestamp, b = int64(v), b[n:]
+			default:
+				t.Fatalf("unexpected sample field %d", num)
+			}
+		}
+		res = append(res, s)
+	}
+	return res
+}
+
+// decodeFields Returns the values of the length-delimited field.
+func decodeFields(t *testing.T, b []byte, field protowire.Number) [][]byte {
+	t.Helper()
+
+	var res [][]byte
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("decoding tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			t.Fatalf("decoding field %d: %v", num, protowire.ParseError(n))
+		}
+		if num == field && typ == protowire.BytesType {
+			v, _ := protowire.ConsumeBytes(b)
+			res = append(res, v)
+		}
+		b = b[n:]
+	}
+	return res
+}
+
+func counterValue(c prom.Counter) float64 {
+	var m dto.Metric
+	_ = c.Write(&m)
+	return m.GetCounter().GetValue()
+}
+
+// testReceiver Remote write receiver answering with the status codes in turn, 204 afterwards.
+type testReceiver struct {
+	mu       sync.Mutex
+	codes    []int
+	header   http.Header
+	bodies   [][]byte
+	times    []time.Time
+	received chan struct{}
+}
+
+func newTestReceiver(codes ...int) (*testReceiver, *httptest.Server) {
+	r := &testReceiver{codes: codes, header: http.Header{}, received: make(chan struct{}, 16)}
+	return r, httptest.NewServer(r)
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	r.bodies = append(r.bodies, body)
+	r.times = append(r.times, time.Now())
+	code := http.StatusNoContent
+	if len(r.codes) > 0 {
+		code, r.codes = r.codes[0], r.codes[1:]
+	}
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(code)
+	r.received <- struct{}{}
+}
+
+func (r *testReceiver) wait(t *testing.T, requests int) {
+	t.Helper()
+	for i := 0; i < requests; i++ {
+		select {
+		case <-r.received:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d requests, want %d", i, requests)
+		}
+	}
+}
+
+func testRegistry() *prom.Registry {
+	reg := prom.NewRegistry()
+
+	c := prom.NewCounterVec(prom.CounterOpts{Name: "test_rx_bytes_total", Help: "test"}, []string{"name", "board"})
+	c.WithLabelValues("ether1", "hAP").Add(100)
+	h := prom.NewHistogram(prom.HistogramOpts{Name: "test_duration_seconds", Help: "test", Buckets: []float64{0.5, 1}})
+	h.Observe(0.7)
+	reg.MustRegister(c, h)
+
+	return reg
+}
+
+// runWriter Runs the writer gathering once, stopping it at the end of the test.
+func runWriter(t *testing.T, conf RemoteWriteConfig) *RemoteWriter {
+	t.Helper()
+
+	conf.Interval = time.Hour
+	w, err := NewRemoteWriter(conf, testRegistry(), time.Minute, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return w
+}
+
+func TestRemoteWriteRequest(t *testing.T) {
+	rcv, srv := newTestReceiver()
+	defer srv.Close()
+
+	w := runWriter(t, RemoteWriteConfig{
+		URL:            srv.URL,
+		ExternalLabels: map[string]string{"site": "dc1", "board": "ignored"},
+	})
+	rcv.wait(t, 1)
+
+	series := decodeWriteRequest(t, rcv.bodies[0])
+	// 1 counter, 3 buckets with +Inf, _sum and _count
+	if len(series) != 6 {
+		t.Fatalf("got %d series, want 6", len(series))
+	}
+
+	var buckets = make(map[string]float64)
+	for _, s := range series {
+		for i := 1; i < len(s.labels); i++ {
+			if s.labels[i-1].name >= s.labels[i].name {
+				t.Errorf("labels %v are not sorted", s.labels)
+			}
+		}
+		if site, _ := s.label("site"); site != "dc1" {
+			t.Errorf("series %v: external label site is '%s'", s.labels, site)
+		}
+		if s.timestamp == 0 {
+			t.Errorf("series %v has no timestamp", s.labels)
+		}
+
+		name, _ := s.label("__name__")
+		switch name {
+		case "test_rx_bytes_total":
+			// The series label takes precedence over the external one
+			if board, _ := s.label("board"); board != "hAP" {
+				t.Errorf("board label is '%s', want 'hAP'", board)
+			}
+			if s.value != 100 {
+				t.Errorf("counter value is %v, want 100", s.value)
+			}
+		case "test_duration_seconds_bucket":
+			le, _ := s.label("le")
+			buckets[le] = s.value
+		case "test_duration_seconds_count":
+			if s.value != 1 {
+				t.Errorf("histogram count is %v, want 1", s.value)
+			}
+		}
+	}
+
+	want := map[string]float64{"0.5": 0, "1": 1, "+Inf": 1}
+	for le, v := range want {
+		if got, ok := buckets[le]; !ok || got != v {
+			t.Errorf("bucket le=%s is %v (%v), want %v", le, got, ok, v)
+		}
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if got := counterValue(w.samplesSent); got != 6 {
+		t.Errorf("samples_sent_total is %v, want 6", got)
+	}
+}
+
+func TestRemoteWriteRetry(t *testing.T) {
+	for _, code := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		t.Run(http.StatusText(code), func(t *testing.T) {
+			rcv, srv := newTestReceiver(code)
+			defer srv.Close()
+
+			w := runWriter(t, RemoteWriteConfig{
+				URL:        srv.URL,
+				PushConfig: PushConfig{MinBackoff: 10 * time.Millisecond, MaxBackoff: 100 * time.Millisecond},
+			})
+			rcv.wait(t, 2)
+
+			if string(rcv.bodies[0]) != string(rcv.bodies[1]) {
+				t.Error("the retried batch differs")
+			}
+			time.Sleep(50 * time.Millisecond)
+			if got := counterValue(w.failedRequests); got != 1 {
+				t.Errorf("failed_requests_total is %v, want 1", got)
+			}
+			if got := counterValue(w.samplesSent); got != 6 {
+				t.Errorf("samples_sent_total is %v, want 6", got)
+			}
+			if got := counterValue(w.samplesDropped); got != 0 {
+				t.Errorf("samples_dropped_total is %v, want 0", got)
+			}
+		})
+	}
+}
+
+func TestRemoteWriteRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxBackoff time.Duration
+		min, max   time.Duration
+	}{
+		{name: "honoured", maxBackoff: 5 * time.Second, min: time.Second, max: 3 * time.Second},
+		{name: "capped at max_backoff", maxBackoff: 200 * time.Millisecond, min: 200 * time.Millisecond, max: 900 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcv, srv := newTestReceiver(http.StatusServiceUnavailable)
+			defer srv.Close()
+			rcv.header.Set("Retry-After", "1")
+
+			runWriter(t, RemoteWriteConfig{
+				URL:        srv.URL,
+				PushConfig: PushConfig{MinBackoff: 10 * time.Millisecond, MaxBackoff: tt.maxBackoff},
+			})
+			rcv.wait(t, 2)
+
+			if wait := rcv.times[1].Sub(rcv.times[0]); wait < tt.min || wait > tt.max {
+				t.Errorf("retried after %v, want between %v and %v", wait, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestRemoteWriteDropOnClientError(t *testing.T) {
+	rcv, srv := newTestReceiver(http.StatusBadRequest)
+	defer srv.Close()
+
+	w := runWriter(t, RemoteWriteConfig{
+		URL:        srv.URL,
+		PushConfig: PushConfig{MinBackoff: 10 * time.Millisecond},
+	})
+	rcv.wait(t, 1)
+
+	// The batch must not be retried
+	select {
+	case <-rcv.received:
+		t.Fatal("the rejected batch was retried")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if got := counterValue(w.samplesDropped); got != 6 {
+		t.Errorf("samples_dropped_total is %v, want 6", got)
+	}
+	if got := counterValue(w.samplesSent); got != 0 {
+		t.Errorf("samples_sent_total is %v, want 0", got)
+	}
+	if w.queue.len() != 0 {
+		t.Errorf("queue has %d batches, want 0", w.queue.len())
+	}
+}
+
+func TestQueueDropsOldest(t *testing.T) {
+	q := newQueue(2)
+
+	for i, samples := range []int{1, 2, 3} {
+		dropped := q.push([]byte{byte(i)}, samples)
+		if want := map[int]int{0: 0, 1: 0, 2: 1}[i]; dropped != want {
+			t.Errorf("push %d dropped %d samples, want %d", i, dropped, want)
+		}
+	}
+	if q.len() != 2 {
+		t.Fatalf("queue has %d batches, want 2", q.len())
+	}
+
+	b, _ := q.peek()
+	if b.samples != 2 {
+		t.Errorf("oldest batch has %d samples, want 2", b.samples)
+	}
+
+	// A batch dropped while it was being sent is not removed again
+	q.push(nil, 4)
+	q.remove(b)
+	if b, _ := q.peek(); q.len() != 2 || b.samples != 3 {
+		t.Errorf("queue has %d batches, the oldest with %d samples, want 2 and 3", q.len(), b.samples)
+	}
+}
+
+func TestPusherQueueFullDropsSamples(t *testing.T) {
+	p := newPusher("test", "test", PushConfig{QueueSize: 1}, nil)
+
+	p.push(context.Background(), []byte("a"), 5)
+	p.push(context.Background(), []byte("b"), 7)
+
+	if got := counterValue(p.samplesDropped); got != 5 {
+		t.Errorf("samples_dropped_total is %v, want 5", got)
+	}
+	if b, _ := p.queue.peek(); string(b.data) != "b" {
+		t.Errorf("queued batch is '%s', want 'b'", b.data)
+	}
+}
+
+func TestPushConfigRejectsNegative(t *testing.T) {
+	for _, conf := range []PushConfig{{Interval: -time.Second}, {Timeout: -time.Second}} {
+		if err := conf.setDefaults(time.Minute); err == nil {
+			t.Errorf("%+v: expected an error", conf)
+		}
+	}
+
+	_, err := NewRemoteWriter(RemoteWriteConfig{URL: "http://localhost", PushConfig: PushConfig{Interval: -time.Second}},
+		prom.NewRegistry(), time.Minute, nil)
+	if err == nil {
+		t.Error("NewRemoteWriter: expected an error for a negative interval")
+	}
+}